@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alvinatthariq/farmsvc-go/backup"
+	"github.com/alvinatthariq/farmsvc-go/entity"
+)
+
+// RunBackup handle backup command, archive of every farm & pond including deleted one is written to file or stdout
+func RunBackup(args []string) {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	output := flags.String("o", "", "output file, - is stdout")
+	farmIDs := flags.String("farm-id", "", "comma separated farms to back up with their ponds, every farm when empty")
+	statistics := flags.Bool("statistics", false, "include api statistic snapshots & current api statistic")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: farmsvc-go backup -o file [-farm-id id,...] [-statistics]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *output == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	InitApp(os.Stderr)
+	InitCommandDomain(false)
+	defer CloseCommandDomain()
+
+	param := entity.BackupParam{IncludeStatistics: *statistics}
+	if *farmIDs != "" {
+		param.FarmIDs = strings.Split(*farmIDs, ",")
+	}
+
+	manifest, checksum, err := writeBackup(backup.New(dbgorm, dom), param, *output)
+	if err != nil {
+		fatal(err)
+	}
+
+	attrs := []any{slog.String("output", *output), slog.String("sha256", checksum), slog.Int64("schema_version", manifest.SchemaVersion)}
+	for _, file := range manifest.Files {
+		attrs = append(attrs, slog.Int(strings.TrimSuffix(file.Name, ".json"), file.Count))
+	}
+	appLogger.Info("Backup Completed", attrs...)
+}
+
+// writeBackup stream archive into output with its checksum, - is stdout. File is written under temporary name
+// in the same directory then renamed once complete, so failed backup never leave partial file
+func writeBackup(b *backup.Backup, param entity.BackupParam, output string) (manifest entity.BackupManifest, checksum string, err error) {
+	if output == "-" {
+		return b.BackupChecksum(context.Background(), os.Stdout, param)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(output), "."+filepath.Base(output)+".*")
+	if err != nil {
+		return manifest, "", err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	w := bufio.NewWriter(f)
+	manifest, checksum, err = b.BackupChecksum(context.Background(), w, param)
+	if err != nil {
+		return manifest, "", err
+	}
+	if err = w.Flush(); err != nil {
+		return manifest, "", err
+	}
+	if err = f.Sync(); err != nil {
+		return manifest, "", err
+	}
+	if err = f.Close(); err != nil {
+		return manifest, "", err
+	}
+	if err = os.Rename(f.Name(), output); err != nil {
+		return manifest, "", err
+	}
+
+	return manifest, checksum, nil
+}
+
+// RunRestore handle restore command, archive of backup command or admin endpoint is loaded in one transaction
+func RunRestore(args []string) {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	mode := flags.String("mode", entity.RestoreModeEmpty, "empty require database without farm & pond, merge load into existing data")
+	conflict := flags.String("conflict", entity.RestoreConflictFail, "on merge, farm or pond already existing: fail, skip, overwrite or newer")
+	statistics := flags.Bool("statistics", false, "restore api statistic snapshots of archive")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: farmsvc-go restore [-mode empty|merge] [-conflict fail|skip|overwrite|newer] [-statistics] <file|->")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	input := flags.Arg(0)
+
+	InitApp(os.Stderr)
+
+	var r io.Reader = os.Stdin
+	if input != "-" {
+		f, err := os.Open(input)
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	InitCommandDomain(true)
+	defer CloseCommandDomain()
+
+	result, err := backup.New(dbgorm, dom).Restore(context.Background(), r, entity.RestoreParam{
+		Mode:              *mode,
+		Conflict:          *conflict,
+		IncludeStatistics: *statistics,
+	})
+	if err != nil {
+		fatal(fmt.Errorf("Restore %s failed, nothing was written : %w", input, err))
+	}
+	appLogger.Info("Restore Completed", slog.String("input", input),
+		slog.Any("farms", result.Farms), slog.Any("ponds", result.Ponds), slog.Any("api_statistic_snapshots", result.APIStatisticSnapshots))
+}
@@ -0,0 +1,308 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/alvinatthariq/farmsvc-go/domain"
+	"github.com/alvinatthariq/farmsvc-go/entity"
+	"github.com/alvinatthariq/farmsvc-go/migration"
+	"github.com/alvinatthariq/farmsvc-go/repository"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// MaxFileSize is max size of one file of archive read by Restore, MaxArchiveSize is max size of every file
+	// together after decompression
+	MaxFileSize    = 256 << 20
+	MaxArchiveSize = 512 << 20
+)
+
+// archiveFiles is name of every file archive may hold, each at most once
+var archiveFiles = map[string]bool{
+	entity.BackupFileManifest:              true,
+	entity.BackupFileFarms:                 true,
+	entity.BackupFilePonds:                 true,
+	entity.BackupFileAPIStatisticSnapshots: true,
+}
+
+// farmRecord & pondRecord keep soft delete state hidden from api response
+type farmRecord struct {
+	entity.Farm
+	DeletedAt *time.Time `json:"deleted_at"`
+	IsDeleted bool       `json:"is_deleted"`
+}
+
+type pondRecord struct {
+	entity.Pond
+	DeletedAt *time.Time `json:"deleted_at"`
+	IsDeleted bool       `json:"is_deleted"`
+}
+
+// Backup write & restore archive of farms, ponds and api statistic snapshots. It read & write database
+// directly so soft deleted data & timestamps are kept, cache of restored farm & pond is invalidated through domain
+type Backup struct {
+	gorm *gorm.DB
+	dom  domain.DomainItf
+}
+
+func New(gorm *gorm.DB, dom domain.DomainItf) *Backup {
+	return &Backup{
+		gorm: gorm,
+		dom:  dom,
+	}
+}
+
+// Backup write gzipped tar archive into w, data is read from primary in one transaction so it is point in time.
+// Archive start with manifest listing checksum of every data file
+func (b *Backup) Backup(ctx context.Context, w io.Writer, param entity.BackupParam) (manifest entity.BackupManifest, err error) {
+	manifest = entity.BackupManifest{
+		FormatVersion:     entity.BackupFormatVersion,
+		CreatedAt:         time.Now().UTC().Truncate(time.Second),
+		FarmIDs:           param.FarmIDs,
+		IncludeStatistics: param.IncludeStatistics,
+	}
+
+	manifest.SchemaVersion, err = migration.New(b.gorm.WithContext(ctx)).Current()
+	if err != nil {
+		return manifest, err
+	}
+
+	var (
+		farms     []entity.Farm
+		ponds     []entity.Pond
+		snapshots []entity.APIStatisticSnapshot
+	)
+	err = b.gorm.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		farmQuery, pondQuery := tx.Order("id"), tx.Order("id")
+		if len(param.FarmIDs) > 0 {
+			farmQuery = farmQuery.Where("id IN ?", param.FarmIDs)
+			pondQuery = pondQuery.Where("farm_id IN ?", param.FarmIDs)
+		}
+		if err := farmQuery.Find(&farms).Error; err != nil {
+			return err
+		}
+		if err := pondQuery.Find(&ponds).Error; err != nil {
+			return err
+		}
+
+		if param.IncludeStatistics {
+			return tx.Order("snapshot_at, path").Find(&snapshots).Error
+		}
+
+		return nil
+	}, repository.SnapshotTxOptions(b.gorm))
+	if err != nil {
+		return manifest, err
+	}
+
+	if param.IncludeStatistics {
+		// current api statistic is kept as snapshot, live counter of redis is never overwritten by restore
+		apiStatistics, err := b.dom.GetAPIStatistic(ctx, entity.APIStatisticParam{})
+		if err != nil {
+			return manifest, err
+		}
+		for _, apiStatistic := range apiStatistics {
+			snapshots = append(snapshots, entity.NewAPIStatisticSnapshot(apiStatistic, entity.APIStatisticSnapshotReasonBackup, manifest.CreatedAt))
+		}
+	}
+
+	files := map[string][]byte{}
+	addFile := func(name string, count int, v interface{}) error {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(raw)
+		files[name] = raw
+		manifest.Files = append(manifest.Files, entity.BackupFile{
+			Name:   name,
+			Count:  count,
+			Size:   int64(len(raw)),
+			SHA256: hex.EncodeToString(sum[:]),
+		})
+
+		return nil
+	}
+
+	farmRecords := make([]farmRecord, 0, len(farms))
+	for _, farm := range farms {
+		farmRecords = append(farmRecords, farmRecord{Farm: farm, DeletedAt: nullTime(farm.DeletedAt), IsDeleted: farm.IsDeleted.Valid})
+	}
+	if err := addFile(entity.BackupFileFarms, len(farmRecords), farmRecords); err != nil {
+		return manifest, err
+	}
+
+	pondRecords := make([]pondRecord, 0, len(ponds))
+	for _, pond := range ponds {
+		pondRecords = append(pondRecords, pondRecord{Pond: pond, DeletedAt: nullTime(pond.DeletedAt), IsDeleted: pond.IsDeleted.Valid})
+	}
+	if err := addFile(entity.BackupFilePonds, len(pondRecords), pondRecords); err != nil {
+		return manifest, err
+	}
+
+	if param.IncludeStatistics {
+		if snapshots == nil {
+			snapshots = []entity.APIStatisticSnapshot{}
+		}
+		if err := addFile(entity.BackupFileAPIStatisticSnapshots, len(snapshots), snapshots); err != nil {
+			return manifest, err
+		}
+	}
+
+	raw, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+
+	return manifest, writeArchive(w, raw, manifest.Files, files)
+}
+
+// writeArchive write manifest then every data file in manifest order
+func writeArchive(w io.Writer, manifest []byte, manifestFiles []entity.BackupFile, files map[string][]byte) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	write := func(name string, raw []byte) error {
+		err := tw.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(raw)),
+			ModTime: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = tw.Write(raw)
+		return err
+	}
+
+	if err := write(entity.BackupFileManifest, manifest); err != nil {
+		return err
+	}
+	for _, file := range manifestFiles {
+		if err := write(file.Name, files[file.Name]); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// readArchive return manifest & data files of archive, checksum of every file listed in manifest is verified.
+// Archive may only hold known files once each, so it has at most len(archiveFiles) entries
+func readArchive(r io.Reader) (manifest entity.BackupManifest, files map[string][]byte, err error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return manifest, nil, fmt.Errorf("%w : %w", entity.ErrorBackupInvalid, err)
+	}
+	defer gz.Close()
+
+	files = map[string][]byte{}
+	total := 0
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return manifest, nil, fmt.Errorf("%w : %w", entity.ErrorBackupInvalid, err)
+		}
+
+		if header.Typeflag != tar.TypeReg || !archiveFiles[header.Name] {
+			return manifest, nil, fmt.Errorf("%w : unknown entry %q", entity.ErrorBackupInvalid, header.Name)
+		} else if _, ok := files[header.Name]; ok {
+			return manifest, nil, fmt.Errorf("%w : duplicate entry %q", entity.ErrorBackupInvalid, header.Name)
+		}
+
+		limit := min(MaxFileSize, MaxArchiveSize-total)
+		raw, err := io.ReadAll(io.LimitReader(tr, int64(limit)+1))
+		if err != nil {
+			return manifest, nil, fmt.Errorf("%w : %w", entity.ErrorBackupInvalid, err)
+		} else if len(raw) > MaxFileSize {
+			return manifest, nil, fmt.Errorf("%w : %s is larger than %d bytes", entity.ErrorBackupInvalid, header.Name, MaxFileSize)
+		} else if len(raw) > limit {
+			return manifest, nil, fmt.Errorf("%w : archive is larger than %d bytes", entity.ErrorBackupInvalid, MaxArchiveSize)
+		}
+		total += len(raw)
+		files[header.Name] = raw
+	}
+
+	raw, ok := files[entity.BackupFileManifest]
+	if !ok {
+		return manifest, nil, fmt.Errorf("%w : %s not found", entity.ErrorBackupInvalid, entity.BackupFileManifest)
+	}
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return manifest, nil, fmt.Errorf("%w : %w", entity.ErrorBackupInvalid, err)
+	}
+	if manifest.FormatVersion != entity.BackupFormatVersion {
+		return manifest, nil, fmt.Errorf("%w : %d", entity.ErrorBackupVersionUnsupported, manifest.FormatVersion)
+	}
+
+	for _, file := range manifest.Files {
+		raw, ok := files[file.Name]
+		if !ok {
+			return manifest, nil, fmt.Errorf("%w : %s not found", entity.ErrorBackupInvalid, file.Name)
+		}
+
+		sum := sha256.Sum256(raw)
+		if hex.EncodeToString(sum[:]) != file.SHA256 || int64(len(raw)) != file.Size {
+			return manifest, nil, fmt.Errorf("%w : %s", entity.ErrorBackupChecksumMismatch, file.Name)
+		}
+	}
+
+	return manifest, files, nil
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+
+	return &t.Time
+}
+
+// softDelete return soft delete columns of record, deleted record has is_deleted set while live one has it null
+func softDelete(deletedAt *time.Time, isDeleted bool) (sql.NullTime, sql.NullBool) {
+	if !isDeleted {
+		return sql.NullTime{}, sql.NullBool{}
+	}
+
+	deleted := sql.NullTime{}
+	if deletedAt != nil {
+		deleted = sql.NullTime{Time: *deletedAt, Valid: true}
+	}
+
+	return deleted, sql.NullBool{Bool: true, Valid: true}
+}
+
+// ArchiveChecksum return hex encoded sha256 of whole archive, to verify transfer between instances
+func ArchiveChecksum(raw []byte) string {
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// BackupChecksum write archive of Backup into w and return hex encoded sha256 of what was written
+func (b *Backup) BackupChecksum(ctx context.Context, w io.Writer, param entity.BackupParam) (manifest entity.BackupManifest, checksum string, err error) {
+	hash := sha256.New()
+	manifest, err = b.Backup(ctx, io.MultiWriter(w, hash), param)
+	if err != nil {
+		return manifest, "", err
+	}
+
+	return manifest, hex.EncodeToString(hash.Sum(nil)), nil
+}
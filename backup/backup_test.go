@@ -0,0 +1,323 @@
+package backup_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alvinatthariq/farmsvc-go/backup"
+	"github.com/alvinatthariq/farmsvc-go/domain"
+	"github.com/alvinatthariq/farmsvc-go/entity"
+	"github.com/alvinatthariq/farmsvc-go/migration"
+	"github.com/alvinatthariq/farmsvc-go/repository"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+func openDB(t *testing.T, name string) *gorm.DB {
+	dialector, err := repository.NewDialector(repository.DriverSQLite, filepath.Join(t.TempDir(), name))
+	So(err, ShouldBeNil)
+
+	db, err := gorm.Open(dialector, &gorm.Config{
+		NamingStrategy: schema.NamingStrategy{
+			SingularTable: true,
+		},
+	})
+	So(err, ShouldBeNil)
+	So(migration.New(db).Up(), ShouldBeNil)
+
+	return db
+}
+
+func TestBackupRestore(t *testing.T) {
+	Convey("TestBackupRestore", t, FailureHalts, func() {
+		ctx := context.Background()
+		dom := domain.InitWithRepository(repository.NewMemory(), domain.Options{})
+		defer dom.Close()
+
+		createdAt := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+		updatedAt := createdAt.Add(time.Hour)
+		deletedAt := createdAt.Add(2 * time.Hour)
+
+		source := openDB(t, "source.db")
+		So(source.Create([]entity.Farm{
+			{ID: "farm-1", Name: "alpha", Description: "first", CreatedAt: createdAt, UpdatedAt: updatedAt},
+			{ID: "farm-2", Name: "beta", Description: "deleted", CreatedAt: createdAt, UpdatedAt: updatedAt,
+				DeletedAt: sql.NullTime{Time: deletedAt, Valid: true}, IsDeleted: sql.NullBool{Bool: true, Valid: true}},
+		}).Error, ShouldBeNil)
+		So(source.Create([]entity.Pond{
+			{ID: "pond-1", FarmID: "farm-1", Name: "gamma", Description: "first", CreatedAt: createdAt, UpdatedAt: updatedAt},
+			{ID: "pond-2", FarmID: "farm-2", Name: "delta", Description: "second", CreatedAt: createdAt, UpdatedAt: updatedAt},
+		}).Error, ShouldBeNil)
+		So(source.Create(&entity.APIStatisticSnapshot{Path: "GET /v1/farm", Count: 3, Reason: entity.APIStatisticSnapshotReasonPeriodic, SnapshotAt: createdAt}).Error, ShouldBeNil)
+
+		var archive bytes.Buffer
+		manifest, err := backup.New(source, dom).Backup(ctx, &archive, entity.BackupParam{IncludeStatistics: true})
+		So(err, ShouldBeNil)
+		So(manifest.FormatVersion, ShouldEqual, entity.BackupFormatVersion)
+		So(manifest.SchemaVersion, ShouldEqual, migration.Latest())
+		So(manifest.Files, ShouldHaveLength, 3)
+
+		testCases := []struct {
+			testID   int
+			testType string
+			testDesc string
+			param    entity.RestoreParam
+			prepare  func(db *gorm.DB)
+			expected entity.RestoreResult
+			err      error
+		}{
+			{
+				testID:   1,
+				testType: "P",
+				testDesc: "Success restore into empty database",
+				param:    entity.RestoreParam{IncludeStatistics: true},
+				expected: entity.RestoreResult{
+					Farms:                 entity.RestoreCount{Created: 2},
+					Ponds:                 entity.RestoreCount{Created: 2},
+					APIStatisticSnapshots: entity.RestoreCount{Created: 1},
+				},
+			},
+			{
+				testID:   2,
+				testType: "N",
+				testDesc: "Failed empty mode on database with farm",
+				prepare: func(db *gorm.DB) {
+					So(db.Create(&entity.Farm{ID: "farm-9", CreatedAt: createdAt, UpdatedAt: createdAt}).Error, ShouldBeNil)
+				},
+				err: entity.ErrorRestoreDatabaseNotEmpty,
+			},
+			{
+				testID:   3,
+				testType: "N",
+				testDesc: "Failed merge on conflict fail",
+				param:    entity.RestoreParam{Mode: entity.RestoreModeMerge},
+				prepare: func(db *gorm.DB) {
+					So(db.Create(&entity.Farm{ID: "farm-1", CreatedAt: createdAt, UpdatedAt: createdAt}).Error, ShouldBeNil)
+				},
+				err: entity.ErrorRestoreConflict,
+			},
+			{
+				testID:   4,
+				testType: "P",
+				testDesc: "Success merge on conflict skip",
+				param:    entity.RestoreParam{Mode: entity.RestoreModeMerge, Conflict: entity.RestoreConflictSkip},
+				prepare: func(db *gorm.DB) {
+					So(db.Create(&entity.Farm{ID: "farm-1", Name: "kept", CreatedAt: createdAt, UpdatedAt: createdAt}).Error, ShouldBeNil)
+				},
+				expected: entity.RestoreResult{
+					Farms: entity.RestoreCount{Created: 1, Skipped: 1},
+					Ponds: entity.RestoreCount{Created: 2},
+				},
+			},
+			{
+				testID:   5,
+				testType: "P",
+				testDesc: "Success merge on conflict newer",
+				param:    entity.RestoreParam{Mode: entity.RestoreModeMerge, Conflict: entity.RestoreConflictNewer},
+				prepare: func(db *gorm.DB) {
+					So(db.Create([]entity.Farm{
+						{ID: "farm-1", Name: "older", CreatedAt: createdAt, UpdatedAt: createdAt},
+						{ID: "farm-2", Name: "newer", CreatedAt: createdAt, UpdatedAt: deletedAt},
+					}).Error, ShouldBeNil)
+				},
+				expected: entity.RestoreResult{
+					Farms: entity.RestoreCount{Updated: 1, Skipped: 1},
+					Ponds: entity.RestoreCount{Created: 2},
+				},
+			},
+			{
+				testID:   6,
+				testType: "N",
+				testDesc: "Failed invalid mode",
+				param:    entity.RestoreParam{Mode: "replace"},
+				err:      entity.ErrorRestoreModeInvalid,
+			},
+			{
+				testID:   7,
+				testType: "N",
+				testDesc: "Failed invalid conflict",
+				param:    entity.RestoreParam{Mode: entity.RestoreModeMerge, Conflict: "ignore"},
+				err:      entity.ErrorRestoreConflictInvalid,
+			},
+		}
+
+		for _, tc := range testCases {
+			t.Logf("%d - [%s] : %s", tc.testID, tc.testType, tc.testDesc)
+			target := openDB(t, "target.db")
+			if tc.prepare != nil {
+				tc.prepare(target)
+			}
+
+			result, err := backup.New(target, dom).Restore(ctx, bytes.NewReader(archive.Bytes()), tc.param)
+			if tc.testType == "N" {
+				So(err, ShouldWrap, tc.err)
+				continue
+			}
+			So(err, ShouldBeNil)
+			So(result.Farms, ShouldResemble, tc.expected.Farms)
+			So(result.Ponds, ShouldResemble, tc.expected.Ponds)
+			So(result.APIStatisticSnapshots, ShouldResemble, tc.expected.APIStatisticSnapshots)
+		}
+
+		t.Logf("%d - [%s] : %s", 8, "P", "Success keep soft delete state & timestamps")
+		target := openDB(t, "target.db")
+		_, err = backup.New(target, dom).Restore(ctx, bytes.NewReader(archive.Bytes()), entity.RestoreParam{})
+		So(err, ShouldBeNil)
+		var farm entity.Farm
+		So(target.First(&farm, "id = ?", "farm-2").Error, ShouldBeNil)
+		So(farm.IsDeleted.Valid, ShouldBeTrue)
+		So(farm.DeletedAt.Time.Equal(deletedAt), ShouldBeTrue)
+		So(farm.UpdatedAt.Equal(updatedAt), ShouldBeTrue)
+
+		t.Logf("%d - [%s] : %s", 9, "P", "Success merge overwrite with statistics")
+		restoreParam := entity.RestoreParam{Mode: entity.RestoreModeMerge, Conflict: entity.RestoreConflictOverwrite, IncludeStatistics: true}
+		result, err := backup.New(target, dom).Restore(ctx, bytes.NewReader(archive.Bytes()), restoreParam)
+		So(err, ShouldBeNil)
+		So(result.Farms, ShouldResemble, entity.RestoreCount{Updated: 2})
+		So(result.APIStatisticSnapshots, ShouldResemble, entity.RestoreCount{Created: 1})
+
+		t.Logf("%d - [%s] : %s", 10, "P", "Success skip snapshot already restored")
+		result, err = backup.New(target, dom).Restore(ctx, bytes.NewReader(archive.Bytes()), restoreParam)
+		So(err, ShouldBeNil)
+		So(result.APIStatisticSnapshots, ShouldResemble, entity.RestoreCount{Skipped: 1})
+
+		t.Logf("%d - [%s] : %s", 11, "N", "Failed tampered archive")
+		_, err = backup.New(target, dom).Restore(ctx, bytes.NewReader(tamper(archive.Bytes(), entity.BackupFileFarms)), entity.RestoreParam{})
+		So(err, ShouldWrap, entity.ErrorBackupChecksumMismatch)
+
+		t.Logf("%d - [%s] : %s", 12, "N", "Failed truncated archive")
+		_, err = backup.New(target, dom).Restore(ctx, bytes.NewReader(archive.Bytes()[:archive.Len()/2]), entity.RestoreParam{})
+		So(err, ShouldWrap, entity.ErrorBackupInvalid)
+
+		t.Logf("%d - [%s] : %s", 13, "N", "Failed archive with unknown entry")
+		entries := readEntries(archive.Bytes())
+		_, err = backup.New(target, dom).Restore(ctx, bytes.NewReader(writeEntries(append(entries, archiveEntry{name: "../farms.json", raw: []byte("[]")}))), entity.RestoreParam{})
+		So(err, ShouldWrap, entity.ErrorBackupInvalid)
+		So(err.Error(), ShouldContainSubstring, "unknown entry")
+
+		t.Logf("%d - [%s] : %s", 14, "N", "Failed archive with duplicate entry")
+		_, err = backup.New(target, dom).Restore(ctx, bytes.NewReader(writeEntries(append(entries, entries[1]))), entity.RestoreParam{})
+		So(err, ShouldWrap, entity.ErrorBackupInvalid)
+		So(err.Error(), ShouldContainSubstring, "duplicate entry")
+
+		t.Logf("%d - [%s] : %s", 15, "N", "Failed archive with farm rejected by farm validation")
+		invalid := replaceFile(entries, entity.BackupFileFarms, `[{"id":"farm 1","name":"alpha","description":"first"}]`)
+		_, err = backup.New(openDB(t, "target.db"), dom).Restore(ctx, bytes.NewReader(writeEntries(invalid)), entity.RestoreParam{})
+		So(err, ShouldWrap, entity.ErrorBackupInvalid)
+		So(err, ShouldWrap, entity.ErrorFarmIDInvalidFormat)
+
+		t.Logf("%d - [%s] : %s", 16, "N", "Failed archive with pond rejected by pond validation")
+		invalid = replaceFile(entries, entity.BackupFilePonds, `[{"id":"pond-1","farm_id":"farm-1","name":"","description":"first"}]`)
+		_, err = backup.New(openDB(t, "target.db"), dom).Restore(ctx, bytes.NewReader(writeEntries(invalid)), entity.RestoreParam{})
+		So(err, ShouldWrap, entity.ErrorBackupInvalid)
+		So(err, ShouldWrap, entity.ErrorPondNameRequired)
+	})
+}
+
+// tamper return copy of archive with one byte of file name changed
+func tamper(archive []byte, name string) []byte {
+	gr, err := gzip.NewReader(bytes.NewReader(archive))
+	So(err, ShouldBeNil)
+	tr := tar.NewReader(gr)
+
+	var out bytes.Buffer
+	gw := gzip.NewWriter(&out)
+	tw := tar.NewWriter(gw)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		So(err, ShouldBeNil)
+
+		raw, err := io.ReadAll(tr)
+		So(err, ShouldBeNil)
+		if header.Name == name {
+			raw[len(raw)/2]++
+		}
+
+		So(tw.WriteHeader(header), ShouldBeNil)
+		_, err = tw.Write(raw)
+		So(err, ShouldBeNil)
+	}
+	So(tw.Close(), ShouldBeNil)
+	So(gw.Close(), ShouldBeNil)
+
+	return out.Bytes()
+}
+
+type archiveEntry struct {
+	name string
+	raw  []byte
+}
+
+// readEntries return every file of archive in order
+func readEntries(archive []byte) (entries []archiveEntry) {
+	gr, err := gzip.NewReader(bytes.NewReader(archive))
+	So(err, ShouldBeNil)
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		So(err, ShouldBeNil)
+
+		raw, err := io.ReadAll(tr)
+		So(err, ShouldBeNil)
+		entries = append(entries, archiveEntry{name: header.Name, raw: raw})
+	}
+
+	return entries
+}
+
+func writeEntries(entries []archiveEntry) []byte {
+	var out bytes.Buffer
+	gw := gzip.NewWriter(&out)
+	tw := tar.NewWriter(gw)
+	for _, entry := range entries {
+		So(tw.WriteHeader(&tar.Header{Name: entry.name, Mode: 0644, Size: int64(len(entry.raw))}), ShouldBeNil)
+		_, err := tw.Write(entry.raw)
+		So(err, ShouldBeNil)
+	}
+	So(tw.Close(), ShouldBeNil)
+	So(gw.Close(), ShouldBeNil)
+
+	return out.Bytes()
+}
+
+// replaceFile return copy of entries with content of file name replaced, its checksum in manifest is updated
+func replaceFile(entries []archiveEntry, name string, content string) []archiveEntry {
+	replaced := make([]archiveEntry, len(entries))
+	copy(replaced, entries)
+
+	var manifest entity.BackupManifest
+	So(json.Unmarshal(replaced[0].raw, &manifest), ShouldBeNil)
+	for i, file := range manifest.Files {
+		if file.Name == name {
+			manifest.Files[i].Size = int64(len(content))
+			manifest.Files[i].SHA256 = backup.ArchiveChecksum([]byte(content))
+		}
+	}
+	raw, err := json.Marshal(manifest)
+	So(err, ShouldBeNil)
+	replaced[0].raw = raw
+
+	for i := range replaced {
+		if replaced[i].name == name {
+			replaced[i].raw = []byte(content)
+		}
+	}
+
+	return replaced
+}
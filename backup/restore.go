@@ -0,0 +1,340 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/alvinatthariq/farmsvc-go/entity"
+	"github.com/alvinatthariq/farmsvc-go/migration"
+
+	"gorm.io/gorm"
+)
+
+// restoreBatchSize is how many records are looked up & written at once on Restore
+const restoreBatchSize = 500
+
+// existingRecord is id & update time of farm or pond already in database
+type existingRecord struct {
+	ID        string
+	UpdatedAt time.Time
+}
+
+// Restore load archive of r into database in one transaction, nothing is written when it fail.
+// Soft deleted farm & pond and their timestamps are restored as they were backed up
+func (b *Backup) Restore(ctx context.Context, r io.Reader, param entity.RestoreParam) (result entity.RestoreResult, err error) {
+	if param.Mode == "" {
+		param.Mode = entity.RestoreModeEmpty
+	}
+	if param.Conflict == "" {
+		param.Conflict = entity.RestoreConflictFail
+	}
+
+	switch param.Mode {
+	case entity.RestoreModeEmpty, entity.RestoreModeMerge:
+	default:
+		return result, entity.ErrorRestoreModeInvalid
+	}
+	switch param.Conflict {
+	case entity.RestoreConflictFail, entity.RestoreConflictSkip, entity.RestoreConflictOverwrite, entity.RestoreConflictNewer:
+	default:
+		return result, entity.ErrorRestoreConflictInvalid
+	}
+
+	manifest, files, err := readArchive(r)
+	if err != nil {
+		return result, err
+	}
+	result.Manifest = manifest
+
+	schemaVersion, err := migration.New(b.gorm.WithContext(ctx)).Current()
+	if err != nil {
+		return result, err
+	} else if manifest.SchemaVersion > schemaVersion {
+		return result, fmt.Errorf("%w : archive %d, database %d", entity.ErrorBackupSchemaNewer, manifest.SchemaVersion, schemaVersion)
+	}
+
+	var (
+		farmRecords []farmRecord
+		pondRecords []pondRecord
+		snapshots   []entity.APIStatisticSnapshot
+	)
+	if err := decodeFile(files, entity.BackupFileFarms, &farmRecords); err != nil {
+		return result, err
+	}
+	if err := decodeFile(files, entity.BackupFilePonds, &pondRecords); err != nil {
+		return result, err
+	}
+	if param.IncludeStatistics && manifest.IncludeStatistics {
+		if err := decodeFile(files, entity.BackupFileAPIStatisticSnapshots, &snapshots); err != nil {
+			return result, err
+		}
+	}
+
+	// restored farm & pond must be valid as created through api, archive may be edited or come from older version
+	for _, record := range farmRecords {
+		if err := b.dom.ValidateFarm(record.Farm); err != nil {
+			return result, fmt.Errorf("%w : farm %q : %w", entity.ErrorBackupInvalid, record.ID, err)
+		}
+	}
+	for _, record := range pondRecords {
+		if err := b.dom.ValidatePond(record.Pond); err != nil {
+			return result, fmt.Errorf("%w : pond %q : %w", entity.ErrorBackupInvalid, record.ID, err)
+		}
+	}
+
+	var updatedFarmIDs, updatedPondIDs []string
+	err = b.gorm.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if param.Mode == entity.RestoreModeEmpty {
+			var farmCount, pondCount int64
+			if err := tx.Model(&entity.Farm{}).Count(&farmCount).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&entity.Pond{}).Count(&pondCount).Error; err != nil {
+				return err
+			}
+			if farmCount > 0 || pondCount > 0 {
+				return fmt.Errorf("%w : %d farms, %d ponds", entity.ErrorRestoreDatabaseNotEmpty, farmCount, pondCount)
+			}
+		}
+
+		farms := make([]entity.Farm, 0, len(farmRecords))
+		for _, record := range farmRecords {
+			farm := record.Farm
+			farm.DeletedAt, farm.IsDeleted = softDelete(record.DeletedAt, record.IsDeleted)
+			farms = append(farms, farm)
+		}
+		farmIDs := map[string]bool{}
+		for start := 0; start < len(farms); start += restoreBatchSize {
+			batch := farms[start:min(start+restoreBatchSize, len(farms))]
+			ids := make([]string, 0, len(batch))
+			for _, farm := range batch {
+				ids = append(ids, farm.ID)
+				farmIDs[farm.ID] = true
+			}
+
+			var creates []entity.Farm
+			err := restoreBatch(tx, &entity.Farm{}, ids, param.Conflict, &result.Farms, func(i int, existing *existingRecord) (update bool, err error) {
+				farm := batch[i]
+				if existing == nil {
+					creates = append(creates, farm)
+					return false, nil
+				}
+				if !resolveConflict(param.Conflict, farm.UpdatedAt, existing.UpdatedAt) {
+					return false, nil
+				}
+
+				updatedFarmIDs = append(updatedFarmIDs, farm.ID)
+				return true, tx.Model(&entity.Farm{}).Where("id = ?", farm.ID).UpdateColumns(map[string]interface{}{
+					"name":        farm.Name,
+					"description": farm.Description,
+					"created_at":  farm.CreatedAt,
+					"updated_at":  farm.UpdatedAt,
+					"deleted_at":  farm.DeletedAt,
+					"is_deleted":  farm.IsDeleted,
+				}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("farm : %w", err)
+			}
+			if len(creates) > 0 {
+				if err := tx.CreateInBatches(creates, restoreBatchSize).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		ponds := make([]entity.Pond, 0, len(pondRecords))
+		var missingFarmIDs []string
+		for _, record := range pondRecords {
+			pond := record.Pond
+			pond.DeletedAt, pond.IsDeleted = softDelete(record.DeletedAt, record.IsDeleted)
+			ponds = append(ponds, pond)
+			if !farmIDs[pond.FarmID] {
+				farmIDs[pond.FarmID] = true
+				missingFarmIDs = append(missingFarmIDs, pond.FarmID)
+			}
+		}
+
+		// farm of pond not in archive must already exist in database, backup of some farms only hold ponds of those farms
+		if len(missingFarmIDs) > 0 {
+			var found []string
+			if err := tx.Model(&entity.Farm{}).Where("id IN ?", missingFarmIDs).Pluck("id", &found).Error; err != nil {
+				return err
+			}
+			if len(found) < len(missingFarmIDs) {
+				foundIDs := map[string]bool{}
+				for _, id := range found {
+					foundIDs[id] = true
+				}
+				for _, id := range missingFarmIDs {
+					if !foundIDs[id] {
+						return fmt.Errorf("%w : %s", entity.ErrorRestorePondFarmNotFound, id)
+					}
+				}
+			}
+		}
+
+		for start := 0; start < len(ponds); start += restoreBatchSize {
+			batch := ponds[start:min(start+restoreBatchSize, len(ponds))]
+			ids := make([]string, 0, len(batch))
+			for _, pond := range batch {
+				ids = append(ids, pond.ID)
+			}
+
+			var creates []entity.Pond
+			err := restoreBatch(tx, &entity.Pond{}, ids, param.Conflict, &result.Ponds, func(i int, existing *existingRecord) (update bool, err error) {
+				pond := batch[i]
+				if existing == nil {
+					creates = append(creates, pond)
+					return false, nil
+				}
+				if !resolveConflict(param.Conflict, pond.UpdatedAt, existing.UpdatedAt) {
+					return false, nil
+				}
+
+				updatedPondIDs = append(updatedPondIDs, pond.ID)
+				return true, tx.Model(&entity.Pond{}).Where("id = ?", pond.ID).UpdateColumns(map[string]interface{}{
+					"farm_id":     pond.FarmID,
+					"name":        pond.Name,
+					"description": pond.Description,
+					"created_at":  pond.CreatedAt,
+					"updated_at":  pond.UpdatedAt,
+					"deleted_at":  pond.DeletedAt,
+					"is_deleted":  pond.IsDeleted,
+				}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("pond : %w", err)
+			}
+			if len(creates) > 0 {
+				if err := tx.CreateInBatches(creates, restoreBatchSize).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		return restoreSnapshots(tx, snapshots, &result.APIStatisticSnapshots)
+	})
+	if err != nil {
+		return result, err
+	}
+
+	b.dom.InvalidateCache(ctx, entity.ResourceFarm, updatedFarmIDs)
+	b.dom.InvalidateCache(ctx, entity.ResourcePond, updatedPondIDs)
+
+	return result, nil
+}
+
+// restoreBatch look up which ids of model already exist then call apply for every id in order,
+// existing is nil for id not in database. Existing id abort the restore on RestoreConflictFail
+func restoreBatch(tx *gorm.DB, model interface{}, ids []string, conflict string, count *entity.RestoreCount, apply func(i int, existing *existingRecord) (update bool, err error)) error {
+	var existingRecords []existingRecord
+	if err := tx.Model(model).Select("id, updated_at").Where("id IN ?", ids).Find(&existingRecords).Error; err != nil {
+		return err
+	}
+	existing := map[string]*existingRecord{}
+	for i := range existingRecords {
+		existing[existingRecords[i].ID] = &existingRecords[i]
+	}
+
+	for i, id := range ids {
+		record := existing[id]
+		if record != nil && conflict == entity.RestoreConflictFail {
+			return fmt.Errorf("%w : %s", entity.ErrorRestoreConflict, id)
+		}
+
+		update, err := apply(i, record)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case record == nil:
+			count.Created++
+		case update:
+			count.Updated++
+		default:
+			count.Skipped++
+		}
+	}
+
+	return nil
+}
+
+// resolveConflict return true when existing record is replaced by the one of archive
+func resolveConflict(conflict string, archived time.Time, existing time.Time) bool {
+	switch conflict {
+	case entity.RestoreConflictOverwrite:
+		return true
+	case entity.RestoreConflictNewer:
+		return archived.After(existing)
+	default:
+		return false
+	}
+}
+
+// restoreSnapshots insert snapshots not in database yet, snapshot is identified by path, snapshot time & reason
+// since its id is generated by the database it was taken on
+func restoreSnapshots(tx *gorm.DB, snapshots []entity.APIStatisticSnapshot, count *entity.RestoreCount) error {
+	snapshotKey := func(snapshot entity.APIStatisticSnapshot) string {
+		return fmt.Sprintf("%s|%d|%s", snapshot.Path, snapshot.SnapshotAt.UnixNano(), snapshot.Reason)
+	}
+
+	for start := 0; start < len(snapshots); start += restoreBatchSize {
+		batch := snapshots[start:min(start+restoreBatchSize, len(snapshots))]
+		from, to := batch[0].SnapshotAt, batch[0].SnapshotAt
+		for _, snapshot := range batch {
+			if snapshot.SnapshotAt.Before(from) {
+				from = snapshot.SnapshotAt
+			}
+			if snapshot.SnapshotAt.After(to) {
+				to = snapshot.SnapshotAt
+			}
+		}
+
+		var existingSnapshots []entity.APIStatisticSnapshot
+		if err := tx.Where("snapshot_at BETWEEN ? AND ?", from, to).Find(&existingSnapshots).Error; err != nil {
+			return err
+		}
+		existing := map[string]bool{}
+		for _, snapshot := range existingSnapshots {
+			existing[snapshotKey(snapshot)] = true
+		}
+
+		var creates []entity.APIStatisticSnapshot
+		for _, snapshot := range batch {
+			key := snapshotKey(snapshot)
+			if existing[key] {
+				count.Skipped++
+				continue
+			}
+
+			existing[key] = true
+			snapshot.ID = 0
+			creates = append(creates, snapshot)
+		}
+		if len(creates) > 0 {
+			if err := tx.CreateInBatches(creates, restoreBatchSize).Error; err != nil {
+				return err
+			}
+			count.Created += len(creates)
+		}
+	}
+
+	return nil
+}
+
+func decodeFile(files map[string][]byte, name string, v interface{}) error {
+	raw, ok := files[name]
+	if !ok {
+		return fmt.Errorf("%w : %s not found", entity.ErrorBackupInvalid, name)
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("%w : %s : %v", entity.ErrorBackupInvalid, name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alvinatthariq/farmsvc-go/backup"
+	"github.com/alvinatthariq/farmsvc-go/entity"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWriteBackup(t *testing.T) {
+	Convey("TestWriteBackup", t, FailureHalts, func() {
+		setCommandConfig(t, "true")
+		InitApp(io.Discard)
+		InitCommandDomain(true)
+		defer CloseCommandDomain()
+		So(dbgorm.Create(&entity.Farm{ID: "farm-1", Name: "alpha", Description: "first"}).Error, ShouldBeNil)
+
+		dir := t.TempDir()
+		output := filepath.Join(dir, "backup.tar.gz")
+
+		t.Log("1 - [P] : Success write archive file, checksum of written file returned")
+		manifest, checksum, err := writeBackup(backup.New(dbgorm, dom), entity.BackupParam{}, output)
+		So(err, ShouldBeNil)
+		So(manifest.Files[0].Count, ShouldEqual, 1)
+		raw, err := os.ReadFile(output)
+		So(err, ShouldBeNil)
+		So(checksum, ShouldEqual, backup.ArchiveChecksum(raw))
+		entries, err := os.ReadDir(dir)
+		So(err, ShouldBeNil)
+		So(entries, ShouldHaveLength, 1)
+
+		t.Log("2 - [N] : Failed backup keep previous file, temporary file removed")
+		sqlDB, err := dbgorm.DB()
+		So(err, ShouldBeNil)
+		So(sqlDB.Close(), ShouldBeNil)
+		_, _, err = writeBackup(backup.New(dbgorm, dom), entity.BackupParam{}, output)
+		So(err, ShouldNotBeNil)
+		current, err := os.ReadFile(output)
+		So(err, ShouldBeNil)
+		So(current, ShouldResemble, raw)
+		entries, err = os.ReadDir(dir)
+		So(err, ShouldBeNil)
+		So(entries, ShouldHaveLength, 1)
+	})
+}
@@ -1,47 +1,445 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/alvinatthariq/farmsvc-go/controllers"
+	"github.com/alvinatthariq/farmsvc-go/domain"
+	"github.com/alvinatthariq/farmsvc-go/entity"
+	"github.com/alvinatthariq/farmsvc-go/logger"
+	"github.com/alvinatthariq/farmsvc-go/repository"
+	"github.com/alvinatthariq/farmsvc-go/telemetry"
+
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	MySQL MySQLConfig `mapstructure:"mysql"`
-	Redis RedisConfig `mapstructure:"redis"`
-	Port  string      `mapstructure:"port"`
+	Database DatabaseConfig `mapstructure:"database"`
+	Redis    RedisConfig    `mapstructure:"redis"`
+	Port     string         `mapstructure:"port"`
+	Server   ServerConfig   `mapstructure:"server"`
+	ID       IDConfig       `mapstructure:"id"`
+	Admin    AdminConfig    `mapstructure:"admin"`
+	Log      LogConfig      `mapstructure:"log"`
+	Tracing  TracingConfig  `mapstructure:"tracing"`
+
+	// Deprecated: MySQL is kept for config written before database driver selection, use Database
+	MySQL DatabaseConfig `mapstructure:"mysql"`
+
+	// file is path of config file loaded, empty when config only come from defaults & environment
+	file string
 }
 
-type MySQLConfig struct {
+type ServerConfig struct {
+	// ReadTimeout, ReadHeaderTimeout, WriteTimeout & IdleTimeout of http server in duration format e.g. "15s"
+	ReadTimeout       time.Duration `mapstructure:"read_timeout"`
+	ReadHeaderTimeout time.Duration `mapstructure:"read_header_timeout"`
+	WriteTimeout      time.Duration `mapstructure:"write_timeout"`
+	IdleTimeout       time.Duration `mapstructure:"idle_timeout"`
+	// DrainDelay is how long /readyz report shutting down before server stop accepting connections,
+	// so load balancer stop routing to the server first
+	DrainDelay time.Duration `mapstructure:"drain_delay"`
+	// GracePeriod is max wait for in flight requests on shutdown
+	GracePeriod time.Duration `mapstructure:"grace_period"`
+	// RequestTimeout is max time a request wait for database & redis, "0s" disable it
+	RequestTimeout time.Duration `mapstructure:"request_timeout"`
+	// RouteTimeouts override RequestTimeout per route, keyed by method & route template e.g. "GET /v1/farm"
+	RouteTimeouts map[string]time.Duration `mapstructure:"route_timeouts"`
+	// TrustedProxies is address or CIDR of load balancers & proxies in front of the server, X-Forwarded-For
+	// & X-Client-ID header is only honored on request coming from them
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+}
+
+type DatabaseConfig struct {
+	// Driver is one of mysql, postgres or sqlite, default to mysql
+	Driver           string `mapstructure:"driver"`
 	ConnectionString string `mapstructure:"connection_string"`
+	// AutoMigrate apply pending migrations on server start, otherwise run migrate subcommand
+	AutoMigrate bool `mapstructure:"auto_migrate"`
+
+	// MaxOpenConns & MaxIdleConns limit connection pool, ConnMaxLifetime & ConnMaxIdleTime
+	// is how long a connection is reused or kept idle, zero is unlimited
+	MaxOpenConns    int           `mapstructure:"max_open_conns"`
+	MaxIdleConns    int           `mapstructure:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `mapstructure:"conn_max_idle_time"`
+
+	// ConnectRetries is how many times connecting on start is retried, ConnectRetryBackoff is wait
+	// before first retry, doubled every retry up to ConnectRetryMaxBackoff
+	ConnectRetries         int           `mapstructure:"connect_retries"`
+	ConnectRetryBackoff    time.Duration `mapstructure:"connect_retry_backoff"`
+	ConnectRetryMaxBackoff time.Duration `mapstructure:"connect_retry_max_backoff"`
+
+	// RetryAttempts is max attempts of read & idempotent write failed by deadlock or lost connection,
+	// RetryBackoff is wait before second attempt, doubled every attempt
+	RetryAttempts int           `mapstructure:"retry_attempts"`
+	RetryBackoff  time.Duration `mapstructure:"retry_backoff"`
+
+	// Replicas is connection string of read replicas serving farm & pond list and report queries, they use
+	// the same driver & pool setting. Replica failing a query is skipped for ReplicaRecheckInterval
+	Replicas               []string      `mapstructure:"replicas"`
+	ReplicaRecheckInterval time.Duration `mapstructure:"replica_recheck_interval"`
 }
 
 type RedisConfig struct {
 	Host     string `mapstructure:"host"`
 	Password string `mapstructure:"password"`
+	// KeyPrefix is prepended to every key, so the service can share redis with others
+	KeyPrefix string          `mapstructure:"key_prefix"`
+	Cache     CacheConfig     `mapstructure:"cache"`
+	Statistic StatisticConfig `mapstructure:"statistic"`
+	// CircuitBreaker guard statistic & cache calls, farm & pond do not depend on redis
+	CircuitBreaker CircuitBreakerConfig `mapstructure:"circuit_breaker"`
+}
+
+type CircuitBreakerConfig struct {
+	// FailureThreshold is consecutive failed calls which open the circuit
+	FailureThreshold int `mapstructure:"failure_threshold"`
+	// OpenTimeout is how long redis is skipped before trying it again, in duration format e.g. "30s"
+	OpenTimeout time.Duration `mapstructure:"open_timeout"`
+}
+
+type StatisticConfig struct {
+	// BufferSize is max api statistic events waiting to be written to redis, event is dropped when full
+	BufferSize int `mapstructure:"buffer_size"`
+	// BatchSize is max events written in one pipeline
+	BatchSize int `mapstructure:"batch_size"`
+	// FlushInterval is max wait before buffered events are written, in duration format e.g. "1s"
+	FlushInterval time.Duration `mapstructure:"flush_interval"`
+	// SnapshotInterval is how often api statistic is copied to sql, "-1s" disable it
+	SnapshotInterval time.Duration `mapstructure:"snapshot_interval"`
+	// SampleRatio is fraction of requests recorded in api statistic, above 0 up to 1
+	SampleRatio float64 `mapstructure:"sample_ratio"`
+}
+
+type CacheConfig struct {
+	// FarmTTL & PondTTL in duration format e.g. "5m", "0s" disable the cache
+	FarmTTL time.Duration `mapstructure:"farm_ttl"`
+	PondTTL time.Duration `mapstructure:"pond_ttl"`
+}
+
+type IDConfig struct {
+	// Pattern is regular expression used to validate client supplied id
+	Pattern string `mapstructure:"pattern"`
+}
+
+type AdminConfig struct {
+	// Token is required in X-Admin-Token header of admin endpoints, empty disable them
+	Token string `mapstructure:"token"`
+}
+
+type LogConfig struct {
+	// Level is one of debug, info, warn or error, default to info
+	Level string `mapstructure:"level"`
+	// Format is json or text, default to json
+	Format string `mapstructure:"format"`
 }
 
+type TracingConfig struct {
+	// Exporter is otlp, stdout or none, default to none
+	Exporter string `mapstructure:"exporter"`
+	// OTLPEndpoint is host:port of OTLP/HTTP collector
+	OTLPEndpoint string `mapstructure:"otlp_endpoint"`
+	OTLPInsecure bool   `mapstructure:"otlp_insecure"`
+	ServiceName  string `mapstructure:"service_name"`
+	// SampleRatio is fraction of new trace sampled, from 0 to 1
+	SampleRatio float64 `mapstructure:"sample_ratio"`
+}
+
+const (
+	// EnvPrefix prefix environment variable overriding config, key is upper cased and dot replaced by underscore,
+	// e.g. FARMSVC_DATABASE_CONNECTION_STRING override database.connection_string
+	EnvPrefix = "FARMSVC"
+	// EnvFileSuffix mark environment variable holding path of file containing the value,
+	// e.g. FARMSVC_DATABASE_CONNECTION_STRING_FILE=/run/secrets/dsn
+	EnvFileSuffix = "_FILE"
+	// EnvConfigFile is path of config file, json, yaml or toml chosen by extension
+	EnvConfigFile = EnvPrefix + "_CONFIG_FILE"
+)
+
 var AppConfig *Config
 
+// LoadAppConfig load config file then environment overrides into AppConfig, exit when config is invalid
 func LoadAppConfig() {
 	log.Println("Loading Server Configurations...")
-	viper.AddConfigPath(".")
 
-	env := os.Getenv("env")
-	if env == "docker" {
-		viper.SetConfigName("config-docker")
+	config, err := loadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := config.Validate(); err != nil {
+		log.Fatalf("Invalid Config :\n%v", err)
+	}
+
+	AppConfig = config
+}
+
+func loadConfig() (config *Config, err error) {
+	// fresh instance every load, so a reload never see setting of a previous load
+	v := viper.New()
+
+	if path := os.Getenv(EnvConfigFile); path != "" {
+		v.SetConfigFile(path)
+	} else {
+		// config.json, config.yaml or config.toml in working directory
+		v.AddConfigPath(".")
+		if os.Getenv("env") == "docker" {
+			v.SetConfigName("config-docker")
+		} else {
+			v.SetConfigName("config")
+		}
+	}
+
+	v.SetDefault("port", 8080)
+	v.SetDefault("server.read_timeout", 15*time.Second)
+	v.SetDefault("server.read_header_timeout", 5*time.Second)
+	v.SetDefault("server.write_timeout", 30*time.Second)
+	v.SetDefault("server.idle_timeout", 60*time.Second)
+	v.SetDefault("server.grace_period", 15*time.Second)
+	v.SetDefault("server.request_timeout", 10*time.Second)
+	v.SetDefault("database.max_open_conns", 25)
+	v.SetDefault("database.max_idle_conns", 10)
+	v.SetDefault("database.conn_max_lifetime", 30*time.Minute)
+	v.SetDefault("database.conn_max_idle_time", 5*time.Minute)
+	v.SetDefault("database.connect_retries", 10)
+	v.SetDefault("database.connect_retry_backoff", time.Second)
+	v.SetDefault("database.connect_retry_max_backoff", 30*time.Second)
+	v.SetDefault("database.retry_attempts", domain.DefaultDatabaseRetryAttempts)
+	v.SetDefault("database.retry_backoff", domain.DefaultDatabaseRetryBackoff)
+	v.SetDefault("database.replica_recheck_interval", repository.DefaultReplicaRecheckInterval)
+	v.SetDefault("log.level", "info")
+	v.SetDefault("log.format", logger.FormatJSON)
+	v.SetDefault("tracing.exporter", telemetry.ExporterNone)
+	v.SetDefault("tracing.otlp_endpoint", telemetry.DefaultOTLPEndpoint)
+	v.SetDefault("tracing.service_name", telemetry.DefaultServiceName)
+	v.SetDefault("tracing.sample_ratio", 1.0)
+	v.SetDefault("redis.key_prefix", "farmsvc:")
+	v.SetDefault("redis.circuit_breaker.failure_threshold", domain.DefaultRedisFailureThreshold)
+	v.SetDefault("redis.circuit_breaker.open_timeout", domain.DefaultRedisOpenTimeout)
+	v.SetDefault("redis.cache.farm_ttl", domain.DefaultFarmCacheTTL)
+	v.SetDefault("redis.cache.pond_ttl", domain.DefaultPondCacheTTL)
+	v.SetDefault("redis.statistic.buffer_size", domain.DefaultStatisticBufferSize)
+	v.SetDefault("redis.statistic.batch_size", domain.DefaultStatisticBatchSize)
+	v.SetDefault("redis.statistic.flush_interval", domain.DefaultStatisticFlushInterval)
+	v.SetDefault("redis.statistic.snapshot_interval", domain.DefaultStatisticSnapshotInterval)
+	v.SetDefault("redis.statistic.sample_ratio", 1.0)
+
+	// every setting can come from environment, config file is optional then
+	err = v.ReadInConfig()
+	var notFoundErr viper.ConfigFileNotFoundError
+	if errors.As(err, &notFoundErr) {
+		log.Println("Config file not found, using defaults & environment")
+	} else if err != nil {
+		return nil, fmt.Errorf("Cannot read config file : %w", err)
 	} else {
-		viper.SetConfigName("config")
+		log.Println("Config file loaded", v.ConfigFileUsed())
+	}
+
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	for _, key := range configKeys(reflect.TypeOf(Config{}), "") {
+		// viper only unmarshal environment of known key
+		if err := v.BindEnv(key); err != nil {
+			return nil, err
+		}
+		if err := loadSecretFile(v, key); err != nil {
+			return nil, err
+		}
 	}
 
-	viper.SetConfigType("json")
-	err := viper.ReadInConfig()
+	err = v.Unmarshal(&config, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		stringToMapHookFunc(",", "="),
+	)))
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("Cannot decode config : %w", err)
+	}
+
+	// pool & retry setting of database section is kept, deprecated mysql section only has connection setting
+	if config.Database.ConnectionString == "" {
+		config.Database.Driver = config.MySQL.Driver
+		config.Database.ConnectionString = config.MySQL.ConnectionString
+		config.Database.AutoMigrate = config.MySQL.AutoMigrate
+	}
+
+	config.file = v.ConfigFileUsed()
+
+	if config.ID.Pattern == "" {
+		config.ID.Pattern = entity.DefaultIDPattern
 	}
-	err = viper.Unmarshal(&AppConfig)
+
+	return config, nil
+}
+
+// Validate return every invalid setting of config, joined
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(key string, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s (%s) %s", key, envName(key), fmt.Sprintf(format, args...)))
+	}
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		invalid("port", "must be number from 1 to 65535, got %q", c.Port)
+	}
+
+	switch c.Database.Driver {
+	case "", repository.DriverMySQL, repository.DriverPostgres, repository.DriverSQLite:
+	default:
+		invalid("database.driver", "must be mysql, postgres or sqlite, got %q", c.Database.Driver)
+	}
+	if strings.TrimSpace(c.Database.ConnectionString) == "" {
+		invalid("database.connection_string", "is required")
+	}
+	for i, replica := range c.Database.Replicas {
+		if strings.TrimSpace(replica) == "" {
+			invalid("database.replicas", "item %d is empty", i)
+		}
+	}
+	if c.Database.MaxOpenConns > 0 && c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		invalid("database.max_idle_conns", "must not exceed max_open_conns %d, got %d", c.Database.MaxOpenConns, c.Database.MaxIdleConns)
+	}
+	if c.Database.ConnectRetries < 0 {
+		invalid("database.connect_retries", "must not be negative, got %d", c.Database.ConnectRetries)
+	}
+
+	if strings.TrimSpace(c.Redis.Host) == "" {
+		invalid("redis.host", "is required")
+	}
+	if c.Redis.Statistic.SampleRatio <= 0 || c.Redis.Statistic.SampleRatio > 1 {
+		invalid("redis.statistic.sample_ratio", "must be above 0 up to 1, got %v", c.Redis.Statistic.SampleRatio)
+	}
+
+	for key, timeout := range map[string]time.Duration{
+		"server.read_timeout":        c.Server.ReadTimeout,
+		"server.read_header_timeout": c.Server.ReadHeaderTimeout,
+		"server.write_timeout":       c.Server.WriteTimeout,
+		"server.idle_timeout":        c.Server.IdleTimeout,
+		"server.drain_delay":         c.Server.DrainDelay,
+		"server.request_timeout":     c.Server.RequestTimeout,
+	} {
+		if timeout < 0 {
+			invalid(key, "must not be negative, got %s", timeout)
+		}
+	}
+	// zero would stop the server without waiting for any in flight request
+	if c.Server.GracePeriod <= 0 {
+		invalid("server.grace_period", "must be positive, got %s", c.Server.GracePeriod)
+	}
+	for route, timeout := range c.Server.RouteTimeouts {
+		if len(strings.Fields(route)) != 2 {
+			invalid("server.route_timeouts", "key must be method & route template e.g. \"GET /v1/farm\", got %q", route)
+		} else if timeout < 0 {
+			invalid("server.route_timeouts", "timeout of %q must not be negative, got %s", route, timeout)
+		}
+	}
+
+	if _, err := controllers.ParseTrustedProxies(c.Server.TrustedProxies); err != nil {
+		invalid("server.trusted_proxies", "%v", err)
+	}
+
+	if _, err := regexp.Compile(c.ID.Pattern); err != nil {
+		invalid("id.pattern", "is not valid regular expression : %v", err)
+	}
+
+	if _, err := logger.ParseLevel(c.Log.Level); err != nil {
+		invalid("log.level", "must be debug, info, warn or error, got %q", c.Log.Level)
+	}
+	switch c.Log.Format {
+	case logger.FormatJSON, logger.FormatText:
+	default:
+		invalid("log.format", "must be json or text, got %q", c.Log.Format)
+	}
+
+	switch c.Tracing.Exporter {
+	case "", telemetry.ExporterNone, telemetry.ExporterStdout, telemetry.ExporterOTLP:
+	default:
+		invalid("tracing.exporter", "must be otlp, stdout or none, got %q", c.Tracing.Exporter)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		invalid("tracing.sample_ratio", "must be from 0 to 1, got %v", c.Tracing.SampleRatio)
+	}
+
+	return errors.Join(errs...)
+}
+
+// configKeys return viper key of every field of t, nested struct is flattened with dot
+func configKeys(t reflect.Type, prefix string) (keys []string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := field.Tag.Get("mapstructure")
+		if key == "" {
+			continue
+		}
+		key = prefix + key
+
+		if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Time{}) {
+			keys = append(keys, configKeys(field.Type, key+".")...)
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// envName return environment variable overriding key
+func envName(key string) string {
+	return EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// loadSecretFile set key of v from content of file named by <env>_FILE variable, so secret such as
+// connection string can be mounted as file instead of written into config or environment
+func loadSecretFile(v *viper.Viper, key string) error {
+	name := envName(key) + EnvFileSuffix
+	path := os.Getenv(name)
+	if path == "" {
+		return nil
+	}
+	if os.Getenv(envName(key)) != "" {
+		return fmt.Errorf("Only one of %s and %s can be set", envName(key), name)
+	}
+
+	content, err := os.ReadFile(path)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("Cannot read %s : %w", name, err)
+	}
+	v.Set(key, strings.TrimRight(string(content), "\r\n"))
+
+	return nil
+}
+
+// stringToMapHookFunc decode string such as "GET /v1/farm=5s,GET /v1/pond=5s" into map,
+// so map setting can be overridden by environment
+func stringToMapHookFunc(separator string, keyValueSeparator string) mapstructure.DecodeHookFuncType {
+	return func(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+		if from.Kind() != reflect.String || to.Kind() != reflect.Map {
+			return data, nil
+		}
+
+		raw := data.(string)
+		m := map[string]string{}
+		if strings.TrimSpace(raw) == "" {
+			return m, nil
+		}
+		for _, pair := range strings.Split(raw, separator) {
+			key, value, ok := strings.Cut(pair, keyValueSeparator)
+			if !ok {
+				return nil, fmt.Errorf("%q is not key%svalue", pair, keyValueSeparator)
+			}
+			m[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+
+		return m, nil
 	}
 }
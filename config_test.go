@@ -0,0 +1,229 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testConfigFile = `{
+	"database": {"driver": "sqlite", "connection_string": "file::memory:"},
+	"redis": {"host": "localhost:6379"},
+	"server": {"route_timeouts": {"GET /v1/farm": "3s"}}
+}`
+
+// writeFile write content to file name in dir of test, return its path
+func writeFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	So(os.WriteFile(path, []byte(content), 0o600), ShouldBeNil)
+
+	return path
+}
+
+// loadTestConfig load config from testConfigFile and environment set by env
+func loadTestConfig(t *testing.T, env map[string]string) (*Config, error) {
+	t.Setenv(EnvConfigFile, writeFile(t, "config.json", testConfigFile))
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+
+	return loadConfig()
+}
+
+func TestLoadConfigEnv(t *testing.T) {
+	Convey("TestLoadConfigEnv", t, FailureHalts, func() {
+		testCases := []struct {
+			testID   int
+			testType string
+			testDesc string
+			env      map[string]string
+			check    func(config *Config)
+		}{
+			{
+				testID:   1,
+				testType: "P",
+				testDesc: "Success load config file & defaults",
+				check: func(config *Config) {
+					So(config.Port, ShouldEqual, "8080")
+					So(config.Database.ConnectionString, ShouldEqual, "file::memory:")
+					So(config.Server.GracePeriod, ShouldEqual, 15*time.Second)
+					// viper lower case key of map read from file
+					So(config.Server.RouteTimeouts, ShouldResemble, map[string]time.Duration{"get /v1/farm": 3 * time.Second})
+				},
+			},
+			{
+				testID:   2,
+				testType: "P",
+				testDesc: "Success override top level & nested key",
+				env: map[string]string{
+					"FARMSVC_PORT":                               "9090",
+					"FARMSVC_DATABASE_CONNECTION_STRING":         "file:farm.db",
+					"FARMSVC_REDIS_CIRCUIT_BREAKER_OPEN_TIMEOUT": "45s",
+					"FARMSVC_TRACING_SAMPLE_RATIO":               "0.25",
+				},
+				check: func(config *Config) {
+					So(config.Port, ShouldEqual, "9090")
+					So(config.Database.ConnectionString, ShouldEqual, "file:farm.db")
+					So(config.Redis.CircuitBreaker.OpenTimeout, ShouldEqual, 45*time.Second)
+					So(config.Tracing.SampleRatio, ShouldEqual, 0.25)
+				},
+			},
+			{
+				testID:   3,
+				testType: "P",
+				testDesc: "Success override map & list key",
+				env: map[string]string{
+					"FARMSVC_SERVER_ROUTE_TIMEOUTS":  "GET /v1/farm=5s, GET /v1/api/statistic/history=25s",
+					"FARMSVC_DATABASE_REPLICAS":      "file:replica-1.db,file:replica-2.db",
+					"FARMSVC_SERVER_TRUSTED_PROXIES": "10.0.0.0/8",
+				},
+				check: func(config *Config) {
+					So(config.Server.RouteTimeouts, ShouldResemble, map[string]time.Duration{
+						"GET /v1/farm":                  5 * time.Second,
+						"GET /v1/api/statistic/history": 25 * time.Second,
+					})
+					So(config.Database.Replicas, ShouldResemble, []string{"file:replica-1.db", "file:replica-2.db"})
+					So(config.Server.TrustedProxies, ShouldResemble, []string{"10.0.0.0/8"})
+				},
+			},
+		}
+
+		for _, tc := range testCases {
+			t.Logf("%d - [%s] : %s", tc.testID, tc.testType, tc.testDesc)
+			config, err := loadTestConfig(t, tc.env)
+			So(err, ShouldBeNil)
+			tc.check(config)
+		}
+	})
+}
+
+func TestLoadConfigSecretFile(t *testing.T) {
+	Convey("TestLoadConfigSecretFile", t, FailureHalts, func() {
+		testCases := []struct {
+			testID   int
+			testType string
+			testDesc string
+			content  string
+			path     string
+			env      map[string]string
+			expected string
+			err      string
+		}{
+			{
+				testID:   1,
+				testType: "P",
+				testDesc: "Success read secret, trailing newline trimmed",
+				content:  "root:secret@tcp(db:3306)/farm_db\n",
+				expected: "root:secret@tcp(db:3306)/farm_db",
+			},
+			{
+				testID:   2,
+				testType: "P",
+				testDesc: "Success read secret, windows newline trimmed, inner space kept",
+				content:  "host=db password=p w\r\n",
+				expected: "host=db password=p w",
+			},
+			{
+				testID:   3,
+				testType: "N",
+				testDesc: "Failed read secret, file not exist",
+				path:     filepath.Join(os.TempDir(), "farmsvc-missing-secret"),
+				err:      "Cannot read FARMSVC_DATABASE_CONNECTION_STRING_FILE",
+			},
+			{
+				testID:   4,
+				testType: "N",
+				testDesc: "Failed read secret, environment variable set as well",
+				content:  "from-file",
+				env:      map[string]string{"FARMSVC_DATABASE_CONNECTION_STRING": "from-env"},
+				err:      "Only one of FARMSVC_DATABASE_CONNECTION_STRING and FARMSVC_DATABASE_CONNECTION_STRING_FILE can be set",
+			},
+		}
+
+		for _, tc := range testCases {
+			t.Logf("%d - [%s] : %s", tc.testID, tc.testType, tc.testDesc)
+			path := tc.path
+			if path == "" {
+				path = writeFile(t, "dsn", tc.content)
+			}
+			env := map[string]string{"FARMSVC_DATABASE_CONNECTION_STRING_FILE": path}
+			for key, value := range tc.env {
+				env[key] = value
+			}
+
+			config, err := loadTestConfig(t, env)
+			if tc.err != "" {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, tc.err)
+				continue
+			}
+			So(err, ShouldBeNil)
+			So(config.Database.ConnectionString, ShouldEqual, tc.expected)
+		}
+	})
+}
+
+func TestConfigValidate(t *testing.T) {
+	Convey("TestConfigValidate", t, FailureHalts, func() {
+		testCases := []struct {
+			testID   int
+			testType string
+			testDesc string
+			modify   func(config *Config)
+			key      string
+		}{
+			{testID: 1, testType: "P", testDesc: "Success validate loaded config", modify: func(config *Config) {}},
+			{testID: 2, testType: "N", testDesc: "Failed validate, port is not number", key: "port", modify: func(config *Config) { config.Port = "http" }},
+			{testID: 3, testType: "N", testDesc: "Failed validate, port out of range", key: "port", modify: func(config *Config) { config.Port = "70000" }},
+			{testID: 4, testType: "N", testDesc: "Failed validate, unknown database driver", key: "database.driver", modify: func(config *Config) { config.Database.Driver = "oracle" }},
+			{testID: 5, testType: "N", testDesc: "Failed validate, connection string missing", key: "database.connection_string", modify: func(config *Config) { config.Database.ConnectionString = " " }},
+			{testID: 6, testType: "N", testDesc: "Failed validate, empty replica", key: "database.replicas", modify: func(config *Config) { config.Database.Replicas = []string{"file:replica.db", ""} }},
+			{testID: 7, testType: "N", testDesc: "Failed validate, idle connections above open connections", key: "database.max_idle_conns", modify: func(config *Config) { config.Database.MaxIdleConns = 30 }},
+			{testID: 8, testType: "N", testDesc: "Failed validate, negative connect retries", key: "database.connect_retries", modify: func(config *Config) { config.Database.ConnectRetries = -1 }},
+			{testID: 9, testType: "N", testDesc: "Failed validate, redis host missing", key: "redis.host", modify: func(config *Config) { config.Redis.Host = "" }},
+			{testID: 10, testType: "N", testDesc: "Failed validate, negative server timeout", key: "server.write_timeout", modify: func(config *Config) { config.Server.WriteTimeout = -time.Second }},
+			{testID: 11, testType: "N", testDesc: "Failed validate, zero grace period", key: "server.grace_period", modify: func(config *Config) { config.Server.GracePeriod = 0 }},
+			{testID: 12, testType: "N", testDesc: "Failed validate, route timeout key without method", key: "server.route_timeouts", modify: func(config *Config) {
+				config.Server.RouteTimeouts = map[string]time.Duration{"/v1/farm": time.Second}
+			}},
+			{testID: 13, testType: "N", testDesc: "Failed validate, negative route timeout", key: "server.route_timeouts", modify: func(config *Config) {
+				config.Server.RouteTimeouts = map[string]time.Duration{"GET /v1/farm": -time.Second}
+			}},
+			{testID: 14, testType: "N", testDesc: "Failed validate, invalid trusted proxy", key: "server.trusted_proxies", modify: func(config *Config) { config.Server.TrustedProxies = []string{"10.0.0.0/33"} }},
+			{testID: 15, testType: "N", testDesc: "Failed validate, invalid id pattern", key: "id.pattern", modify: func(config *Config) { config.ID.Pattern = "[a-z" }},
+			{testID: 16, testType: "N", testDesc: "Failed validate, unknown log level", key: "log.level", modify: func(config *Config) { config.Log.Level = "verbose" }},
+			{testID: 17, testType: "N", testDesc: "Failed validate, unknown log format", key: "log.format", modify: func(config *Config) { config.Log.Format = "xml" }},
+			{testID: 18, testType: "N", testDesc: "Failed validate, unknown tracing exporter", key: "tracing.exporter", modify: func(config *Config) { config.Tracing.Exporter = "jaeger" }},
+			{testID: 19, testType: "N", testDesc: "Failed validate, sample ratio above 1", key: "tracing.sample_ratio", modify: func(config *Config) { config.Tracing.SampleRatio = 1.5 }},
+			{testID: 20, testType: "N", testDesc: "Failed validate, zero statistic sample ratio", key: "redis.statistic.sample_ratio", modify: func(config *Config) { config.Redis.Statistic.SampleRatio = 0 }},
+		}
+
+		for _, tc := range testCases {
+			t.Logf("%d - [%s] : %s", tc.testID, tc.testType, tc.testDesc)
+			config, err := loadTestConfig(t, nil)
+			So(err, ShouldBeNil)
+			tc.modify(config)
+
+			err = config.Validate()
+			if tc.key == "" {
+				So(err, ShouldBeNil)
+				continue
+			}
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldStartWith, tc.key+" ("+envName(tc.key)+")")
+		}
+
+		t.Log("21 - [N] : Failed validate, every invalid setting reported")
+		config, err := loadTestConfig(t, nil)
+		So(err, ShouldBeNil)
+		config.Port = ""
+		config.Redis.Host = ""
+		err = config.Validate()
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "port (FARMSVC_PORT)")
+		So(err.Error(), ShouldContainSubstring, "redis.host (FARMSVC_REDIS_HOST)")
+	})
+}
@@ -1,13 +1,230 @@
 package controllers
 
-import "net/http"
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alvinatthariq/farmsvc-go/entity"
+
+	"github.com/gorilla/mux"
+)
 
 func (c *controller) GetAPIStatistic(w http.ResponseWriter, r *http.Request) {
-	apiStatistics, err := c.domain.GetAPIStatistic()
+	// get url query param
+	urlVal := r.URL.Query()
+
+	param := entity.APIStatisticParam{
+		Granularity: urlVal.Get("granularity"),
+	}
+
+	// from & to in RFC3339 format
+	var err error
+	if from := urlVal.Get("from"); from != "" {
+		param.From, err = time.Parse(time.RFC3339, from)
+		if err != nil {
+			httpRespError(w, r, fmt.Errorf("Invalid From : %w", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if to := urlVal.Get("to"); to != "" {
+		param.To, err = time.Parse(time.RFC3339, to)
+		if err != nil {
+			httpRespError(w, r, fmt.Errorf("Invalid To : %w", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	apiStatistics, err := c.domain.GetAPIStatistic(r.Context(), param)
+	if err != nil {
+		switch err {
+		case
+			entity.ErrorAPIStatisticGranularityInvalid,
+			entity.ErrorAPIStatisticRangeInvalid,
+			entity.ErrorAPIStatisticRangeTooLarge:
+			httpRespError(w, r, err, http.StatusBadRequest)
+			return
+		default:
+			httpRespError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	cacheStatistics, err := c.domain.GetCacheStatistic(r.Context())
+	if err != nil {
+		httpRespError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	httpRespSuccess(w, r, http.StatusOK, entity.HTTPAPIStatisticsData{
+		APIStatistics:   apiStatistics,
+		CacheStatistics: cacheStatistics,
+		BufferStatistic: c.domain.GetAPIStatisticBufferStatistic(),
+
+		RedisCircuitBreaker: c.domain.GetRedisCircuitBreakerStatistic(),
+	})
+}
+
+func (c *controller) GetClientStatistic(w http.ResponseWriter, r *http.Request) {
+	// top, default to 20
+	top, err := parseNonNegativeQuery("Top", r.URL.Query().Get("top"))
+	if err != nil {
+		httpRespError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	clientStatistic, err := c.domain.GetClientStatistic(r.Context(), top)
 	if err != nil {
 		httpRespError(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	httpRespSuccess(w, r, http.StatusOK, apiStatistics)
+	httpRespSuccess(w, r, http.StatusOK, clientStatistic)
+}
+
+func (c *controller) GetAPIStatisticHistory(w http.ResponseWriter, r *http.Request) {
+	// get url query param
+	urlVal := r.URL.Query()
+
+	// json default to 100 per page, csv export default to max 10000 rows, page it for more
+	format := urlVal.Get("format")
+	limit, err := parseNonNegativeQuery("Limit", urlVal.Get("limit"))
+	if err != nil {
+		httpRespError(w, r, err, http.StatusBadRequest)
+		return
+	}
+	if limit < 1 && format == "csv" {
+		limit = entity.MaxAPIStatisticHistoryLimit
+	}
+	page, err := parseNonNegativeQuery("Page", urlVal.Get("page"))
+	if err != nil {
+		httpRespError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	param := entity.APIStatisticSnapshotParam{
+		Path:  urlVal.Get("path"),
+		Limit: limit,
+		Page:  page,
+	}
+
+	// from & to in RFC3339 format
+	if from := urlVal.Get("from"); from != "" {
+		param.From, err = time.Parse(time.RFC3339, from)
+		if err != nil {
+			httpRespError(w, r, fmt.Errorf("Invalid From : %w", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if to := urlVal.Get("to"); to != "" {
+		param.To, err = time.Parse(time.RFC3339, to)
+		if err != nil {
+			httpRespError(w, r, fmt.Errorf("Invalid To : %w", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	snapshots, err := c.domain.GetAPIStatisticHistory(r.Context(), param)
+	if err != nil {
+		switch err {
+		case entity.ErrorAPIStatisticRangeInvalid, entity.ErrorAPIStatisticHistoryLimitTooLarge:
+			httpRespError(w, r, err, http.StatusBadRequest)
+			return
+		default:
+			httpRespError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	switch format {
+	case "", "json":
+		httpRespSuccess(w, r, http.StatusOK, snapshots)
+	case "csv":
+		records := [][]string{entity.APIStatisticSnapshotCSVHeader}
+		for _, snapshot := range snapshots {
+			records = append(records, snapshot.CSVRecord())
+		}
+		httpRespCSV(w, r, "api_statistic_history.csv", records)
+	default:
+		httpRespError(w, r, fmt.Errorf("Invalid Format : %s, must be json or csv", format), http.StatusBadRequest)
+	}
+}
+
+func (c *controller) ResetAPIStatistic(w http.ResponseWriter, r *http.Request) {
+	snapshots, err := c.domain.ResetAPIStatistic(r.Context())
+	if err != nil {
+		switch err {
+		case entity.ErrorRedisCircuitOpen:
+			httpRespError(w, r, err, http.StatusServiceUnavailable)
+			return
+		default:
+			httpRespError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	httpRespSuccess(w, r, http.StatusOK, snapshots)
+}
+
+// resourceStatisticResources map leaderboard route to resource
+var resourceStatisticResources = map[string]string{
+	"farms": entity.ResourceFarm,
+	"ponds": entity.ResourcePond,
+}
+
+func (c *controller) GetResourceStatistics(w http.ResponseWriter, r *http.Request) {
+	// get url query param
+	urlVal := r.URL.Query()
+
+	// top, default to 20
+	top, err := parseNonNegativeQuery("Top", urlVal.Get("top"))
+	if err != nil {
+		httpRespError(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	param := entity.ResourceStatisticParam{
+		Resource: resourceStatisticResources[mux.Vars(r)["resource"]],
+		Top:      top,
+	}
+
+	// dormant_since in RFC3339 format
+	if dormantSince := urlVal.Get("dormant_since"); dormantSince != "" {
+		param.DormantSince, err = time.Parse(time.RFC3339, dormantSince)
+		if err != nil {
+			httpRespError(w, r, fmt.Errorf("Invalid Dormant Since : %w", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	resourceStatistics, err := c.domain.GetResourceStatistics(r.Context(), param)
+	if err != nil {
+		switch err {
+		case entity.ErrorResourceStatisticResourceInvalid:
+			httpRespError(w, r, err, http.StatusBadRequest)
+			return
+		default:
+			httpRespError(w, r, err, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	httpRespSuccess(w, r, http.StatusOK, resourceStatistics)
+}
+
+// parseNonNegativeQuery parse number query param such as top or limit, empty is 0 which is replaced by default
+func parseNonNegativeQuery(name string, value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %s : %w", name, err)
+	} else if n < 0 {
+		return 0, fmt.Errorf("Invalid %s : %d, must not be negative", name, n)
+	}
+
+	return n, nil
 }
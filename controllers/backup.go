@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alvinatthariq/farmsvc-go/entity"
+	"github.com/alvinatthariq/farmsvc-go/logger"
+)
+
+// maxRestoreBodySize is max size of archive uploaded to restore
+var maxRestoreBodySize int64 = 256 << 20
+
+// headerWriter call writeHeader before the first write
+type headerWriter struct {
+	w           http.ResponseWriter
+	writeHeader func()
+	wroteHeader bool
+}
+
+func (hw *headerWriter) Write(p []byte) (int, error) {
+	if !hw.wroteHeader {
+		hw.wroteHeader = true
+		hw.writeHeader()
+	}
+
+	return hw.w.Write(p)
+}
+
+func (c *controller) Backup(w http.ResponseWriter, r *http.Request) {
+	// get url query param
+	urlVal := r.URL.Query()
+
+	includeStatistics, err := parseBoolQuery(urlVal.Get("include_statistics"))
+	if err != nil {
+		httpRespError(w, r, fmt.Errorf("Invalid Include Statistics : %w", err), http.StatusBadRequest)
+		return
+	}
+
+	// archive is streamed, header is only written with the first byte so failure before it get error response.
+	// checksum is known once archive is written, it is sent as trailer
+	filename := fmt.Sprintf("farmsvc-backup-%s.tar.gz", time.Now().UTC().Format("20060102T150405Z"))
+	archive := &headerWriter{w: w, writeHeader: func() {
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		w.Header().Set("Trailer", "X-Backup-SHA256")
+		w.WriteHeader(http.StatusOK)
+	}}
+	_, checksum, err := c.backup.BackupChecksum(r.Context(), archive, entity.BackupParam{
+		FarmIDs:           urlVal["farm_id"],
+		IncludeStatistics: includeStatistics,
+	})
+	if err != nil && !archive.wroteHeader {
+		httpRespError(w, r, err, http.StatusInternalServerError)
+		return
+	} else if err != nil {
+		// status is already sent, abort response so client does not take partial archive as complete
+		ctx := r.Context()
+		logger.FromContext(ctx).ErrorContext(ctx, "backup failed while streaming archive", slog.Any("error", err))
+		panic(http.ErrAbortHandler)
+	}
+
+	w.Header().Set("X-Backup-SHA256", checksum)
+}
+
+func (c *controller) Restore(w http.ResponseWriter, r *http.Request) {
+	// get url query param
+	urlVal := r.URL.Query()
+
+	includeStatistics, err := parseBoolQuery(urlVal.Get("include_statistics"))
+	if err != nil {
+		httpRespError(w, r, fmt.Errorf("Invalid Include Statistics : %w", err), http.StatusBadRequest)
+		return
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxRestoreBodySize)
+	result, err := c.backup.Restore(r.Context(), body, entity.RestoreParam{
+		Mode:              urlVal.Get("mode"),
+		Conflict:          urlVal.Get("conflict"),
+		IncludeStatistics: includeStatistics,
+	})
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr):
+			httpRespError(w, r, err, http.StatusRequestEntityTooLarge)
+		case errors.Is(err, entity.ErrorRestoreModeInvalid),
+			errors.Is(err, entity.ErrorRestoreConflictInvalid),
+			errors.Is(err, entity.ErrorBackupInvalid),
+			errors.Is(err, entity.ErrorBackupChecksumMismatch),
+			errors.Is(err, entity.ErrorBackupVersionUnsupported),
+			errors.Is(err, entity.ErrorRestorePondFarmNotFound):
+			httpRespError(w, r, err, http.StatusBadRequest)
+		case errors.Is(err, entity.ErrorBackupSchemaNewer),
+			errors.Is(err, entity.ErrorRestoreDatabaseNotEmpty),
+			errors.Is(err, entity.ErrorRestoreConflict):
+			httpRespError(w, r, err, http.StatusConflict)
+		default:
+			httpRespError(w, r, err, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	httpRespSuccess(w, r, http.StatusOK, result)
+}
+
+// parseBoolQuery parse boolean query param, empty is false
+func parseBoolQuery(value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+
+	return strconv.ParseBool(value)
+}
@@ -0,0 +1,70 @@
+package controllers_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/alvinatthariq/farmsvc-go/backup"
+	"github.com/alvinatthariq/farmsvc-go/controllers"
+	"github.com/alvinatthariq/farmsvc-go/domain"
+	"github.com/alvinatthariq/farmsvc-go/entity"
+	"github.com/alvinatthariq/farmsvc-go/migration"
+	"github.com/alvinatthariq/farmsvc-go/repository"
+
+	"github.com/gorilla/mux"
+	. "github.com/smartystreets/goconvey/convey"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+func TestBackupRestore(t *testing.T) {
+	Convey("TestBackupRestore", t, FailureHalts, func() {
+		dialector, err := repository.NewDialector(repository.DriverSQLite, filepath.Join(t.TempDir(), "farm.db"))
+		So(err, ShouldBeNil)
+		db, err := gorm.Open(dialector, &gorm.Config{
+			NamingStrategy: schema.NamingStrategy{
+				SingularTable: true,
+			},
+		})
+		So(err, ShouldBeNil)
+		So(migration.New(db).Up(), ShouldBeNil)
+		So(db.Create(&entity.Farm{ID: "farm-1", Name: "alpha", Description: "first"}).Error, ShouldBeNil)
+
+		dom := domain.InitWithRepository(repository.NewMemory(), domain.Options{})
+		defer dom.Close()
+		router := mux.NewRouter().StrictSlash(true)
+		controllers.Init(db, router, dom, nil, controllers.Options{AdminToken: "secret", Backup: backup.New(db, dom)})
+		request := func(method string, url string, body []byte) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, url, bytes.NewReader(body))
+			req.Header.Set("X-Admin-Token", "secret")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			return rec
+		}
+
+		t.Log("1 - [P] : Success stream archive, checksum sent as trailer")
+		rec := request(http.MethodGet, "/v1/admin/backup", nil)
+		So(rec.Code, ShouldEqual, http.StatusOK)
+		So(rec.Header().Get("Content-Type"), ShouldEqual, "application/gzip")
+		archive := rec.Body.Bytes()
+		So(rec.Result().Trailer.Get("X-Backup-SHA256"), ShouldEqual, backup.ArchiveChecksum(archive))
+
+		t.Log("2 - [N] : Failed restore invalid archive")
+		rec = request(http.MethodPost, "/v1/admin/restore?mode=merge&conflict=skip", []byte("not an archive"))
+		So(rec.Code, ShouldEqual, http.StatusBadRequest)
+
+		t.Log("3 - [N] : Failed restore archive larger than upload limit")
+		previous := controllers.SetMaxRestoreBodySize(int64(len(archive) / 2))
+		defer controllers.SetMaxRestoreBodySize(previous)
+		rec = request(http.MethodPost, "/v1/admin/restore?mode=merge&conflict=skip", archive)
+		So(rec.Code, ShouldEqual, http.StatusRequestEntityTooLarge)
+
+		t.Log("4 - [P] : Success restore archive within upload limit")
+		controllers.SetMaxRestoreBodySize(previous)
+		rec = request(http.MethodPost, "/v1/admin/restore?mode=merge&conflict=skip", archive)
+		So(rec.Code, ShouldEqual, http.StatusOK)
+	})
+}
@@ -1,36 +1,107 @@
 package controllers
 
 import (
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/alvinatthariq/farmsvc-go/backup"
 	"github.com/alvinatthariq/farmsvc-go/domain"
+	"github.com/alvinatthariq/farmsvc-go/health"
+	"github.com/alvinatthariq/farmsvc-go/metrics"
+	"github.com/alvinatthariq/farmsvc-go/telemetry"
+
 	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
 	"gorm.io/gorm"
 )
 
 type controller struct {
-	gorm   *gorm.DB
-	router *mux.Router
-	domain domain.DomainItf
+	gorm       *gorm.DB
+	router     *mux.Router
+	domain     domain.DomainItf
+	metrics    *metrics.Metrics
+	health     *health.Health
+	backup     *backup.Backup
+	logger     *slog.Logger
+	adminToken string
+
+	trustedProxies []netip.Prefix
+
+	requestTimeout time.Duration
+	routeTimeouts  map[string]time.Duration
 }
 
-func Init(gorm *gorm.DB, router *mux.Router, domain domain.DomainItf) {
+type Options struct {
+	// AdminToken must be sent in X-Admin-Token header to call admin endpoints,
+	// admin endpoints are disabled when empty
+	AdminToken string
+
+	// Logger is used for access & error log, slog.Default is used when nil
+	Logger *slog.Logger
+
+	// Health is checked by /readyz, dependencies are not checked when nil
+	Health *health.Health
+
+	// Backup serve admin backup & restore endpoints, they are not served when nil
+	Backup *backup.Backup
+
+	// TrustedProxies is address of proxies whose X-Forwarded-For & X-Client-ID header is honored,
+	// parsed by ParseTrustedProxies. Header of other caller is ignored
+	TrustedProxies []netip.Prefix
+
+	// RequestTimeout is deadline of request context, zero or negative disable it
+	RequestTimeout time.Duration
+	// RouteTimeouts override RequestTimeout of route, keyed by method & route template
+	// e.g. "GET /v1/api/statistic/history", method is case insensitive
+	RouteTimeouts map[string]time.Duration
+}
+
+// Init register routes to router, metrics is optional and /metrics is not served when nil
+func Init(gorm *gorm.DB, router *mux.Router, domain domain.DomainItf, metrics *metrics.Metrics, opt Options) {
 	var c *controller
 
+	if opt.Logger == nil {
+		opt.Logger = slog.Default()
+	}
+
 	c = &controller{
-		gorm:   gorm,
-		router: router,
-		domain: domain,
+		gorm:       gorm,
+		router:     router,
+		domain:     domain,
+		metrics:    metrics,
+		health:     opt.Health,
+		backup:     opt.Backup,
+		logger:     opt.Logger,
+		adminToken: opt.AdminToken,
+
+		trustedProxies: opt.TrustedProxies,
+
+		requestTimeout: opt.RequestTimeout,
+		routeTimeouts:  map[string]time.Duration{},
+	}
+	for route, timeout := range opt.RouteTimeouts {
+		c.routeTimeouts[routeTimeoutKey(route)] = timeout
 	}
 
 	c.Serve()
 }
 
 func (c *controller) Serve() {
+	// middlewares run for every route, including unmatched one
+	c.router.Use(c.middlewares()...)
+	c.router.NotFoundHandler = c.withMiddlewares(http.HandlerFunc(c.NotFound))
+	c.router.MethodNotAllowedHandler = c.withMiddlewares(http.HandlerFunc(c.MethodNotAllowed))
+
 	// farm
 	c.router.HandleFunc("/v1/farm", c.GetFarm).Methods("GET")
 	c.router.HandleFunc("/v1/farm/{id}", c.GetFarmByID).Methods("GET")
 	c.router.HandleFunc("/v1/farm", c.CreateFarm).Methods("POST")
 	c.router.HandleFunc("/v1/farm/{id}", c.UpdateFarm).Methods("PUT")
 	c.router.HandleFunc("/v1/farm/{id}", c.DeleteFarmByID).Methods("DELETE")
+	c.router.HandleFunc("/v1/farm/{id}/statistic", c.GetFarmStatistic).Methods("GET")
 
 	// pond
 	c.router.HandleFunc("/v1/pond", c.GetPond).Methods("GET")
@@ -38,7 +109,60 @@ func (c *controller) Serve() {
 	c.router.HandleFunc("/v1/pond", c.CreatePond).Methods("POST")
 	c.router.HandleFunc("/v1/pond/{id}", c.UpdatePond).Methods("PUT")
 	c.router.HandleFunc("/v1/pond/{id}", c.DeletePondByID).Methods("DELETE")
+	c.router.HandleFunc("/v1/pond/{id}/statistic", c.GetPondStatistic).Methods("GET")
 
 	// api statistic
 	c.router.HandleFunc("/v1/api/statistic", c.GetAPIStatistic).Methods("GET")
+	c.router.HandleFunc("/v1/api/statistic/clients", c.GetClientStatistic).Methods("GET")
+	c.router.HandleFunc("/v1/api/statistic/history", c.GetAPIStatisticHistory).Methods("GET")
+	c.router.HandleFunc("/v1/api/statistic/{resource:farms|ponds}", c.GetResourceStatistics).Methods("GET")
+	c.router.Handle("/v1/api/statistic/reset", c.requireAdmin(http.HandlerFunc(c.ResetAPIStatistic))).Methods("POST")
+
+	// backup & restore
+	if c.backup != nil {
+		c.router.Handle("/v1/admin/backup", c.requireAdmin(http.HandlerFunc(c.Backup))).Methods("GET")
+		c.router.Handle("/v1/admin/restore", c.requireAdmin(http.HandlerFunc(c.Restore))).Methods("POST")
+	}
+
+	// liveness & readiness probe
+	c.router.HandleFunc("/healthz", c.Liveness).Methods("GET")
+	c.router.HandleFunc("/readyz", c.Readiness).Methods("GET")
+
+	// prometheus metrics
+	if c.metrics != nil {
+		c.router.Handle("/metrics", c.metrics.Handler()).Methods("GET")
+	}
+}
+
+// middlewares return middlewares run for every request, first is outermost
+func (c *controller) middlewares() []mux.MiddlewareFunc {
+	return []mux.MiddlewareFunc{
+		// server span of every route, parented by traceparent header of request
+		otelmux.Middleware(telemetry.DefaultServiceName, otelmux.WithSpanNameFormatter(spanName)),
+		c.requestID,
+		c.timeout,
+		c.accessLog,
+		c.recordAPIStatistic,
+	}
+}
+
+// withMiddlewares wrap handler which is not a route, such as not found handler, with middlewares
+func (c *controller) withMiddlewares(handler http.Handler) http.Handler {
+	middlewares := c.middlewares()
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+
+	return handler
+}
+
+// routeTimeoutKey normalize route of RouteTimeouts into api statistic path format,
+// config loader may lower case the method
+func routeTimeoutKey(route string) string {
+	method, template, found := strings.Cut(strings.TrimSpace(route), " ")
+	if !found {
+		return route
+	}
+
+	return strings.ToUpper(method) + " " + strings.TrimSpace(template)
 }
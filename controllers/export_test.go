@@ -0,0 +1,7 @@
+package controllers
+
+// SetMaxRestoreBodySize change max size of archive uploaded to restore, previous size is returned to reset it
+func SetMaxRestoreBodySize(size int64) (previous int64) {
+	previous, maxRestoreBodySize = maxRestoreBodySize, size
+	return previous
+}
@@ -13,9 +13,6 @@ import (
 )
 
 func (c *controller) CreateFarm(w http.ResponseWriter, r *http.Request) {
-	// upsert api statistic
-	c.domain.UpsertAPIStatistic(entity.APIPathPOSTFarm, r.UserAgent())
-
 	// parse request body
 	var createFarmRequest entity.CreateFarmRequest
 	if err := json.NewDecoder(r.Body).Decode(&createFarmRequest); err != nil {
@@ -23,7 +20,7 @@ func (c *controller) CreateFarm(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	farm, err := c.domain.CreateFarm(createFarmRequest)
+	farm, err := c.domain.CreateFarm(r.Context(), createFarmRequest)
 	if err != nil {
 		switch err {
 		case entity.ErrorFarmAlreadyExist:
@@ -32,6 +29,7 @@ func (c *controller) CreateFarm(w http.ResponseWriter, r *http.Request) {
 		case
 			entity.ErrorFarmIDRequired,
 			entity.ErrorFarmIDMaxLength,
+			entity.ErrorFarmIDInvalidFormat,
 			entity.ErrorFarmNameRequired,
 			entity.ErrorFarmNameMaxLength,
 			entity.ErrorFarmDescriptionRequired,
@@ -48,12 +46,9 @@ func (c *controller) CreateFarm(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *controller) GetFarmByID(w http.ResponseWriter, r *http.Request) {
-	// upsert api statistic
-	c.domain.UpsertAPIStatistic(entity.APIPathGETFarmByID, r.UserAgent())
-
 	farmID := mux.Vars(r)["id"]
 
-	farmRes, err := c.domain.GetFarmByID(farmID)
+	farmRes, err := c.domain.GetFarmByID(r.Context(), farmID)
 	if err != nil {
 		httpRespError(w, r, fmt.Errorf("Error when get farm by id : %w", err), http.StatusInternalServerError)
 		return
@@ -66,9 +61,6 @@ func (c *controller) GetFarmByID(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *controller) GetFarm(w http.ResponseWriter, r *http.Request) {
-	// upsert api statistic
-	c.domain.UpsertAPIStatistic(entity.APIPathGETFarm, r.UserAgent())
-
 	// get url query param
 	urlVal := r.URL.Query()
 
@@ -90,7 +82,7 @@ func (c *controller) GetFarm(w http.ResponseWriter, r *http.Request) {
 		Page:  page,
 	}
 
-	farms, err := c.domain.GetFarm(param)
+	farms, err := c.domain.GetFarm(r.Context(), param)
 	if err != nil {
 		httpRespError(w, r, err, http.StatusInternalServerError)
 		return
@@ -105,9 +97,6 @@ func (c *controller) GetFarm(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *controller) UpdateFarm(w http.ResponseWriter, r *http.Request) {
-	// upsert api statistic
-	c.domain.UpsertAPIStatistic(entity.APIPathPUTFarmByID, r.UserAgent())
-
 	farmID := mux.Vars(r)["id"]
 
 	// read request body
@@ -117,7 +106,7 @@ func (c *controller) UpdateFarm(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	farm, err := c.domain.UpdateFarm(farmID, reqBody)
+	farm, err := c.domain.UpdateFarm(r.Context(), farmID, reqBody)
 	if err != nil {
 		switch err {
 		case entity.ErrorFarmAlreadyExist:
@@ -126,6 +115,7 @@ func (c *controller) UpdateFarm(w http.ResponseWriter, r *http.Request) {
 		case
 			entity.ErrorFarmIDRequired,
 			entity.ErrorFarmIDMaxLength,
+			entity.ErrorFarmIDInvalidFormat,
 			entity.ErrorFarmNameRequired,
 			entity.ErrorFarmNameMaxLength,
 			entity.ErrorFarmDescriptionRequired,
@@ -142,12 +132,9 @@ func (c *controller) UpdateFarm(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *controller) DeleteFarmByID(w http.ResponseWriter, r *http.Request) {
-	// upsert api statistic
-	c.domain.UpsertAPIStatistic(entity.APIPathDELETEFarmByID, r.UserAgent())
-
 	farmID := mux.Vars(r)["id"]
 
-	err := c.domain.DeleteFarmByID(farmID)
+	err := c.domain.DeleteFarmByID(r.Context(), farmID)
 	if err != nil {
 		if errors.Is(err, entity.ErrorFarmNotFound) {
 			httpRespError(w, r, err, http.StatusBadRequest)
@@ -159,3 +146,19 @@ func (c *controller) DeleteFarmByID(w http.ResponseWriter, r *http.Request) {
 
 	httpRespSuccess(w, r, http.StatusOK, nil)
 }
+
+func (c *controller) GetFarmStatistic(w http.ResponseWriter, r *http.Request) {
+	farmID := mux.Vars(r)["id"]
+
+	resourceStatistic, err := c.domain.GetFarmStatistic(r.Context(), farmID)
+	if err != nil {
+		if errors.Is(err, entity.ErrorFarmNotFound) {
+			httpRespError(w, r, err, http.StatusNotFound)
+			return
+		}
+		httpRespError(w, r, fmt.Errorf("Error GetFarmStatistic : %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	httpRespSuccess(w, r, http.StatusOK, resourceStatistic)
+}
@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/alvinatthariq/farmsvc-go/entity"
+)
+
+// Liveness report the process is serving requests, dependencies are not checked
+// so a broken dependency does not restart the service
+func (c *controller) Liveness(w http.ResponseWriter, r *http.Request) {
+	httpRespSuccess(w, r, http.StatusOK, entity.Health{
+		Status: entity.HealthStatusOK,
+	})
+}
+
+// Readiness report status & latency of every dependency,
+// 503 when a critical dependency is down or shutdown started
+func (c *controller) Readiness(w http.ResponseWriter, r *http.Request) {
+	health := entity.Health{
+		Status: entity.HealthStatusOK,
+	}
+	if c.health != nil {
+		health = c.health.Check(r.Context())
+	}
+
+	statusCode := http.StatusOK
+	if health.Status == entity.HealthStatusUnavailable || health.Status == entity.HealthStatusShuttingDown {
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	httpRespSuccess(w, r, statusCode, health)
+}
@@ -1,17 +1,37 @@
 package controllers
 
 import (
+	"bytes"
+	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/alvinatthariq/farmsvc-go/entity"
+	"github.com/alvinatthariq/farmsvc-go/logger"
 )
 
 func httpRespError(w http.ResponseWriter, r *http.Request, err error, statusCode int) {
+	// request timeout passed while waiting database or redis
+	if statusCode == http.StatusInternalServerError && errors.Is(err, context.DeadlineExceeded) {
+		statusCode = http.StatusGatewayTimeout
+	}
 	statusStr := http.StatusText(statusCode)
 
+	if statusCode >= http.StatusInternalServerError {
+		ctx := r.Context()
+		logger.FromContext(ctx).ErrorContext(ctx, "request failed",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", statusCode),
+			slog.Any("error", err),
+		)
+	}
+
 	jsonErrResp := &entity.HTTPEmptyResp{
 		Meta: entity.Meta{
 			Path:       r.URL.String(),
@@ -20,6 +40,7 @@ func httpRespError(w http.ResponseWriter, r *http.Request, err error, statusCode
 			Message:    fmt.Sprintf("%s %s [%d] %s", r.Method, r.URL.RequestURI(), statusCode, statusStr),
 			Error:      err.Error(),
 			Timestamp:  time.Now().Format(time.RFC3339),
+			RequestID:  logger.RequestID(r.Context()),
 		},
 	}
 
@@ -40,6 +61,7 @@ func httpRespSuccess(w http.ResponseWriter, r *http.Request, statusCode int, res
 		Status:     http.StatusText(statusCode),
 		Message:    fmt.Sprintf("%s %s [%d] %s", r.Method, r.URL.RequestURI(), statusCode, http.StatusText(statusCode)),
 		Timestamp:  time.Now().Format(time.RFC3339),
+		RequestID:  logger.RequestID(r.Context()),
 	}
 
 	var (
@@ -99,17 +121,73 @@ func httpRespSuccess(w http.ResponseWriter, r *http.Request, statusCode int, res
 		if err != nil {
 			statusCode = http.StatusInternalServerError
 		}
-	case []entity.APIStatistic:
+	case entity.HTTPAPIStatisticsData:
 		httpResp := &entity.HTTPAPIStatisticsResp{
 			Meta: meta,
-			Data: entity.HTTPAPIStatisticsData{
-				APIStatistics: data,
+			Data: data,
+		}
+		raw, err = json.Marshal(httpResp)
+		if err != nil {
+			statusCode = http.StatusInternalServerError
+		}
+	case []entity.APIStatisticSnapshot:
+		httpResp := &entity.HTTPAPIStatisticSnapshotsResp{
+			Meta: meta,
+			Data: entity.HTTPAPIStatisticSnapshotsData{
+				Snapshots: data,
 			},
 		}
 		raw, err = json.Marshal(httpResp)
 		if err != nil {
 			statusCode = http.StatusInternalServerError
 		}
+	case entity.ResourceStatistic:
+		httpResp := &entity.HTTPResourceStatisticResp{
+			Meta: meta,
+			Data: data,
+		}
+		raw, err = json.Marshal(httpResp)
+		if err != nil {
+			statusCode = http.StatusInternalServerError
+		}
+	case []entity.ResourceStatistic:
+		httpResp := &entity.HTTPResourceStatisticsResp{
+			Meta: meta,
+			Data: entity.HTTPResourceStatisticsData{
+				ResourceStatistics: data,
+			},
+		}
+		raw, err = json.Marshal(httpResp)
+		if err != nil {
+			statusCode = http.StatusInternalServerError
+		}
+	case entity.Health:
+		httpResp := &entity.HTTPHealthResp{
+			Meta: meta,
+			Data: data,
+		}
+		raw, err = json.Marshal(httpResp)
+		if err != nil {
+			statusCode = http.StatusInternalServerError
+		}
+	case entity.ClientStatistic:
+		httpResp := &entity.HTTPClientStatisticResp{
+			Meta: meta,
+			Data: data,
+		}
+		raw, err = json.Marshal(httpResp)
+		if err != nil {
+			statusCode = http.StatusInternalServerError
+		}
+	case entity.RestoreResult:
+		httpResp := &entity.HTTPRestoreResp{
+			Meta: meta,
+			Data: data,
+		}
+		raw, err = json.Marshal(httpResp)
+		if err != nil {
+			statusCode = http.StatusInternalServerError
+		}
 
 	default:
 		httpRespError(w, r, fmt.Errorf("cannot cast type of %+v", data), http.StatusInternalServerError)
@@ -120,3 +198,17 @@ func httpRespSuccess(w http.ResponseWriter, r *http.Request, statusCode int, res
 	w.WriteHeader(statusCode)
 	_, _ = w.Write(raw)
 }
+
+// httpRespCSV write records as csv attachment named filename
+func httpRespCSV(w http.ResponseWriter, r *http.Request, filename string, records [][]string) {
+	var buf bytes.Buffer
+	if err := csv.NewWriter(&buf).WriteAll(records); err != nil {
+		httpRespError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+}
@@ -0,0 +1,326 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/alvinatthariq/farmsvc-go/entity"
+	"github.com/alvinatthariq/farmsvc-go/logger"
+
+	"github.com/gorilla/mux"
+	"github.com/oklog/ulid/v2"
+)
+
+const headerRequestID = "X-Request-ID"
+
+// requestIDPattern is format of client supplied request id, other value is replaced
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// statusRecorder capture status code & body size written by handler
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	bytes      int
+}
+
+func (rec *statusRecorder) WriteHeader(statusCode int) {
+	rec.statusCode = statusCode
+	rec.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+// requestID propagate X-Request-ID header of request or generate one, the id is echoed in response header
+// and carried by request context with logger so every log line of the request include it
+func (c *controller) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(headerRequestID)
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = ulid.Make().String()
+		}
+		w.Header().Set(headerRequestID, requestID)
+
+		ctx := logger.WithRequestID(r.Context(), requestID)
+		ctx = logger.WithLogger(ctx, c.logger)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// timeout set deadline of request context from timeout of matched route or default request timeout,
+// database call still running when the deadline pass is canceled
+func (c *controller) timeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout := c.requestTimeout
+		if template, matched := routeTemplate(r, http.StatusOK); matched {
+			if routeTimeout, ok := c.routeTimeouts[r.Method+" "+template]; ok {
+				timeout = routeTimeout
+			}
+		}
+		if timeout <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// probeRoutes is route template of liveness & readiness probe, they are called every few seconds by
+// orchestrator so they are not logged nor recorded in api statistic
+var probeRoutes = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// isProbe return whether request is matched to liveness or readiness probe route
+func isProbe(r *http.Request) bool {
+	template, matched := routeTemplate(r, http.StatusOK)
+	return matched && probeRoutes[template]
+}
+
+// accessLog log every request after handler is done, except probe
+func (c *controller) accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isProbe(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+
+		template, _ := routeTemplate(r, rec.statusCode)
+		c.logger.LogAttrs(r.Context(), slog.LevelInfo, "access",
+			slog.String("method", r.Method),
+			slog.String("route", template),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.statusCode),
+			slog.Int("bytes", rec.bytes),
+			slog.Float64("duration_ms", float64(time.Since(start))/float64(time.Millisecond)),
+			slog.String("client_ip", c.clientIP(r)),
+			slog.String("user_agent", r.UserAgent()),
+		)
+	})
+}
+
+// recordAPIStatistic record api statistic of request after handler is done,
+// path is taken from matched route template so every route is recorded without listing it.
+// Probe is observed in metrics only
+func (c *controller) recordAPIStatistic(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+		latency := time.Since(start)
+
+		template, matched := routeTemplate(r, rec.statusCode)
+		path := template
+		if matched {
+			path = r.Method + " " + template
+		}
+
+		event := entity.APIStatisticEvent{
+			Path:       path,
+			UserAgent:  r.UserAgent(),
+			ClientID:   c.clientID(r),
+			ClientIP:   c.clientIP(r),
+			StatusCode: rec.statusCode,
+			Latency:    latency,
+			At:         start,
+		}
+		// only successful access is counted, so unknown id does not show up in resource statistic
+		if matched && rec.statusCode < 400 {
+			event.Resource, event.ResourceID, event.ResourceAccess = resourceAccess(r, template)
+		}
+
+		// statistic is best effort, ignore error
+		if !probeRoutes[template] {
+			c.domain.RecordAPIStatistic(event)
+		}
+
+		if c.metrics != nil {
+			c.metrics.ObserveHTTPRequest(template, r.Method, rec.statusCode, latency)
+		}
+	})
+}
+
+// routeTemplate return matched route template of request e.g. "/v1/farm/{id}",
+// or entity.APIPathNotFound / entity.APIPathMethodNotAllowed when no route matched
+func routeTemplate(r *http.Request, statusCode int) (template string, matched bool) {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		if statusCode == http.StatusMethodNotAllowed {
+			return entity.APIPathMethodNotAllowed, false
+		}
+		return entity.APIPathNotFound, false
+	}
+
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return entity.APIPathNotFound, false
+	}
+
+	return template, true
+}
+
+// resourceRoutes is route template of single farm or pond, access of them is counted per id
+var resourceRoutes = map[string]string{
+	"/v1/farm/{id}": entity.ResourceFarm,
+	"/v1/pond/{id}": entity.ResourcePond,
+}
+
+// resourceAccess return farm or pond accessed by request and whether it is read or write,
+// resource is empty when template is not single farm or pond route. Delete is not counted
+// since statistic of deleted farm or pond is removed
+func resourceAccess(r *http.Request, template string) (resource string, id string, access string) {
+	resource, ok := resourceRoutes[template]
+	if !ok || r.Method == http.MethodDelete {
+		return "", "", ""
+	}
+
+	access = entity.ResourceAccessWrite
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		access = entity.ResourceAccessRead
+	}
+
+	return resource, mux.Vars(r)["id"], access
+}
+
+// spanName return server span name of request, method & route template as api statistic path
+func spanName(routeName string, r *http.Request) string {
+	return r.Method + " " + routeName
+}
+
+// clientID return client id of request from X-Client-ID header set by trusted proxy, or fingerprint of
+// X-API-Key header so the key itself is never stored
+func (c *controller) clientID(r *http.Request) string {
+	if c.isTrustedProxy(remoteIP(r)) {
+		if id := strings.TrimSpace(r.Header.Get("X-Client-ID")); id != "" {
+			return id
+		}
+	}
+
+	if key := strings.TrimSpace(r.Header.Get("X-API-Key")); key != "" {
+		sum := sha256.Sum256([]byte(key))
+		return "key:" + hex.EncodeToString(sum[:6])
+	}
+
+	return ""
+}
+
+// clientIP return remote address of request. When it is a trusted proxy, X-Forwarded-For is read from the
+// right and the first address which is not a trusted proxy is returned, since caller may prepend any address
+func (c *controller) clientIP(r *http.Request) string {
+	ip := remoteIP(r)
+	if !c.isTrustedProxy(ip) {
+		return ip
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if hop == "" {
+			continue
+		}
+		ip = hop
+		if !c.isTrustedProxy(hop) {
+			break
+		}
+	}
+
+	return ip
+}
+
+// remoteIP return address of immediate peer of request
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+func (c *controller) isTrustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, prefix := range c.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseTrustedProxies parse proxy address or CIDR e.g. "10.0.0.1" or "10.0.0.0/8"
+func ParseTrustedProxies(proxies []string) (prefixes []netip.Prefix, err error) {
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		var prefix netip.Prefix
+		if strings.Contains(proxy, "/") {
+			prefix, err = netip.ParsePrefix(proxy)
+		} else {
+			var addr netip.Addr
+			addr, err = netip.ParseAddr(proxy)
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Invalid Trusted Proxy %q : %w", proxy, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+// requireAdmin allow request with X-Admin-Token header equal to configured admin token
+func (c *controller) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.adminToken == "" {
+			httpRespError(w, r, entity.ErrorAdminDisabled, http.StatusForbidden)
+			return
+		}
+
+		token := r.Header.Get("X-Admin-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(c.adminToken)) != 1 {
+			httpRespError(w, r, entity.ErrorAdminUnauthorized, http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *controller) NotFound(w http.ResponseWriter, r *http.Request) {
+	httpRespError(w, r, entity.ErrorRouteNotFound, http.StatusNotFound)
+}
+
+func (c *controller) MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	httpRespError(w, r, entity.ErrorRouteMethodNotAllowed, http.StatusMethodNotAllowed)
+}
@@ -0,0 +1,227 @@
+package controllers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alvinatthariq/farmsvc-go/controllers"
+	"github.com/alvinatthariq/farmsvc-go/domain"
+	"github.com/alvinatthariq/farmsvc-go/entity"
+	"github.com/alvinatthariq/farmsvc-go/logger"
+	"github.com/alvinatthariq/farmsvc-go/repository"
+
+	"github.com/gorilla/mux"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// newRouter return router served by in memory domain, every log line is written to logs as json
+func newRouter(opt controllers.Options) (router *mux.Router, dom domain.DomainItf, logs *bytes.Buffer) {
+	logs = &bytes.Buffer{}
+	opt.Logger = logger.New(logger.Options{Output: logs})
+
+	dom = domain.InitWithRepository(repository.NewMemory(), domain.Options{})
+	router = mux.NewRouter().StrictSlash(true)
+	controllers.Init(nil, router, dom, nil, opt)
+
+	return router, dom, logs
+}
+
+// accessLogs return access log lines of logs
+func accessLogs(logs *bytes.Buffer) (lines []map[string]interface{}) {
+	decoder := json.NewDecoder(bytes.NewReader(logs.Bytes()))
+	for decoder.More() {
+		line := map[string]interface{}{}
+		So(decoder.Decode(&line), ShouldBeNil)
+		if line["msg"] == "access" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines
+}
+
+func TestClientIdentification(t *testing.T) {
+	Convey("TestClientIdentification", t, FailureHalts, func() {
+		trustedProxies, err := controllers.ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+		So(err, ShouldBeNil)
+
+		testCases := []struct {
+			testID     int
+			testType   string
+			testDesc   string
+			remoteAddr string
+			headers    map[string]string
+			clientIP   string
+			clientID   string
+		}{
+			{
+				testID:     1,
+				testType:   "P",
+				testDesc:   "Success use remote address, header of untrusted caller ignored",
+				remoteAddr: "203.0.113.7:5000",
+				headers:    map[string]string{"X-Forwarded-For": "198.51.100.1", "X-Client-ID": "spoofed"},
+				clientIP:   "203.0.113.7",
+				clientID:   entity.ClientIDAnonymous,
+			},
+			{
+				testID:     2,
+				testType:   "P",
+				testDesc:   "Success honor header of trusted proxy",
+				remoteAddr: "10.1.2.3:5000",
+				headers:    map[string]string{"X-Forwarded-For": "198.51.100.1", "X-Client-ID": "partner-a"},
+				clientIP:   "198.51.100.1",
+				clientID:   "partner-a",
+			},
+			{
+				testID:     3,
+				testType:   "P",
+				testDesc:   "Success skip trusted hops, address prepended by caller ignored",
+				remoteAddr: "10.1.2.3:5000",
+				headers:    map[string]string{"X-Forwarded-For": "1.1.1.1, 198.51.100.1, 192.168.1.1"},
+				clientIP:   "198.51.100.1",
+				clientID:   entity.ClientIDAnonymous,
+			},
+			{
+				testID:     4,
+				testType:   "P",
+				testDesc:   "Success use api key fingerprint of untrusted caller",
+				remoteAddr: "203.0.113.7:5000",
+				headers:    map[string]string{"X-API-Key": "secret"},
+				clientIP:   "203.0.113.7",
+				clientID:   "key:2bb80d537b1d",
+			},
+		}
+
+		for _, tc := range testCases {
+			t.Logf("%d - [%s] : %s", tc.testID, tc.testType, tc.testDesc)
+			router, dom, logs := newRouter(controllers.Options{TrustedProxies: trustedProxies})
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/api/statistic/clients", nil)
+			req.RemoteAddr = tc.remoteAddr
+			for key, value := range tc.headers {
+				req.Header.Set(key, value)
+			}
+			router.ServeHTTP(httptest.NewRecorder(), req)
+			dom.FlushAPIStatistic()
+
+			lines := accessLogs(logs)
+			So(lines, ShouldHaveLength, 1)
+			So(lines[0]["client_ip"], ShouldEqual, tc.clientIP)
+
+			clientStatistic, err := dom.GetClientStatistic(context.Background(), 0)
+			So(err, ShouldBeNil)
+			So(clientStatistic.TopClients, ShouldContain, entity.ClientCount{Name: tc.clientID, Count: 1})
+			dom.Close()
+		}
+	})
+}
+
+func TestInvalidNumberQuery(t *testing.T) {
+	Convey("TestInvalidNumberQuery", t, FailureHalts, func() {
+		router, dom, _ := newRouter(controllers.Options{})
+		defer dom.Close()
+
+		testCases := []struct {
+			testID   int
+			testType string
+			testDesc string
+			url      string
+			expected int
+		}{
+			{testID: 1, testType: "P", testDesc: "Success get clients with default top", url: "/v1/api/statistic/clients", expected: http.StatusOK},
+			{testID: 2, testType: "N", testDesc: "Failed get clients, top is not number", url: "/v1/api/statistic/clients?top=ten", expected: http.StatusBadRequest},
+			{testID: 3, testType: "N", testDesc: "Failed get clients, top is negative", url: "/v1/api/statistic/clients?top=-1", expected: http.StatusBadRequest},
+			{testID: 4, testType: "P", testDesc: "Success get farms leaderboard", url: "/v1/api/statistic/farms?top=5", expected: http.StatusOK},
+			{testID: 5, testType: "N", testDesc: "Failed get farms leaderboard, top is not number", url: "/v1/api/statistic/farms?top=5x", expected: http.StatusBadRequest},
+			{testID: 6, testType: "P", testDesc: "Success export history as csv", url: "/v1/api/statistic/history?format=csv", expected: http.StatusOK},
+			{testID: 7, testType: "N", testDesc: "Failed get history, limit is not number", url: "/v1/api/statistic/history?limit=all", expected: http.StatusBadRequest},
+			{testID: 8, testType: "N", testDesc: "Failed export history, limit above max", url: "/v1/api/statistic/history?format=csv&limit=10001", expected: http.StatusBadRequest},
+		}
+
+		for _, tc := range testCases {
+			t.Logf("%d - [%s] : %s", tc.testID, tc.testType, tc.testDesc)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.url, nil))
+			So(rec.Code, ShouldEqual, tc.expected)
+		}
+	})
+}
+
+func TestRequestID(t *testing.T) {
+	Convey("TestRequestID", t, FailureHalts, func() {
+		testCases := []struct {
+			testID    int
+			testType  string
+			testDesc  string
+			requestID string
+			expected  string
+		}{
+			{testID: 1, testType: "P", testDesc: "Success propagate request id of request", requestID: "req-123:abc", expected: "req-123:abc"},
+			{testID: 2, testType: "P", testDesc: "Success generate request id when missing", requestID: ""},
+			{testID: 3, testType: "N", testDesc: "Failed propagate invalid request id, replaced", requestID: "bad id\n"},
+		}
+
+		for _, tc := range testCases {
+			t.Logf("%d - [%s] : %s", tc.testID, tc.testType, tc.testDesc)
+			router, dom, logs := newRouter(controllers.Options{})
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/farm/unknown-farm", nil)
+			if tc.requestID != "" {
+				req.Header.Set("X-Request-ID", tc.requestID)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			dom.Close()
+
+			requestID := rec.Header().Get("X-Request-ID")
+			if tc.expected != "" {
+				So(requestID, ShouldEqual, tc.expected)
+			} else {
+				So(requestID, ShouldNotBeEmpty)
+				So(requestID, ShouldNotEqual, tc.requestID)
+			}
+
+			var body entity.HTTPEmptyResp
+			So(json.Unmarshal(rec.Body.Bytes(), &body), ShouldBeNil)
+			So(body.Meta.RequestID, ShouldEqual, requestID)
+
+			lines := accessLogs(logs)
+			So(lines, ShouldHaveLength, 1)
+			So(lines[0][logger.KeyRequestID], ShouldEqual, requestID)
+		}
+	})
+}
+
+func TestProbeNotRecorded(t *testing.T) {
+	Convey("TestProbeNotRecorded", t, FailureHalts, func() {
+		router, dom, logs := newRouter(controllers.Options{})
+		defer dom.Close()
+
+		t.Log("1 - [P] : Success serve probe without access log & api statistic")
+		for _, url := range []string{"/healthz", "/readyz"} {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+			So(rec.Code, ShouldEqual, http.StatusOK)
+		}
+		dom.FlushAPIStatistic()
+		So(accessLogs(logs), ShouldBeEmpty)
+
+		apiStatistics, err := dom.GetAPIStatistic(context.Background(), entity.APIStatisticParam{})
+		So(err, ShouldBeNil)
+		So(apiStatistics, ShouldBeEmpty)
+
+		t.Log("2 - [P] : Success log & record other route")
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/farm", nil))
+		dom.FlushAPIStatistic()
+		So(accessLogs(logs), ShouldHaveLength, 1)
+
+		apiStatistics, err = dom.GetAPIStatistic(context.Background(), entity.APIStatisticParam{})
+		So(err, ShouldBeNil)
+		So(apiStatistics, ShouldHaveLength, 1)
+		So(apiStatistics[0].Path, ShouldEqual, "GET /v1/farm")
+	})
+}
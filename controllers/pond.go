@@ -13,9 +13,6 @@ import (
 )
 
 func (c *controller) CreatePond(w http.ResponseWriter, r *http.Request) {
-	// upsert api statistic
-	c.domain.UpsertAPIStatistic(entity.APIPathPOSTPond, r.UserAgent())
-
 	// parse request body
 	var createPondRequest entity.CreatePondRequest
 	if err := json.NewDecoder(r.Body).Decode(&createPondRequest); err != nil {
@@ -23,7 +20,7 @@ func (c *controller) CreatePond(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pond, err := c.domain.CreatePond(createPondRequest)
+	pond, err := c.domain.CreatePond(r.Context(), createPondRequest)
 	if err != nil {
 		switch err {
 		case entity.ErrorPondAlreadyExist:
@@ -35,6 +32,7 @@ func (c *controller) CreatePond(w http.ResponseWriter, r *http.Request) {
 			entity.ErrorFarmIDMaxLength,
 			entity.ErrorPondIDRequired,
 			entity.ErrorPondIDMaxLength,
+			entity.ErrorPondIDInvalidFormat,
 			entity.ErrorPondNameRequired,
 			entity.ErrorPondNameMaxLength,
 			entity.ErrorPondDescriptionRequired,
@@ -51,12 +49,9 @@ func (c *controller) CreatePond(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *controller) GetPondByID(w http.ResponseWriter, r *http.Request) {
-	// upsert api statistic
-	c.domain.UpsertAPIStatistic(entity.APIPathGETPondByID, r.UserAgent())
-
 	pondID := mux.Vars(r)["id"]
 
-	pondRes, err := c.domain.GetPondByID(pondID)
+	pondRes, err := c.domain.GetPondByID(r.Context(), pondID)
 	if err != nil {
 		httpRespError(w, r, fmt.Errorf("Error when get pond by id : %w", err), http.StatusInternalServerError)
 		return
@@ -69,9 +64,6 @@ func (c *controller) GetPondByID(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *controller) GetPond(w http.ResponseWriter, r *http.Request) {
-	// upsert api statistic
-	c.domain.UpsertAPIStatistic(entity.APIPathGETPond, r.UserAgent())
-
 	// get url query param
 	urlVal := r.URL.Query()
 
@@ -94,7 +86,7 @@ func (c *controller) GetPond(w http.ResponseWriter, r *http.Request) {
 		Limit:  limit,
 	}
 
-	ponds, err := c.domain.GetPond(param)
+	ponds, err := c.domain.GetPond(r.Context(), param)
 	if err != nil {
 		httpRespError(w, r, err, http.StatusInternalServerError)
 		return
@@ -109,9 +101,6 @@ func (c *controller) GetPond(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *controller) UpdatePond(w http.ResponseWriter, r *http.Request) {
-	// upsert api statistic
-	c.domain.UpsertAPIStatistic(entity.APIPathPUTPondByID, r.UserAgent())
-
 	pondID := mux.Vars(r)["id"]
 
 	// read request body
@@ -121,7 +110,7 @@ func (c *controller) UpdatePond(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pond, err := c.domain.UpdatePond(pondID, reqBody)
+	pond, err := c.domain.UpdatePond(r.Context(), pondID, reqBody)
 	if err != nil {
 		switch err {
 		case entity.ErrorPondAlreadyExist:
@@ -133,6 +122,7 @@ func (c *controller) UpdatePond(w http.ResponseWriter, r *http.Request) {
 			entity.ErrorFarmIDMaxLength,
 			entity.ErrorPondIDRequired,
 			entity.ErrorPondIDMaxLength,
+			entity.ErrorPondIDInvalidFormat,
 			entity.ErrorPondNameRequired,
 			entity.ErrorPondNameMaxLength,
 			entity.ErrorPondDescriptionRequired,
@@ -149,12 +139,9 @@ func (c *controller) UpdatePond(w http.ResponseWriter, r *http.Request) {
 }
 
 func (c *controller) DeletePondByID(w http.ResponseWriter, r *http.Request) {
-	// upsert api statistic
-	c.domain.UpsertAPIStatistic(entity.APIPathDELETEPondByID, r.UserAgent())
-
 	pondID := mux.Vars(r)["id"]
 
-	err := c.domain.DeletePondByID(pondID)
+	err := c.domain.DeletePondByID(r.Context(), pondID)
 	if err != nil {
 		if errors.Is(err, entity.ErrorPondNotFound) {
 			httpRespError(w, r, err, http.StatusBadRequest)
@@ -166,3 +153,19 @@ func (c *controller) DeletePondByID(w http.ResponseWriter, r *http.Request) {
 
 	httpRespSuccess(w, r, http.StatusOK, nil)
 }
+
+func (c *controller) GetPondStatistic(w http.ResponseWriter, r *http.Request) {
+	pondID := mux.Vars(r)["id"]
+
+	resourceStatistic, err := c.domain.GetPondStatistic(r.Context(), pondID)
+	if err != nil {
+		if errors.Is(err, entity.ErrorPondNotFound) {
+			httpRespError(w, r, err, http.StatusNotFound)
+			return
+		}
+		httpRespError(w, r, fmt.Errorf("Error GetPondStatistic : %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	httpRespSuccess(w, r, http.StatusOK, resourceStatistic)
+}
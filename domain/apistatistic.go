@@ -1,56 +1,78 @@
 package domain
 
 import (
-	"errors"
+	"context"
+	"log/slog"
+	"math/rand"
+	"time"
 
 	"github.com/alvinatthariq/farmsvc-go/entity"
-
-	"github.com/go-redis/redis"
 )
 
-func (d *domain) UpsertAPIStatistic(apiPath string, userAgent string) error {
-	res := d.redisClient.IncrBy(apiPath, 1)
-	if res.Err() != nil {
-		return res.Err()
+// RecordAPIStatistic buffer event to be written in background, event is dropped when buffer is full.
+// Only fraction StatisticSampleRatio of events is kept
+func (d *domain) RecordAPIStatistic(event entity.APIStatisticEvent) error {
+	// request not sampled is skipped silently, it is not a dropped event
+	if ratio := d.settings().StatisticSampleRatio; ratio > 0 && ratio < 1 && rand.Float64() >= ratio {
+		return nil
 	}
 
-	res = d.redisClient.PFAdd(apiPath+"ua", userAgent)
-	if res.Err() != nil {
-		return res.Err()
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+	if event.ClientID == "" {
+		event.ClientID = entity.ClientIDAnonymous
 	}
 
-	return nil
+	return d.statisticBuffer.add(event)
 }
 
-func (d *domain) GetAPIStatistic() (apiStatistics []entity.APIStatistic, err error) {
-	for _, apiPath := range entity.APIPaths {
-		res := d.redisClient.Get(apiPath)
-		if res.Err() != nil {
-			if !errors.Is(res.Err(), redis.Nil) {
-				return apiStatistics, res.Err()
-			}
-		}
+// writeAPIStatistics write batch of buffered events, failed batch is logged and counted by buffer.
+// Batch mix events of many requests, so it is not bound to any request context
+func (d *domain) writeAPIStatistics(events []entity.APIStatisticEvent) error {
+	ctx := context.Background()
+	err := d.statisticRepo.RecordAPIStatistics(ctx, events)
+	if err != nil {
+		d.logger.WarnContext(ctx, "Failed to write api statistic", slog.Int("events", len(events)), slog.Any("error", err))
+	}
+
+	return err
+}
+
+// FlushAPIStatistic write every buffered event and wait until done
+func (d *domain) FlushAPIStatistic() {
+	d.statisticBuffer.flush()
+}
 
-		count, err := res.Int64()
+func (d *domain) GetAPIStatisticBufferStatistic() entity.APIStatisticBufferStatistic {
+	return d.statisticBuffer.statistic()
+}
+
+func (d *domain) GetAPIStatistic(ctx context.Context, param entity.APIStatisticParam) (apiStatistics []entity.APIStatistic, err error) {
+	var granularity entity.Granularity
+	if param.Granularity != "" {
+		granularity, param, err = validateAPIStatisticParam(param)
 		if err != nil {
-			if !errors.Is(err, redis.Nil) {
-				return apiStatistics, err
-			}
+			return apiStatistics, err
 		}
+	}
 
-		resUa := d.redisClient.PFCount(apiPath + "ua")
-		if resUa.Err() != nil {
-			if !errors.Is(resUa.Err(), redis.Nil) {
-				return apiStatistics, resUa.Err()
-			}
-		}
+	apiPaths, err := d.statisticRepo.GetAPIStatisticPaths(ctx)
+	if err != nil {
+		return apiStatistics, err
+	}
 
-		uniqueUserAgent := resUa.Val()
+	for _, apiPath := range apiPaths {
+		apiStat, err := d.statisticRepo.GetAPIStatistic(ctx, apiPath)
+		if err != nil {
+			return apiStatistics, err
+		}
 
-		apiStat := entity.APIStatistic{
-			Path:            apiPath,
-			Count:           count,
-			UniqueUserAgent: uniqueUserAgent,
+		if param.Granularity != "" {
+			apiStat.Series, err = d.statisticRepo.GetAPIStatisticSeries(ctx, apiPath, granularity, param.From, param.To)
+			if err != nil {
+				return apiStatistics, err
+			}
 		}
 
 		apiStatistics = append(apiStatistics, apiStat)
@@ -58,3 +80,39 @@ func (d *domain) GetAPIStatistic() (apiStatistics []entity.APIStatistic, err err
 
 	return apiStatistics, nil
 }
+
+// GetClientStatistic return top clients & user agents, top below 1 default to
+// entity.DefaultClientStatisticTop and is capped at entity.MaxClientStatisticTop
+func (d *domain) GetClientStatistic(ctx context.Context, top int) (clientStatistic entity.ClientStatistic, err error) {
+	if top < 1 {
+		top = entity.DefaultClientStatisticTop
+	} else if top > entity.MaxClientStatisticTop {
+		top = entity.MaxClientStatisticTop
+	}
+
+	return d.statisticRepo.GetClientStatistic(ctx, top)
+}
+
+// validateAPIStatisticParam check granularity & range of series,
+// to default to now and from default to 60 buckets before to
+func validateAPIStatisticParam(param entity.APIStatisticParam) (granularity entity.Granularity, p entity.APIStatisticParam, err error) {
+	granularity, ok := entity.GetGranularity(param.Granularity)
+	if !ok {
+		return granularity, param, entity.ErrorAPIStatisticGranularityInvalid
+	}
+
+	if param.To.IsZero() {
+		param.To = time.Now()
+	}
+	if param.From.IsZero() {
+		param.From = param.To.Add(-59 * granularity.Size)
+	}
+
+	if param.From.After(param.To) {
+		return granularity, param, entity.ErrorAPIStatisticRangeInvalid
+	} else if param.To.Sub(param.From)/granularity.Size >= entity.MaxAPIStatisticSeriesPoints {
+		return granularity, param, entity.ErrorAPIStatisticRangeTooLarge
+	}
+
+	return granularity, param, nil
+}
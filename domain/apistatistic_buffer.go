@@ -0,0 +1,182 @@
+package domain
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alvinatthariq/farmsvc-go/entity"
+)
+
+const (
+	DefaultStatisticBufferSize    = 10000
+	DefaultStatisticBatchSize     = 500
+	DefaultStatisticFlushInterval = time.Second
+
+	// statisticRetryDelay is wait before failed batch is written again
+	statisticRetryDelay = 200 * time.Millisecond
+)
+
+// statisticBuffer hold api statistic events in memory, events are written to
+// statistic repository in batch by background worker so request never wait for redis
+type statisticBuffer struct {
+	mu     sync.RWMutex
+	closed bool
+
+	events        chan entity.APIStatisticEvent
+	flushRequests chan chan struct{}
+	done          chan struct{}
+	batchSize     int
+	flushInterval time.Duration
+
+	dropped int64
+	failed  int64
+	flushed int64
+}
+
+func newStatisticBuffer(bufferSize int, batchSize int, flushInterval time.Duration) *statisticBuffer {
+	if bufferSize < 1 {
+		bufferSize = DefaultStatisticBufferSize
+	}
+	if batchSize < 1 {
+		batchSize = DefaultStatisticBatchSize
+	}
+	if flushInterval <= 0 {
+		flushInterval = DefaultStatisticFlushInterval
+	}
+
+	return &statisticBuffer{
+		events:        make(chan entity.APIStatisticEvent, bufferSize),
+		flushRequests: make(chan chan struct{}),
+		done:          make(chan struct{}),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+	}
+}
+
+// add enqueue event without blocking, event is dropped when buffer is full or closed
+func (b *statisticBuffer) add(event entity.APIStatisticEvent) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closed {
+		atomic.AddInt64(&b.dropped, 1)
+		return entity.ErrorAPIStatisticBufferClosed
+	}
+
+	select {
+	case b.events <- event:
+		return nil
+	default:
+		atomic.AddInt64(&b.dropped, 1)
+		return entity.ErrorAPIStatisticBufferFull
+	}
+}
+
+// run write buffered events in batch until buffer is closed, remaining events are written before return.
+// Failed batch is written once more, batch failing again is dropped and counted as failed: statistic is
+// best effort and holding it longer would stall the buffer while storage is down.
+// writeCounters write counters kept outside the buffer such as cache hit & miss, it is called every flush interval,
+// on flush and before return
+func (b *statisticBuffer) run(write func(events []entity.APIStatisticEvent) error, writeCounters func()) {
+	defer close(b.done)
+
+	ticker := time.NewTicker(b.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]entity.APIStatisticEvent, 0, b.batchSize)
+	flush := func() {
+		if len(batch) < 1 {
+			return
+		}
+		err := write(batch)
+		if err != nil {
+			time.Sleep(statisticRetryDelay)
+			err = write(batch)
+		}
+		if err != nil {
+			atomic.AddInt64(&b.failed, int64(len(batch)))
+		} else {
+			atomic.AddInt64(&b.flushed, int64(len(batch)))
+		}
+		batch = batch[:0]
+	}
+	// drain take every event already in buffer
+	drain := func() {
+		for {
+			select {
+			case event, ok := <-b.events:
+				if !ok {
+					return
+				}
+				batch = append(batch, event)
+				if len(batch) >= b.batchSize {
+					flush()
+				}
+			default:
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case event, ok := <-b.events:
+			if !ok {
+				flush()
+				writeCounters()
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= b.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+			writeCounters()
+		case flushed := <-b.flushRequests:
+			drain()
+			flush()
+			writeCounters()
+			close(flushed)
+		}
+	}
+}
+
+// flush write every buffered event and wait until done
+func (b *statisticBuffer) flush() {
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		<-b.done
+		return
+	}
+
+	flushed := make(chan struct{})
+	b.flushRequests <- flushed
+	b.mu.RUnlock()
+
+	<-flushed
+}
+
+// close stop accepting event and wait until remaining events are written
+func (b *statisticBuffer) close() {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.events)
+	}
+	b.mu.Unlock()
+
+	<-b.done
+}
+
+func (b *statisticBuffer) statistic() entity.APIStatisticBufferStatistic {
+	return entity.APIStatisticBufferStatistic{
+		Buffered: int64(len(b.events)),
+		Capacity: int64(cap(b.events)),
+		Dropped:  atomic.LoadInt64(&b.dropped),
+		Failed:   atomic.LoadInt64(&b.failed),
+		Flushed:  atomic.LoadInt64(&b.flushed),
+	}
+}
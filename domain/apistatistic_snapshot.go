@@ -0,0 +1,117 @@
+package domain
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/alvinatthariq/farmsvc-go/entity"
+)
+
+const (
+	DefaultStatisticSnapshotInterval = time.Hour
+
+	statisticSnapshotLockName = "statistic-snapshot:"
+)
+
+// runStatisticSnapshot snapshot api statistic every interval until stop is closed. Every replica run it,
+// a lock per interval slot make sure only the first replica reaching the slot write the snapshot
+func (d *domain) runStatisticSnapshot(interval time.Duration, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ctx := context.Background()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			slot := now.Truncate(interval)
+			acquired, err := d.statisticRepo.Lock(ctx, statisticSnapshotLockName+strconv.FormatInt(slot.Unix(), 10), interval)
+			if err != nil {
+				d.logger.ErrorContext(ctx, "Failed to lock api statistic snapshot", slog.Any("error", err))
+				continue
+			} else if !acquired {
+				continue
+			}
+
+			// failed snapshot is not retried, next one is taken on next slot
+			snapshots, err := d.snapshotAPIStatistic(ctx, entity.APIStatisticSnapshotReasonPeriodic)
+			if err != nil {
+				d.logger.ErrorContext(ctx, "Failed to snapshot api statistic", slog.Any("error", err))
+				continue
+			}
+			d.logger.InfoContext(ctx, "API statistic snapshotted", slog.Int("paths", len(snapshots)))
+		}
+	}
+}
+
+// snapshotAPIStatistic write current lifetime statistic of every path with the same snapshot time
+func (d *domain) snapshotAPIStatistic(ctx context.Context, reason string) (snapshots []entity.APIStatisticSnapshot, err error) {
+	apiStatistics, err := d.GetAPIStatistic(ctx, entity.APIStatisticParam{})
+	if err != nil {
+		return snapshots, err
+	}
+
+	snapshotAt := time.Now().UTC().Truncate(time.Second)
+	snapshots = []entity.APIStatisticSnapshot{}
+	for _, apiStat := range apiStatistics {
+		snapshots = append(snapshots, entity.NewAPIStatisticSnapshot(apiStat, reason, snapshotAt))
+	}
+
+	err = d.statisticSnapshotRepo.Create(ctx, snapshots)
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshots, nil
+}
+
+// ResetAPIStatistic archive current api statistic as snapshot then zero every api & client statistic,
+// statistic is not zeroed when archive failed or redis circuit is open. Event recorded between archive & reset is lost
+func (d *domain) ResetAPIStatistic(ctx context.Context) (snapshots []entity.APIStatisticSnapshot, err error) {
+	// statistic of in memory fallback is partial, it is not archived
+	if d.redisBreaker.statistic().State == entity.CircuitStateOpen {
+		return nil, entity.ErrorRedisCircuitOpen
+	}
+
+	d.FlushAPIStatistic()
+
+	snapshots, err = d.snapshotAPIStatistic(ctx, entity.APIStatisticSnapshotReasonReset)
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.statisticRepo.ResetAPIStatistic(ctx)
+	if err != nil {
+		return snapshots, err
+	}
+
+	return snapshots, nil
+}
+
+// GetAPIStatisticHistory return one page of snapshots, limit below 1 default to entity.DefaultAPIStatisticHistoryLimit
+// and limit above entity.MaxAPIStatisticHistoryLimit is rejected, so history never load the whole table
+func (d *domain) GetAPIStatisticHistory(ctx context.Context, param entity.APIStatisticSnapshotParam) (snapshots []entity.APIStatisticSnapshot, err error) {
+	if !param.From.IsZero() && !param.To.IsZero() && param.From.After(param.To) {
+		return snapshots, entity.ErrorAPIStatisticRangeInvalid
+	}
+	if param.Limit < 1 {
+		param.Limit = entity.DefaultAPIStatisticHistoryLimit
+	} else if param.Limit > entity.MaxAPIStatisticHistoryLimit {
+		return snapshots, entity.ErrorAPIStatisticHistoryLimitTooLarge
+	}
+	if param.Page < 1 {
+		param.Page = 1
+	}
+
+	err = d.retry(ctx, func() (err error) {
+		snapshots, err = d.statisticSnapshotReplicaRepo.Find(ctx, param)
+		return err
+	})
+
+	return snapshots, err
+}
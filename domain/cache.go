@@ -0,0 +1,175 @@
+package domain
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"hash/fnv"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/alvinatthariq/farmsvc-go/entity"
+)
+
+const (
+	cacheEntityFarm     = "farm"
+	cacheEntityPond     = "pond"
+	DefaultFarmCacheTTL = 5 * time.Minute
+	DefaultPondCacheTTL = 5 * time.Minute
+)
+
+var cacheEntities = []string{
+	cacheEntityFarm,
+	cacheEntityPond,
+}
+
+func cacheKey(cacheEntity string, id string) string {
+	return cacheEntity + ":" + id
+}
+
+// cacheGenerationStripes is number of invalidation counters shared by cache keys
+const cacheGenerationStripes = 256
+
+// cacheGeneration return invalidation counter of key, it is incremented on every invalidation of keys sharing it
+func (d *domain) cacheGeneration(key string) *atomic.Uint64 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+
+	return &d.cacheGenerations[h.Sum32()%cacheGenerationStripes]
+}
+
+// cacheCounter count cache hit & miss of entity until written by statistic buffer
+type cacheCounter struct {
+	hit  atomic.Int64
+	miss atomic.Int64
+}
+
+func (d *domain) countCache(cacheEntity string, hit bool) {
+	counter, ok := d.cacheCounters[cacheEntity]
+	if !ok {
+		return
+	}
+
+	if hit {
+		counter.hit.Add(1)
+	} else {
+		counter.miss.Add(1)
+	}
+}
+
+// writeCacheStatistics write cache hit & miss counted since last write, count failed to be written is kept for next write
+func (d *domain) writeCacheStatistics() {
+	ctx := context.Background()
+	for cacheEntity, counter := range d.cacheCounters {
+		hit, miss := counter.hit.Swap(0), counter.miss.Swap(0)
+		if hit == 0 && miss == 0 {
+			continue
+		}
+
+		if err := d.statisticRepo.IncrCacheStatistic(ctx, cacheEntity, hit, miss); err != nil {
+			counter.hit.Add(hit)
+			counter.miss.Add(miss)
+			d.logger.WarnContext(ctx, "Failed to write cache statistic", slog.String("entity", cacheEntity), slog.Any("error", err))
+		}
+	}
+}
+
+// readThrough return cached value of cacheEntity id into dest, on cache miss
+// load is called once per key across concurrent callers and its result cached for ttl
+func (d *domain) readThrough(ctx context.Context, cacheEntity string, id string, ttl time.Duration, dest interface{}, load func(ctx context.Context) (interface{}, error)) (found bool, err error) {
+	key := cacheKey(cacheEntity, id)
+
+	if ttl > 0 {
+		raw, err := d.cacheRepo.Get(ctx, key)
+		hit := err == nil && decodeCache(raw, dest) == nil
+		// counted in memory, written by statistic buffer so lookup never wait for redis
+		d.countCache(cacheEntity, hit)
+		if hit {
+			return true, nil
+		}
+	}
+
+	// prevent cache stampede, only one load per key at a time
+	v, err, _ := d.cacheGroup.Do(key, func() (interface{}, error) {
+		// load is shared by concurrent callers, so it is not canceled when the first caller
+		// disconnect, deadline of the first caller still apply
+		loadCtx := context.WithoutCancel(ctx)
+		if deadline, ok := ctx.Deadline(); ok {
+			var cancel context.CancelFunc
+			loadCtx, cancel = context.WithDeadline(loadCtx, deadline)
+			defer cancel()
+		}
+
+		// generation is taken before load, so invalidation landing while value is loaded is detected
+		generation := d.cacheGeneration(key)
+		loaded := generation.Load()
+
+		v, err := load(loadCtx)
+		if err != nil || v == nil {
+			return nil, err
+		}
+
+		raw, err := encodeCache(v)
+		if err != nil {
+			return nil, err
+		}
+
+		if ttl > 0 {
+			// cache is best effort, ignore error
+			d.cacheRepo.Set(loadCtx, key, raw, ttl)
+
+			// value may be read before a write whose invalidation ran before Set, delete it again
+			// so the stale value is not served until ttl pass
+			if generation.Load() != loaded {
+				d.cacheRepo.Del(loadCtx, key)
+			}
+		}
+
+		return raw, nil
+	})
+	if err != nil || v == nil {
+		return false, err
+	}
+
+	return true, decodeCache(v.([]byte), dest)
+}
+
+// encodeCache use gob instead of json, so fields hidden from api response
+// such as soft delete state are kept in cache
+func encodeCache(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+func decodeCache(raw []byte, dest interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(raw)).Decode(dest)
+}
+
+// invalidateCache remove cached value of cacheEntity id, it is called after the entity is written
+// so it is not canceled with ctx, otherwise stale value is served until ttl passed
+func (d *domain) invalidateCache(ctx context.Context, cacheEntity string, id string) {
+	key := cacheKey(cacheEntity, id)
+	d.cacheGeneration(key).Add(1)
+	d.cacheRepo.Del(context.WithoutCancel(ctx), key)
+}
+
+func (d *domain) InvalidateCache(ctx context.Context, resource string, ids []string) {
+	for _, id := range ids {
+		d.invalidateCache(ctx, resource, id)
+	}
+}
+
+func (d *domain) GetCacheStatistic(ctx context.Context) (cacheStatistics []entity.CacheStatistic, err error) {
+	for _, cacheEntity := range cacheEntities {
+		cacheStat, err := d.statisticRepo.GetCacheStatistic(ctx, cacheEntity)
+		if err != nil {
+			return cacheStatistics, err
+		}
+
+		cacheStatistics = append(cacheStatistics, cacheStat)
+	}
+
+	return cacheStatistics, nil
+}
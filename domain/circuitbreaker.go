@@ -0,0 +1,158 @@
+package domain
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/alvinatthariq/farmsvc-go/entity"
+)
+
+const (
+	DefaultRedisFailureThreshold = 5
+	DefaultRedisOpenTimeout      = 30 * time.Second
+)
+
+// circuitBreaker stop calling a failing dependency. Circuit open after failureThreshold consecutive failures,
+// every call is rejected until openTimeout passed, then one trial call decide whether it close or open again
+type circuitBreaker struct {
+	mu               sync.Mutex
+	state            string
+	failures         int64
+	openedAt         time.Time
+	trialInFlight    bool
+	rejected         int64
+	failureThreshold int64
+	openTimeout      time.Duration
+
+	// closeListeners run every time trial call close the circuit
+	closeListeners []func(ctx context.Context)
+}
+
+func newCircuitBreaker(failureThreshold int, openTimeout time.Duration) *circuitBreaker {
+	if failureThreshold < 1 {
+		failureThreshold = DefaultRedisFailureThreshold
+	}
+	if openTimeout <= 0 {
+		openTimeout = DefaultRedisOpenTimeout
+	}
+
+	return &circuitBreaker{
+		state:            entity.CircuitStateClosed,
+		failureThreshold: int64(failureThreshold),
+		openTimeout:      openTimeout,
+	}
+}
+
+// onClose register fn to run once the circuit close again, it run with context of the trial call
+// stripped of its cancellation. Register before circuit breaker is used
+func (b *circuitBreaker) onClose(fn func(ctx context.Context)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closeListeners = append(b.closeListeners, fn)
+}
+
+// allow return whether call may be sent and whether it is the trial call of half open circuit,
+// caller must report result of allowed call with trial
+func (b *circuitBreaker) allow() (trial bool, allowed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case entity.CircuitStateOpen:
+		if time.Now().Sub(b.openedAt) < b.openTimeout {
+			b.rejected++
+			return false, false
+		}
+		b.state = entity.CircuitStateHalfOpen
+		b.trialInFlight = true
+		return true, true
+	case entity.CircuitStateHalfOpen:
+		// only one trial call at a time
+		if b.trialInFlight {
+			b.rejected++
+			return false, false
+		}
+		b.trialInFlight = true
+		return true, true
+	default:
+		return false, true
+	}
+}
+
+// report result of allowed call, error caused by ctx done is not counted as failure
+// since the dependency is not to blame for caller giving up.
+// Only the trial call move circuit out of half open, result of call allowed before circuit opened is ignored
+// once it is no longer closed
+func (b *circuitBreaker) report(ctx context.Context, trial bool, err error) {
+	b.mu.Lock()
+
+	if !trial && b.state != entity.CircuitStateClosed {
+		b.mu.Unlock()
+		return
+	}
+
+	if err != nil && ctx.Err() != nil {
+		if trial {
+			b.trialInFlight = false
+		}
+		b.mu.Unlock()
+		return
+	}
+
+	if err != nil {
+		b.failures++
+		if trial || b.failures >= b.failureThreshold {
+			b.state = entity.CircuitStateOpen
+			b.openedAt = time.Now()
+			b.trialInFlight = false
+		}
+		b.mu.Unlock()
+		return
+	}
+
+	b.failures = 0
+	if !trial {
+		b.mu.Unlock()
+		return
+	}
+
+	b.state = entity.CircuitStateClosed
+	b.trialInFlight = false
+	listeners := b.closeListeners
+	b.mu.Unlock()
+
+	// listeners may call through the circuit again, so they run without the lock
+	for _, listener := range listeners {
+		listener(context.WithoutCancel(ctx))
+	}
+}
+
+func (b *circuitBreaker) isOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state == entity.CircuitStateOpen
+}
+
+func (b *circuitBreaker) statistic() entity.CircuitBreakerStatistic {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stat := entity.CircuitBreakerStatistic{
+		State:    b.state,
+		Failures: b.failures,
+		Rejected: b.rejected,
+	}
+	if !b.openedAt.IsZero() {
+		openedAt := b.openedAt
+		stat.OpenedAt = &openedAt
+	}
+
+	return stat
+}
+
+func (d *domain) GetRedisCircuitBreakerStatistic() entity.CircuitBreakerStatistic {
+	return d.redisBreaker.statistic()
+}
@@ -0,0 +1,392 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/alvinatthariq/farmsvc-go/entity"
+	"github.com/alvinatthariq/farmsvc-go/repository"
+)
+
+// breakerStatistic send statistic call to primary repository while circuit is closed, and to in memory
+// fallback when circuit is open or the call failed, so statistic keep working during redis outage (batch write
+// only use fallback once circuit is open).
+// Statistic recorded to fallback is only served while circuit is open and is not copied back to primary,
+// fallback is replaced by an empty one created by newFallback every time circuit close, so the next outage
+// does not serve count of the previous one
+type breakerStatistic struct {
+	primary     repository.StatisticRepository
+	newFallback func() repository.StatisticRepository
+	breaker     *circuitBreaker
+
+	mu       sync.RWMutex
+	fallback repository.StatisticRepository
+}
+
+func newBreakerStatistic(primary repository.StatisticRepository, newFallback func() repository.StatisticRepository, breaker *circuitBreaker) repository.StatisticRepository {
+	r := &breakerStatistic{
+		primary:     primary,
+		newFallback: newFallback,
+		breaker:     breaker,
+		fallback:    newFallback(),
+	}
+	breaker.onClose(func(ctx context.Context) {
+		r.resetFallback()
+	})
+
+	return r
+}
+
+func (r *breakerStatistic) getFallback() repository.StatisticRepository {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.fallback
+}
+
+func (r *breakerStatistic) resetFallback() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.fallback = r.newFallback()
+}
+
+// call run fn against primary when circuit allow it, otherwise or when primary failed against fallback
+func (r *breakerStatistic) call(ctx context.Context, fn func(repo repository.StatisticRepository) error) error {
+	if trial, allowed := r.breaker.allow(); allowed {
+		err := fn(r.primary)
+		r.breaker.report(ctx, trial, err)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+	}
+
+	return fn(r.getFallback())
+}
+
+// callPrimary run fn against primary only, entity.ErrorRedisCircuitOpen is returned while circuit is open
+func (r *breakerStatistic) callPrimary(ctx context.Context, fn func(repo repository.StatisticRepository) error) error {
+	trial, allowed := r.breaker.allow()
+	if !allowed {
+		return entity.ErrorRedisCircuitOpen
+	}
+
+	err := fn(r.primary)
+	r.breaker.report(ctx, trial, err)
+
+	return err
+}
+
+// RecordAPIStatistics write to fallback only once circuit is open, while it is still closed failed batch is
+// returned to statistic buffer to be written again rather than kept in fallback which is not read then
+func (r *breakerStatistic) RecordAPIStatistics(ctx context.Context, events []entity.APIStatisticEvent) (err error) {
+	if trial, allowed := r.breaker.allow(); allowed {
+		err = r.primary.RecordAPIStatistics(ctx, events)
+		r.breaker.report(ctx, trial, err)
+		if err == nil || ctx.Err() != nil || !r.breaker.isOpen() {
+			return err
+		}
+	}
+
+	return r.getFallback().RecordAPIStatistics(ctx, events)
+}
+
+func (r *breakerStatistic) GetAPIStatisticPaths(ctx context.Context) (apiPaths []string, err error) {
+	err = r.call(ctx, func(repo repository.StatisticRepository) error {
+		apiPaths, err = repo.GetAPIStatisticPaths(ctx)
+		return err
+	})
+
+	return apiPaths, err
+}
+
+func (r *breakerStatistic) GetAPIStatistic(ctx context.Context, apiPath string) (apiStatistic entity.APIStatistic, err error) {
+	err = r.call(ctx, func(repo repository.StatisticRepository) error {
+		apiStatistic, err = repo.GetAPIStatistic(ctx, apiPath)
+		return err
+	})
+
+	return apiStatistic, err
+}
+
+func (r *breakerStatistic) GetAPIStatisticSeries(ctx context.Context, apiPath string, granularity entity.Granularity, from time.Time, to time.Time) (series []entity.APIStatisticPoint, err error) {
+	err = r.call(ctx, func(repo repository.StatisticRepository) error {
+		series, err = repo.GetAPIStatisticSeries(ctx, apiPath, granularity, from, to)
+		return err
+	})
+
+	return series, err
+}
+
+func (r *breakerStatistic) GetClientStatistic(ctx context.Context, top int) (clientStatistic entity.ClientStatistic, err error) {
+	err = r.call(ctx, func(repo repository.StatisticRepository) error {
+		clientStatistic, err = repo.GetClientStatistic(ctx, top)
+		return err
+	})
+
+	return clientStatistic, err
+}
+
+func (r *breakerStatistic) GetResourceStatistic(ctx context.Context, resource string, id string) (resourceStatistic entity.ResourceStatistic, err error) {
+	err = r.call(ctx, func(repo repository.StatisticRepository) error {
+		resourceStatistic, err = repo.GetResourceStatistic(ctx, resource, id)
+		return err
+	})
+
+	return resourceStatistic, err
+}
+
+func (r *breakerStatistic) GetTopResourceStatistics(ctx context.Context, resource string, top int) (resourceStatistics []entity.ResourceStatistic, err error) {
+	err = r.call(ctx, func(repo repository.StatisticRepository) error {
+		resourceStatistics, err = repo.GetTopResourceStatistics(ctx, resource, top)
+		return err
+	})
+
+	return resourceStatistics, err
+}
+
+func (r *breakerStatistic) GetResourceStatistics(ctx context.Context, resource string, ids []string) (resourceStatistics []entity.ResourceStatistic, err error) {
+	err = r.call(ctx, func(repo repository.StatisticRepository) error {
+		resourceStatistics, err = repo.GetResourceStatistics(ctx, resource, ids)
+		return err
+	})
+
+	return resourceStatistics, err
+}
+
+func (r *breakerStatistic) DeleteResourceStatistics(ctx context.Context, resource string, ids []string) (err error) {
+	return r.call(ctx, func(repo repository.StatisticRepository) error {
+		return repo.DeleteResourceStatistics(ctx, resource, ids)
+	})
+}
+
+func (r *breakerStatistic) IncrCacheStatistic(ctx context.Context, cacheEntity string, hit int64, miss int64) (err error) {
+	return r.call(ctx, func(repo repository.StatisticRepository) error {
+		return repo.IncrCacheStatistic(ctx, cacheEntity, hit, miss)
+	})
+}
+
+func (r *breakerStatistic) GetCacheStatistic(ctx context.Context, cacheEntity string) (cacheStatistic entity.CacheStatistic, err error) {
+	err = r.call(ctx, func(repo repository.StatisticRepository) error {
+		cacheStatistic, err = repo.GetCacheStatistic(ctx, cacheEntity)
+		return err
+	})
+
+	return cacheStatistic, err
+}
+
+// ResetAPIStatistic reset primary then fallback, reset is refused while circuit is open
+// so it never report success with primary untouched
+func (r *breakerStatistic) ResetAPIStatistic(ctx context.Context) (err error) {
+	err = r.callPrimary(ctx, func(repo repository.StatisticRepository) error {
+		return repo.ResetAPIStatistic(ctx)
+	})
+	if err != nil {
+		return err
+	}
+
+	return r.getFallback().ResetAPIStatistic(ctx)
+}
+
+// Lock is only taken on primary, lock of in memory fallback is not shared between replicas
+func (r *breakerStatistic) Lock(ctx context.Context, name string, ttl time.Duration) (acquired bool, err error) {
+	err = r.callPrimary(ctx, func(repo repository.StatisticRepository) error {
+		acquired, err = repo.Lock(ctx, name, ttl)
+		return err
+	})
+
+	return acquired, err
+}
+
+const (
+	// maxPendingCacheInvalidations bound key whose delete is kept to be replayed, cache is skipped after recovery
+	// when more were dropped
+	maxPendingCacheInvalidations = 10000
+	// cacheReplayBatchSize is how many pending keys are deleted by one command on replay
+	cacheReplayBatchSize = 500
+)
+
+// breakerCache skip cache while circuit is open, every get is a miss and every write is dropped.
+// Delete which did not reach primary is kept and replayed in background once circuit close, otherwise entry
+// written before the outage would be served stale after it until its ttl passed. Get of pending key is a miss
+// until its delete is replayed. When more than maxPendingCacheInvalidations keys were pending, which of them are
+// stale is unknown, so every get is a miss until the longest ttl written passed after circuit close. Other replicas
+// still read entry whose delete was dropped until its ttl
+type breakerCache struct {
+	primary repository.CacheRepository
+	breaker *circuitBreaker
+	logger  *slog.Logger
+
+	mu sync.Mutex
+	// pending map key to sequence of its latest failed delete, so key deleted again meanwhile stay pending
+	pending   map[string]uint64
+	sequence  uint64
+	replaying bool
+	// dropped count delete not kept since pending was full, maxTTL is longest ttl set so far
+	dropped   int64
+	maxTTL    time.Duration
+	missUntil time.Time
+}
+
+func newBreakerCache(primary repository.CacheRepository, breaker *circuitBreaker, logger *slog.Logger) repository.CacheRepository {
+	r := &breakerCache{
+		primary: primary,
+		breaker: breaker,
+		logger:  logger,
+		pending: map[string]uint64{},
+	}
+	breaker.onClose(r.recover)
+
+	return r
+}
+
+func (r *breakerCache) Get(ctx context.Context, key string) (value []byte, err error) {
+	trial, allowed := r.breaker.allow()
+	if !allowed {
+		return nil, repository.ErrCacheMiss
+	}
+
+	value, err = r.primary.Get(ctx, key)
+	// checked before report, since trial closing the circuit start replay of pending key
+	stale := r.isStale(key)
+	if errors.Is(err, repository.ErrCacheMiss) {
+		r.breaker.report(ctx, trial, nil)
+	} else {
+		r.breaker.report(ctx, trial, err)
+	}
+	if err == nil && stale {
+		return nil, repository.ErrCacheMiss
+	}
+
+	return value, err
+}
+
+func (r *breakerCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) (err error) {
+	r.mu.Lock()
+	r.maxTTL = max(r.maxTTL, ttl)
+	r.mu.Unlock()
+
+	trial, allowed := r.breaker.allow()
+	if !allowed {
+		return entity.ErrorRedisCircuitOpen
+	}
+
+	err = r.primary.Set(ctx, key, value, ttl)
+	r.breaker.report(ctx, trial, err)
+
+	return err
+}
+
+// Del keep keys to be replayed when circuit is open or primary failed
+func (r *breakerCache) Del(ctx context.Context, keys ...string) (err error) {
+	trial, allowed := r.breaker.allow()
+	if !allowed {
+		r.addPending(keys)
+		return entity.ErrorRedisCircuitOpen
+	}
+
+	err = r.primary.Del(ctx, keys...)
+	r.breaker.report(ctx, trial, err)
+	if err != nil {
+		r.addPending(keys)
+	}
+
+	return err
+}
+
+func (r *breakerCache) addPending(keys []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, key := range keys {
+		if _, ok := r.pending[key]; !ok && len(r.pending) >= maxPendingCacheInvalidations {
+			if r.dropped == 0 {
+				r.logger.Warn("Too many pending cache invalidations, cache is skipped after redis recover", slog.Int("max", maxPendingCacheInvalidations))
+			}
+			r.dropped++
+			continue
+		}
+		r.sequence++
+		r.pending[key] = r.sequence
+	}
+}
+
+func (r *breakerCache) isStale(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.pending[key]
+	return ok || r.dropped > 0 || time.Now().Before(r.missUntil)
+}
+
+// recover run once circuit close, cache is skipped for maxTTL when invalidation was dropped and pending delete
+// is replayed in background, so the trial call closing the circuit is not held by it
+func (r *breakerCache) recover(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.dropped > 0 {
+		r.logger.WarnContext(ctx, "Cache invalidations dropped during redis outage, cache skipped until entries expire",
+			slog.Int64("dropped", r.dropped),
+			slog.Duration("skipped_for", r.maxTTL),
+		)
+		r.missUntil = time.Now().Add(r.maxTTL)
+		r.dropped = 0
+	}
+
+	if r.replaying || len(r.pending) < 1 {
+		return
+	}
+	r.replaying = true
+	go r.replayDel(ctx)
+}
+
+// replayDel delete pending keys batch by batch until none is left, it stop on failure and the keys stay pending
+// for next recovery. Key deleted again while its batch is sent stay pending
+func (r *breakerCache) replayDel(ctx context.Context) {
+	for {
+		r.mu.Lock()
+		batch := make(map[string]uint64, cacheReplayBatchSize)
+		for key, sequence := range r.pending {
+			if len(batch) >= cacheReplayBatchSize {
+				break
+			}
+			batch[key] = sequence
+		}
+		if len(batch) < 1 {
+			r.replaying = false
+			r.mu.Unlock()
+			return
+		}
+		r.mu.Unlock()
+
+		keys := make([]string, 0, len(batch))
+		for key := range batch {
+			keys = append(keys, key)
+		}
+		trial, allowed := r.breaker.allow()
+		err := entity.ErrorRedisCircuitOpen
+		if allowed {
+			err = r.primary.Del(ctx, keys...)
+			r.breaker.report(ctx, trial, err)
+		}
+
+		r.mu.Lock()
+		if err != nil {
+			r.replaying = false
+			pending := len(r.pending)
+			r.mu.Unlock()
+			r.logger.WarnContext(ctx, "Failed to replay cache invalidations", slog.Int("pending", pending), slog.Any("error", err))
+			return
+		}
+		for key, sequence := range batch {
+			if r.pending[key] == sequence {
+				delete(r.pending, key)
+			}
+		}
+		r.mu.Unlock()
+	}
+}
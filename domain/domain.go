@@ -1,40 +1,249 @@
 package domain
 
 import (
+	"context"
+	"log/slog"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/alvinatthariq/farmsvc-go/entity"
+	"github.com/alvinatthariq/farmsvc-go/repository"
 
 	"github.com/go-redis/redis"
+	"golang.org/x/sync/singleflight"
 	"gorm.io/gorm"
 )
 
 type domain struct {
-	gorm        *gorm.DB
-	redisClient *redis.Client
+	farmRepo              repository.FarmRepository
+	pondRepo              repository.PondRepository
+	statisticRepo         repository.StatisticRepository
+	statisticSnapshotRepo repository.StatisticSnapshotRepository
+	cacheRepo             repository.CacheRepository
+
+	// farmReplicaRepo, pondReplicaRepo & statisticSnapshotReplicaRepo serve list & report queries,
+	// lookup by id stay on primary so a resource just written is always found
+	farmReplicaRepo              repository.FarmRepository
+	pondReplicaRepo              repository.PondRepository
+	statisticSnapshotReplicaRepo repository.StatisticSnapshotRepository
+
+	idPattern *regexp.Regexp
+	logger    *slog.Logger
+
+	settings func() Settings
+
+	cacheGroup       singleflight.Group
+	cacheGenerations [cacheGenerationStripes]atomic.Uint64
+	cacheCounters    map[string]*cacheCounter
+
+	statisticBuffer *statisticBuffer
+	redisBreaker    *circuitBreaker
+
+	retryAttempts int
+	retryBackoff  time.Duration
+
+	closeOnce    sync.Once
+	snapshotStop chan struct{}
+	snapshotDone chan struct{}
+}
+
+type Options struct {
+	// IDPattern is used to validate client supplied farm & pond id,
+	// entity.DefaultIDPattern is used when nil
+	IDPattern *regexp.Regexp
+
+	// FarmCacheTTL & PondCacheTTL is how long farm & pond are kept in cache,
+	// zero or negative disable the cache
+	FarmCacheTTL time.Duration
+	PondCacheTTL time.Duration
+	// StatisticSampleRatio is fraction of requests recorded in api statistic, zero or above 1 record every request
+	StatisticSampleRatio float64
+	// Settings return cache ttl & statistic sample ratio on every use, so they change without restart.
+	// FarmCacheTTL, PondCacheTTL & StatisticSampleRatio are used when nil
+	Settings func() Settings
+
+	// StatisticBufferSize is max api statistic events waiting to be written, StatisticBatchSize is
+	// max events written at once and StatisticFlushInterval is max wait before written, zero use default
+	StatisticBufferSize    int
+	StatisticBatchSize     int
+	StatisticFlushInterval time.Duration
+
+	// StatisticSnapshotInterval is how often api statistic is snapshotted to sql,
+	// zero use DefaultStatisticSnapshotInterval and negative disable periodic snapshot
+	StatisticSnapshotInterval time.Duration
+
+	// RedisKeyPrefix is prepended to every redis key used by Init
+	RedisKeyPrefix string
+
+	// RedisFailureThreshold is consecutive failed statistic or cache calls which open the circuit,
+	// RedisOpenTimeout is how long statistic & cache skip redis before trying it again, zero use default
+	RedisFailureThreshold int
+	RedisOpenTimeout      time.Duration
+
+	// DatabaseRetryAttempts is max attempts of idempotent database operation failed by transient error
+	// such as deadlock or lost connection, 1 disable retry. DatabaseRetryBackoff is wait before second attempt,
+	// doubled every attempt. Zero use default
+	DatabaseRetryAttempts int
+	DatabaseRetryBackoff  time.Duration
+
+	// ReadReplicas serve list & report queries of Init, replica failing a query is skipped for
+	// ReplicaRecheckInterval and primary serve it meanwhile, zero use repository.DefaultReplicaRecheckInterval
+	ReadReplicas           []*gorm.DB
+	ReplicaRecheckInterval time.Duration
+
+	// Logger is used by background workers, slog.Default is used when nil
+	Logger *slog.Logger
+}
+
+// Settings is part of Options which can change without restart, it is read as a whole so farm & pond ttl
+// and sample ratio always come from the same change. Value already cached keep its ttl
+type Settings struct {
+	FarmCacheTTL         time.Duration
+	PondCacheTTL         time.Duration
+	StatisticSampleRatio float64
 }
 
 type DomainItf interface {
 	// Farm
-	CreateFarm(v entity.CreateFarmRequest) (farm entity.Farm, err error)
-	GetFarmByID(farmID string) (farm *entity.Farm, err error)
-	GetFarm(param entity.FarmParam) (farms []entity.Farm, err error)
-	UpdateFarm(farmID string, v entity.UpdateFarmRequest) (farm entity.Farm, err error)
-	DeleteFarmByID(farmID string) (err error)
+	CreateFarm(ctx context.Context, v entity.CreateFarmRequest) (farm entity.Farm, err error)
+	GetFarmByID(ctx context.Context, farmID string) (farm *entity.Farm, err error)
+	GetFarm(ctx context.Context, param entity.FarmParam) (farms []entity.Farm, err error)
+	CountFarm(ctx context.Context) (count int64, err error)
+	UpdateFarm(ctx context.Context, farmID string, v entity.UpdateFarmRequest) (farm entity.Farm, err error)
+	DeleteFarmByID(ctx context.Context, farmID string) (err error)
+	// ValidateFarm check farm written outside the domain such as restore, as CreateFarm check supplied farm
+	ValidateFarm(farm entity.Farm) error
 
 	// Pond
-	CreatePond(v entity.CreatePondRequest) (pond entity.Pond, err error)
-	GetPondByID(pondID string) (pond *entity.Pond, err error)
-	GetPond(param entity.PondParam) (ponds []entity.Pond, err error)
-	UpdatePond(pondID string, v entity.UpdatePondRequest) (pond entity.Pond, err error)
-	DeletePondByID(pondID string) (err error)
+	CreatePond(ctx context.Context, v entity.CreatePondRequest) (pond entity.Pond, err error)
+	GetPondByID(ctx context.Context, pondID string) (pond *entity.Pond, err error)
+	GetPond(ctx context.Context, param entity.PondParam) (ponds []entity.Pond, err error)
+	CountPond(ctx context.Context) (count int64, err error)
+	UpdatePond(ctx context.Context, pondID string, v entity.UpdatePondRequest) (pond entity.Pond, err error)
+	DeletePondByID(ctx context.Context, pondID string) (err error)
+	// ValidatePond check pond written outside the domain such as restore, as CreatePond check supplied pond
+	ValidatePond(pond entity.Pond) error
 
 	// API Statistic
-	UpsertAPIStatistic(apiPath string, userAgent string) error
-	GetAPIStatistic() (apiStatistics []entity.APIStatistic, err error)
+	RecordAPIStatistic(event entity.APIStatisticEvent) error
+	FlushAPIStatistic()
+	GetAPIStatisticBufferStatistic() entity.APIStatisticBufferStatistic
+	GetAPIStatistic(ctx context.Context, param entity.APIStatisticParam) (apiStatistics []entity.APIStatistic, err error)
+	GetClientStatistic(ctx context.Context, top int) (clientStatistic entity.ClientStatistic, err error)
+	GetFarmStatistic(ctx context.Context, farmID string) (resourceStatistic entity.ResourceStatistic, err error)
+	GetPondStatistic(ctx context.Context, pondID string) (resourceStatistic entity.ResourceStatistic, err error)
+	GetResourceStatistics(ctx context.Context, param entity.ResourceStatisticParam) (resourceStatistics []entity.ResourceStatistic, err error)
+	GetCacheStatistic(ctx context.Context) (cacheStatistics []entity.CacheStatistic, err error)
+	// InvalidateCache remove cached farms or ponds of resource, used after they are written outside the domain such as restore
+	InvalidateCache(ctx context.Context, resource string, ids []string)
+	// GetRedisCircuitBreakerStatistic return state of circuit breaker guarding statistic & cache
+	GetRedisCircuitBreakerStatistic() entity.CircuitBreakerStatistic
+	// GetAPIStatisticHistory return one page of api statistic snapshots matching param
+	GetAPIStatisticHistory(ctx context.Context, param entity.APIStatisticSnapshotParam) (snapshots []entity.APIStatisticSnapshot, err error)
+	// ResetAPIStatistic archive api statistic as snapshot then zero it, return archived snapshots
+	ResetAPIStatistic(ctx context.Context) (snapshots []entity.APIStatisticSnapshot, err error)
+
+	// Close flush buffered statistic and stop background workers
+	Close() error
+}
+
+// Init create domain backed by gorm for farm, pond & statistic snapshot, and redis for statistic & cache
+func Init(gorm *gorm.DB, redisClient *redis.Client, opt Options) DomainItf {
+	if len(opt.ReadReplicas) > 0 {
+		replicas := repository.NewReplicaSet(gorm, opt.ReadReplicas, opt.ReplicaRecheckInterval)
+		return InitWithRepository(repository.NewSQLWithReplica(replicas, redisClient, opt.RedisKeyPrefix), opt)
+	}
+
+	return InitWithRepository(repository.NewSQL(gorm, redisClient, opt.RedisKeyPrefix), opt)
 }
 
-func Init(gorm *gorm.DB, redisClient *redis.Client) DomainItf {
-	return &domain{
-		gorm:        gorm,
-		redisClient: redisClient,
+// InitWithRepository create domain backed by given repository,
+// use repository.NewMemory to run without MySQL & Redis
+func InitWithRepository(repo repository.Repository, opt Options) DomainItf {
+	if opt.IDPattern == nil {
+		opt.IDPattern = regexp.MustCompile(entity.DefaultIDPattern)
 	}
+	if opt.Logger == nil {
+		opt.Logger = slog.Default()
+	}
+	if opt.DatabaseRetryAttempts < 1 {
+		opt.DatabaseRetryAttempts = DefaultDatabaseRetryAttempts
+	}
+	if opt.DatabaseRetryBackoff <= 0 {
+		opt.DatabaseRetryBackoff = DefaultDatabaseRetryBackoff
+	}
+
+	if repo.FarmReplica == nil {
+		repo.FarmReplica = repo.Farm
+	}
+	if repo.PondReplica == nil {
+		repo.PondReplica = repo.Pond
+	}
+	if repo.StatisticSnapshotReplica == nil {
+		repo.StatisticSnapshotReplica = repo.StatisticSnapshot
+	}
+
+	// statistic & cache skip redis while it is failing, farm & pond never depend on it
+	redisBreaker := newCircuitBreaker(opt.RedisFailureThreshold, opt.RedisOpenTimeout)
+
+	d := &domain{
+		farmRepo:              repo.Farm,
+		pondRepo:              repo.Pond,
+		statisticRepo:         newBreakerStatistic(repo.Statistic, repository.NewMemoryStatistic, redisBreaker),
+		statisticSnapshotRepo: repo.StatisticSnapshot,
+		cacheRepo:             newBreakerCache(repo.Cache, redisBreaker, opt.Logger),
+
+		farmReplicaRepo:              repo.FarmReplica,
+		pondReplicaRepo:              repo.PondReplica,
+		statisticSnapshotReplicaRepo: repo.StatisticSnapshotReplica,
+
+		redisBreaker: redisBreaker,
+
+		retryAttempts: opt.DatabaseRetryAttempts,
+		retryBackoff:  opt.DatabaseRetryBackoff,
+		idPattern:     opt.IDPattern,
+		logger:        opt.Logger,
+
+		statisticBuffer: newStatisticBuffer(opt.StatisticBufferSize, opt.StatisticBatchSize, opt.StatisticFlushInterval),
+		cacheCounters:   map[string]*cacheCounter{},
+	}
+	for _, cacheEntity := range cacheEntities {
+		d.cacheCounters[cacheEntity] = &cacheCounter{}
+	}
+	d.settings = opt.Settings
+	if d.settings == nil {
+		settings := Settings{
+			FarmCacheTTL:         opt.FarmCacheTTL,
+			PondCacheTTL:         opt.PondCacheTTL,
+			StatisticSampleRatio: opt.StatisticSampleRatio,
+		}
+		d.settings = func() Settings { return settings }
+	}
+	go d.statisticBuffer.run(d.writeAPIStatistics, d.writeCacheStatistics)
+
+	if opt.StatisticSnapshotInterval == 0 {
+		opt.StatisticSnapshotInterval = DefaultStatisticSnapshotInterval
+	}
+	if opt.StatisticSnapshotInterval > 0 {
+		d.snapshotStop = make(chan struct{})
+		d.snapshotDone = make(chan struct{})
+		go d.runStatisticSnapshot(opt.StatisticSnapshotInterval, d.snapshotStop, d.snapshotDone)
+	}
+
+	return d
+}
+
+func (d *domain) Close() error {
+	d.closeOnce.Do(func() {
+		if d.snapshotStop != nil {
+			close(d.snapshotStop)
+			<-d.snapshotDone
+		}
+
+		d.statisticBuffer.close()
+	})
+
+	return nil
 }
@@ -1,15 +1,24 @@
 package domain_test
 
 import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/alvinatthariq/farmsvc-go/domain"
 	"github.com/alvinatthariq/farmsvc-go/entity"
+	"github.com/alvinatthariq/farmsvc-go/logger"
+	"github.com/alvinatthariq/farmsvc-go/migration"
+	"github.com/alvinatthariq/farmsvc-go/repository"
 
 	"github.com/go-redis/redis"
-	"github.com/gorilla/mux"
 	. "github.com/smartystreets/goconvey/convey"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -18,38 +27,52 @@ import (
 
 var (
 	dbgorm      *gorm.DB
-	router      *mux.Router
 	redisClient *redis.Client
 	err         error
 
-	dom domain.DomainItf
+	repo repository.Repository
+	dom  domain.DomainItf
+	ctx  = context.Background()
 )
 
+// TestMain run tests against in memory repository, set TEST_MYSQL_DSN & TEST_REDIS_HOST
+// to run them against live MySQL & Redis instead
 func TestMain(t *testing.M) {
-	dbgorm, err = gorm.Open(mysql.Open("root:@tcp(127.0.0.1:3307)/farm_db?parseTime=true"), &gorm.Config{
-		NamingStrategy: schema.NamingStrategy{
-			SingularTable: true,
-		},
-	})
-	if err != nil {
-		log.Fatal(err)
-		panic("Cannot connect to DB")
-	}
+	if dsn := os.Getenv("TEST_MYSQL_DSN"); dsn != "" {
+		dbgorm, err = gorm.Open(mysql.Open(dsn), &gorm.Config{
+			NamingStrategy: schema.NamingStrategy{
+				SingularTable: true,
+			},
+		})
+		if err != nil {
+			log.Fatal(err)
+			panic("Cannot connect to DB")
+		}
 
-	dbgorm.AutoMigrate(&entity.Farm{})
-	dbgorm.AutoMigrate(&entity.Pond{})
+		if err := migration.New(dbgorm).Up(); err != nil {
+			log.Fatal(err)
+		}
 
-	redisClient = redis.NewClient(&redis.Options{
-		Addr:     "127.0.0.1:6379",
-		Password: "",
-	})
+		redisClient = redis.NewClient(&redis.Options{
+			Addr:     os.Getenv("TEST_REDIS_HOST"),
+			Password: "",
+		})
 
-	dom = domain.Init(
-		dbgorm,
-		redisClient,
+		repo = repository.NewSQL(dbgorm, redisClient, "farmsvc-test:")
+	} else {
+		repo = repository.NewMemory()
+	}
+
+	dom = domain.InitWithRepository(
+		repo,
+		domain.Options{
+			FarmCacheTTL: domain.DefaultFarmCacheTTL,
+			PondCacheTTL: domain.DefaultPondCacheTTL,
+		},
 	)
 
 	exitVal := t.Run()
+	dom.Close()
 
 	os.Exit(exitVal)
 }
@@ -74,8 +97,7 @@ func TestCreateFarm(t *testing.T) {
 				},
 				prepare: func() {
 					// delete data before create
-					farm := entity.Farm{}
-					dbgorm.Where("id = ?", "integtest").Delete(&farm)
+					repo.Farm.Delete(ctx, "integtest")
 				},
 			},
 			{
@@ -89,14 +111,49 @@ func TestCreateFarm(t *testing.T) {
 				},
 				prepare: func() {},
 			},
+			{
+				testID:   3,
+				testDesc: "Success create farm, generated id",
+				testType: "P",
+				payload: entity.CreateFarmRequest{
+					Name:        "name test",
+					Description: "test",
+				},
+				prepare: func() {},
+			},
+			{
+				testID:   4,
+				testDesc: "fail create farm, invalid id format",
+				testType: "N",
+				payload: entity.CreateFarmRequest{
+					ID:          "integ test/1",
+					Name:        "name test",
+					Description: "test",
+				},
+				prepare: func() {},
+			},
+			{
+				testID:   5,
+				testDesc: "Success create farm, surrounding whitespace of id is trimmed",
+				testType: "P",
+				payload: entity.CreateFarmRequest{
+					ID:          " integtest-trim ",
+					Name:        "name test",
+					Description: "test",
+				},
+				prepare: func() {
+					repo.Farm.Delete(ctx, "integtest-trim")
+				},
+			},
 		}
 
 		for _, tc := range testCases {
 			tc.prepare()
 			t.Logf("%d - [%s] : %s", tc.testID, tc.testType, tc.testDesc)
-			_, err := dom.CreateFarm(tc.payload)
+			farm, err := dom.CreateFarm(ctx, tc.payload)
 			if tc.testType == "P" {
 				So(err, ShouldBeNil)
+				So(farm.ID, ShouldNotContainSubstring, " ")
 			} else {
 				So(err, ShouldNotBeNil)
 			}
@@ -125,7 +182,7 @@ func TestGetFarmByID(t *testing.T) {
 						Name:        "integ-test",
 						Description: "integ-test",
 					}
-					dbgorm.Create(&farm)
+					repo.Farm.Create(ctx, farm)
 				},
 			},
 			{
@@ -140,7 +197,7 @@ func TestGetFarmByID(t *testing.T) {
 		for _, tc := range testCases {
 			tc.prepare()
 			t.Logf("%d - [%s] : %s", tc.testID, tc.testType, tc.testDesc)
-			_, err := dom.GetFarmByID(tc.farmID)
+			_, err := dom.GetFarmByID(ctx, tc.farmID)
 			if tc.testType == "P" {
 				So(err, ShouldBeNil)
 			} else {
@@ -170,7 +227,7 @@ func TestGetFarm(t *testing.T) {
 						Name:        "integ-test",
 						Description: "integ-test",
 					}
-					dbgorm.Create(&farm)
+					repo.Farm.Create(ctx, farm)
 				},
 			},
 		}
@@ -178,7 +235,7 @@ func TestGetFarm(t *testing.T) {
 		for _, tc := range testCases {
 			tc.prepare()
 			t.Logf("%d - [%s] : %s", tc.testID, tc.testType, tc.testDesc)
-			_, err := dom.GetFarm(tc.param)
+			_, err := dom.GetFarm(ctx, tc.param)
 			if tc.testType == "P" {
 				So(err, ShouldBeNil)
 			} else {
@@ -188,6 +245,21 @@ func TestGetFarm(t *testing.T) {
 	})
 }
 
+func TestCountFarm(t *testing.T) {
+	Convey("TestCountFarm", t, FailureHalts, func() {
+		t.Log("1 - [P] : Success count farm")
+		repo.Farm.Create(ctx, entity.Farm{
+			ID:          "integ-count",
+			Name:        "integ-count",
+			Description: "integ-count",
+		})
+
+		count, err := dom.CountFarm(ctx)
+		So(err, ShouldBeNil)
+		So(count, ShouldBeGreaterThanOrEqualTo, 1)
+	})
+}
+
 func TestUpdateFarm(t *testing.T) {
 	Convey("TestUpdateFarm", t, FailureHalts, func() {
 		testCases := []struct {
@@ -221,7 +293,7 @@ func TestUpdateFarm(t *testing.T) {
 						Name:        "integ-test",
 						Description: "integ-test",
 					}
-					dbgorm.Create(&farm)
+					repo.Farm.Create(ctx, farm)
 				},
 			},
 			{
@@ -240,8 +312,7 @@ func TestUpdateFarm(t *testing.T) {
 				},
 				prepare: func() {
 					// delete data before update
-					farm := entity.Farm{}
-					dbgorm.Where("id = ?", "integ-test").Delete(&farm)
+					repo.Farm.Delete(ctx, "integ-test")
 				},
 			},
 		}
@@ -249,7 +320,7 @@ func TestUpdateFarm(t *testing.T) {
 		for _, tc := range testCases {
 			tc.prepare()
 			t.Logf("%d - [%s] : %s", tc.testID, tc.testType, tc.testDesc)
-			_, err := dom.UpdateFarm(tc.in.farmID, tc.in.payload)
+			_, err := dom.UpdateFarm(ctx, tc.in.farmID, tc.in.payload)
 			if tc.testType == "P" {
 				So(err, ShouldBeNil)
 			} else {
@@ -286,7 +357,7 @@ func TestDeleteFarmByID(t *testing.T) {
 						Name:        "integ-test",
 						Description: "integ-test",
 					}
-					dbgorm.Create(&farm)
+					repo.Farm.Create(ctx, farm)
 				},
 			},
 			{
@@ -306,7 +377,7 @@ func TestDeleteFarmByID(t *testing.T) {
 		for _, tc := range testCases {
 			tc.prepare()
 			t.Logf("%d - [%s] : %s", tc.testID, tc.testType, tc.testDesc)
-			err := dom.DeleteFarmByID(tc.in.farmID)
+			err := dom.DeleteFarmByID(ctx, tc.in.farmID)
 			if tc.testType == "P" {
 				So(err, ShouldBeNil)
 			} else {
@@ -337,8 +408,7 @@ func TestCreatePond(t *testing.T) {
 				},
 				prepare: func() {
 					// delete data before create
-					pond := entity.Pond{}
-					dbgorm.Where("id = ?", "integtest").Delete(&pond)
+					repo.Pond.Delete(ctx, "integtest")
 
 					// insert data farm before create pond
 					farm := entity.Farm{
@@ -346,7 +416,7 @@ func TestCreatePond(t *testing.T) {
 						Name:        "integ-test",
 						Description: "integ-test",
 					}
-					dbgorm.Create(&farm)
+					repo.Farm.Create(ctx, farm)
 				},
 			},
 			{
@@ -378,7 +448,7 @@ func TestCreatePond(t *testing.T) {
 						Name:        "integ-test",
 						Description: "integ-test",
 					}
-					dbgorm.Create(&farm)
+					repo.Farm.Create(ctx, farm)
 				},
 			},
 		}
@@ -386,7 +456,7 @@ func TestCreatePond(t *testing.T) {
 		for _, tc := range testCases {
 			tc.prepare()
 			t.Logf("%d - [%s] : %s", tc.testID, tc.testType, tc.testDesc)
-			_, err := dom.CreatePond(tc.payload)
+			_, err := dom.CreatePond(ctx, tc.payload)
 			if tc.testType == "P" {
 				So(err, ShouldBeNil)
 			} else {
@@ -417,7 +487,7 @@ func TestGetPondByID(t *testing.T) {
 						Name:        "integ-test",
 						Description: "integ-test",
 					}
-					dbgorm.Create(&farm)
+					repo.Farm.Create(ctx, farm)
 					// insert data before get
 					pond := entity.Pond{
 						ID:          "integ-test",
@@ -425,7 +495,7 @@ func TestGetPondByID(t *testing.T) {
 						Name:        "integ-test",
 						Description: "integ-test",
 					}
-					dbgorm.Create(&pond)
+					repo.Pond.Create(ctx, pond)
 				},
 			},
 			{
@@ -440,7 +510,7 @@ func TestGetPondByID(t *testing.T) {
 		for _, tc := range testCases {
 			tc.prepare()
 			t.Logf("%d - [%s] : %s", tc.testID, tc.testType, tc.testDesc)
-			_, err := dom.GetPondByID(tc.farmID)
+			_, err := dom.GetPondByID(ctx, tc.farmID)
 			if tc.testType == "P" {
 				So(err, ShouldBeNil)
 			} else {
@@ -470,7 +540,7 @@ func TestGetPond(t *testing.T) {
 						Name:        "integ-test",
 						Description: "integ-test",
 					}
-					dbgorm.Create(&farm)
+					repo.Farm.Create(ctx, farm)
 					// insert data before get
 					pond := entity.Pond{
 						ID:          "integ-test",
@@ -478,7 +548,7 @@ func TestGetPond(t *testing.T) {
 						Name:        "integ-test",
 						Description: "integ-test",
 					}
-					dbgorm.Create(&pond)
+					repo.Pond.Create(ctx, pond)
 				},
 			},
 		}
@@ -486,7 +556,7 @@ func TestGetPond(t *testing.T) {
 		for _, tc := range testCases {
 			tc.prepare()
 			t.Logf("%d - [%s] : %s", tc.testID, tc.testType, tc.testDesc)
-			_, err := dom.GetPond(tc.param)
+			_, err := dom.GetPond(ctx, tc.param)
 			if tc.testType == "P" {
 				So(err, ShouldBeNil)
 			} else {
@@ -530,7 +600,7 @@ func TestUpdatePond(t *testing.T) {
 						Name:        "integ-test",
 						Description: "integ-test",
 					}
-					dbgorm.Create(&farm)
+					repo.Farm.Create(ctx, farm)
 					// insert data pond
 					pond := entity.Pond{
 						ID:          "integ-test",
@@ -538,7 +608,7 @@ func TestUpdatePond(t *testing.T) {
 						Name:        "integ-test",
 						Description: "integ-test",
 					}
-					dbgorm.Create(&pond)
+					repo.Pond.Create(ctx, pond)
 				},
 			},
 			{
@@ -563,11 +633,10 @@ func TestUpdatePond(t *testing.T) {
 						Name:        "integ-test",
 						Description: "integ-test",
 					}
-					dbgorm.Create(&farm)
+					repo.Farm.Create(ctx, farm)
 
 					// delete pond
-					pond := entity.Pond{}
-					dbgorm.Where("id = ?", "integ-test").Delete(&pond)
+					repo.Pond.Delete(ctx, "integ-test")
 				},
 			},
 			{
@@ -592,7 +661,7 @@ func TestUpdatePond(t *testing.T) {
 						Name:        "integ-test",
 						Description: "integ-test",
 					}
-					dbgorm.Create(&farm)
+					repo.Farm.Create(ctx, farm)
 
 					// insert data pond
 					pond := entity.Pond{
@@ -601,7 +670,7 @@ func TestUpdatePond(t *testing.T) {
 						Name:        "integ-test",
 						Description: "integ-test",
 					}
-					dbgorm.Create(&pond)
+					repo.Pond.Create(ctx, pond)
 				},
 			},
 		}
@@ -609,7 +678,7 @@ func TestUpdatePond(t *testing.T) {
 		for _, tc := range testCases {
 			tc.prepare()
 			t.Logf("%d - [%s] : %s", tc.testID, tc.testType, tc.testDesc)
-			_, err := dom.UpdatePond(tc.in.pondID, tc.in.payload)
+			_, err := dom.UpdatePond(ctx, tc.in.pondID, tc.in.payload)
 			if tc.testType == "P" {
 				So(err, ShouldBeNil)
 			} else {
@@ -646,7 +715,7 @@ func TestDeletePondByID(t *testing.T) {
 						Name:        "integ-test",
 						Description: "integ-test",
 					}
-					dbgorm.Create(&farm)
+					repo.Farm.Create(ctx, farm)
 
 					// insert data before create
 					pond := entity.Pond{
@@ -655,7 +724,7 @@ func TestDeletePondByID(t *testing.T) {
 						Name:        "integ-test",
 						Description: "integ-test",
 					}
-					dbgorm.Create(&pond)
+					repo.Pond.Create(ctx, pond)
 				},
 			},
 			{
@@ -675,7 +744,7 @@ func TestDeletePondByID(t *testing.T) {
 		for _, tc := range testCases {
 			tc.prepare()
 			t.Logf("%d - [%s] : %s", tc.testID, tc.testType, tc.testDesc)
-			err := dom.DeletePondByID(tc.in.farmID)
+			err := dom.DeletePondByID(ctx, tc.in.farmID)
 			if tc.testType == "P" {
 				So(err, ShouldBeNil)
 			} else {
@@ -685,45 +754,307 @@ func TestDeletePondByID(t *testing.T) {
 	})
 }
 
-func TestUpsertAPIStatistic(t *testing.T) {
-	Convey("TestUpsertAPIStatistic", t, FailureHalts, func() {
+func TestRecordAPIStatistic(t *testing.T) {
+	Convey("TestRecordAPIStatistic", t, FailureHalts, func() {
 		testCases := []struct {
 			testID   int
 			testType string
 			testDesc string
-			in       struct {
-				apiPath   string
-				userAgent string
-			}
+			event    entity.APIStatisticEvent
 		}{
 			{
 				testID:   1,
-				testDesc: "Success upsert",
+				testDesc: "Success record ok request",
 				testType: "P",
-				in: struct {
-					apiPath   string
-					userAgent string
-				}{
-					apiPath:   "POST /a",
-					userAgent: "agent-a",
+				event: entity.APIStatisticEvent{
+					Path:       "POST /a",
+					UserAgent:  "agent-a",
+					StatusCode: 200,
+					Latency:    20 * time.Millisecond,
+				},
+			},
+			{
+				testID:   2,
+				testDesc: "Success record error request",
+				testType: "P",
+				event: entity.APIStatisticEvent{
+					Path:       "POST /a",
+					UserAgent:  "agent-b",
+					StatusCode: 500,
+					Latency:    3 * time.Second,
 				},
 			},
 		}
 
 		for _, tc := range testCases {
 			t.Logf("%d - [%s] : %s", tc.testID, tc.testType, tc.testDesc)
-			err := dom.UpsertAPIStatistic(tc.in.apiPath, tc.in.userAgent)
+			err := dom.RecordAPIStatistic(tc.event)
 			if tc.testType == "P" {
 				So(err, ShouldBeNil)
 			} else {
 				So(err, ShouldNotBeNil)
 			}
 		}
+
+		dom.FlushAPIStatistic()
+		apiStatistics, err := dom.GetAPIStatistic(ctx, entity.APIStatisticParam{})
+		So(err, ShouldBeNil)
+
+		found := false
+		for _, apiStatistic := range apiStatistics {
+			if apiStatistic.Path != "POST /a" {
+				continue
+			}
+			found = true
+			So(apiStatistic.UniqueUserAgent, ShouldBeGreaterThanOrEqualTo, 2)
+			So(apiStatistic.StatusCount["2xx"], ShouldBeGreaterThanOrEqualTo, 1)
+			So(apiStatistic.StatusCount["5xx"], ShouldBeGreaterThanOrEqualTo, 1)
+			So(apiStatistic.ErrorCount, ShouldBeGreaterThanOrEqualTo, 1)
+			So(apiStatistic.Latency.P99, ShouldBeGreaterThan, apiStatistic.Latency.P50)
+		}
+		So(found, ShouldBeTrue)
+	})
+}
+
+func TestRecordAPIStatisticAfterClose(t *testing.T) {
+	Convey("TestRecordAPIStatisticAfterClose", t, FailureHalts, func() {
+		closedDom := domain.InitWithRepository(repository.NewMemory(), domain.Options{})
+		So(closedDom.Close(), ShouldBeNil)
+
+		t.Log("1 - [N] : Failed record, buffer closed")
+		err := closedDom.RecordAPIStatistic(entity.APIStatisticEvent{Path: "POST /a"})
+		So(err, ShouldEqual, entity.ErrorAPIStatisticBufferClosed)
+		So(closedDom.GetAPIStatisticBufferStatistic().Dropped, ShouldEqual, 1)
+	})
+}
+
+// flakyStatistic fail the first failures batch writes with lost connection
+type flakyStatistic struct {
+	repository.StatisticRepository
+	failures int
+}
+
+func (r *flakyStatistic) RecordAPIStatistics(ctx context.Context, events []entity.APIStatisticEvent) error {
+	if r.failures > 0 {
+		r.failures--
+		return errors.New("dial tcp: connection refused")
+	}
+
+	return r.StatisticRepository.RecordAPIStatistics(ctx, events)
+}
+
+func TestRecordAPIStatisticRetry(t *testing.T) {
+	Convey("TestRecordAPIStatisticRetry", t, FailureHalts, func() {
+		flakyRepo := repository.NewMemory()
+		statisticRepo := &flakyStatistic{StatisticRepository: flakyRepo.Statistic}
+		flakyRepo.Statistic = statisticRepo
+
+		retryDom := domain.InitWithRepository(flakyRepo, domain.Options{RedisFailureThreshold: 100})
+		defer retryDom.Close()
+
+		t.Log("1 - [P] : Success record, failed batch written again")
+		statisticRepo.failures = 1
+		So(retryDom.RecordAPIStatistic(entity.APIStatisticEvent{Path: "GET /r", StatusCode: 200}), ShouldBeNil)
+		retryDom.FlushAPIStatistic()
+		So(retryDom.GetAPIStatisticBufferStatistic().Flushed, ShouldEqual, 1)
+		So(retryDom.GetAPIStatisticBufferStatistic().Failed, ShouldEqual, 0)
+
+		t.Log("2 - [N] : Failed record, batch failed twice is dropped")
+		statisticRepo.failures = 2
+		So(retryDom.RecordAPIStatistic(entity.APIStatisticEvent{Path: "GET /r", StatusCode: 200}), ShouldBeNil)
+		retryDom.FlushAPIStatistic()
+		So(retryDom.GetAPIStatisticBufferStatistic().Flushed, ShouldEqual, 1)
+		So(retryDom.GetAPIStatisticBufferStatistic().Failed, ShouldEqual, 1)
+
+		apiStatistics, err := retryDom.GetAPIStatistic(ctx, entity.APIStatisticParam{})
+		So(err, ShouldBeNil)
+		So(apiStatistics[0].Count, ShouldEqual, 1)
 	})
 }
 
 func TestGetAPIStatistic(t *testing.T) {
 	Convey("TestGetAPIStatistic", t, FailureHalts, func() {
+		statisticDom := domain.InitWithRepository(repository.NewMemory(), domain.Options{})
+		defer statisticDom.Close()
+
+		base := time.Now().UTC().Truncate(time.Hour).Add(-2 * time.Hour)
+		for _, event := range []entity.APIStatisticEvent{
+			{Path: "GET /a", UserAgent: "agent-a", StatusCode: 200, At: base},
+			{Path: "GET /a", UserAgent: "agent-a", StatusCode: 200, At: base.Add(time.Minute)},
+			{Path: "GET /a", UserAgent: "agent-b", StatusCode: 404, At: base.Add(time.Minute + 30*time.Second)},
+			{Path: "GET /a", UserAgent: "agent-b", StatusCode: 500, At: base.Add(61 * time.Minute)},
+			{Path: "GET /b", UserAgent: "agent-a", StatusCode: 200, At: base.Add(time.Minute)},
+			{Path: "GET /b", UserAgent: "agent-a", StatusCode: 200, At: base.Add(-49 * time.Hour)},
+		} {
+			So(statisticDom.RecordAPIStatistic(event), ShouldBeNil)
+		}
+		statisticDom.FlushAPIStatistic()
+
+		point := func(at time.Time, count int64) entity.APIStatisticPoint {
+			return entity.APIStatisticPoint{Timestamp: at, Count: count}
+		}
+
+		testCases := []struct {
+			testID   int
+			testType string
+			testDesc string
+			param    entity.APIStatisticParam
+			expected map[string][]entity.APIStatisticPoint
+		}{
+			{
+				testID:   1,
+				testDesc: "Success get without series",
+				testType: "P",
+				expected: map[string][]entity.APIStatisticPoint{"GET /a": nil, "GET /b": nil},
+			},
+			{
+				testID:   2,
+				testDesc: "Success get with hourly series, to bucket is included",
+				testType: "P",
+				param: entity.APIStatisticParam{
+					Granularity: entity.GranularityHour.Name,
+					From:        base,
+					To:          base.Add(time.Hour),
+				},
+				expected: map[string][]entity.APIStatisticPoint{
+					"GET /a": {point(base, 3), point(base.Add(time.Hour), 1)},
+					"GET /b": {point(base, 1), point(base.Add(time.Hour), 0)},
+				},
+			},
+			{
+				testID:   3,
+				testDesc: "Success get with minute series, from is truncated to its bucket",
+				testType: "P",
+				param: entity.APIStatisticParam{
+					Granularity: entity.GranularityMinute.Name,
+					From:        base.Add(30 * time.Second),
+					To:          base.Add(time.Minute + 59*time.Second),
+				},
+				expected: map[string][]entity.APIStatisticPoint{
+					"GET /a": {point(base, 1), point(base.Add(time.Minute), 2)},
+					"GET /b": {point(base, 0), point(base.Add(time.Minute), 1)},
+				},
+			},
+			{
+				testID:   4,
+				testDesc: "Success get with minute series, bucket past retention is empty",
+				testType: "P",
+				param: entity.APIStatisticParam{
+					Granularity: entity.GranularityMinute.Name,
+					From:        base.Add(-49 * time.Hour),
+					To:          base.Add(-49 * time.Hour),
+				},
+				expected: map[string][]entity.APIStatisticPoint{
+					"GET /a": {point(base.Add(-49*time.Hour), 0)},
+					"GET /b": {point(base.Add(-49*time.Hour), 0)},
+				},
+			},
+			{
+				testID:   5,
+				testDesc: "Failed get, invalid granularity",
+				testType: "N",
+				param: entity.APIStatisticParam{
+					Granularity: "week",
+				},
+			},
+			{
+				testID:   6,
+				testDesc: "Failed get, from after to",
+				testType: "N",
+				param: entity.APIStatisticParam{
+					Granularity: entity.GranularityMinute.Name,
+					From:        time.Now(),
+					To:          time.Now().Add(-time.Hour),
+				},
+			},
+			{
+				testID:   7,
+				testDesc: "Failed get, range too large",
+				testType: "N",
+				param: entity.APIStatisticParam{
+					Granularity: entity.GranularityMinute.Name,
+					From:        time.Now().Add(-48 * time.Hour),
+					To:          time.Now(),
+				},
+			},
+		}
+
+		for _, tc := range testCases {
+			t.Logf("%d - [%s] : %s", tc.testID, tc.testType, tc.testDesc)
+			apiStatistics, err := statisticDom.GetAPIStatistic(ctx, tc.param)
+			if tc.testType == "N" {
+				So(err, ShouldNotBeNil)
+				continue
+			}
+			So(err, ShouldBeNil)
+			So(apiStatistics, ShouldHaveLength, len(tc.expected))
+			for _, apiStatistic := range apiStatistics {
+				So(tc.expected, ShouldContainKey, apiStatistic.Path)
+				So(apiStatistic.Series, ShouldResemble, tc.expected[apiStatistic.Path])
+			}
+		}
+
+		t.Logf("%d - [%s] : %s", 8, "P", "Success count status, error & user agent")
+		apiStatistics, err := statisticDom.GetAPIStatistic(ctx, entity.APIStatisticParam{})
+		So(err, ShouldBeNil)
+		So(apiStatistics[0].Path, ShouldEqual, "GET /a")
+		So(apiStatistics[0].Count, ShouldEqual, 4)
+		So(apiStatistics[0].UniqueUserAgent, ShouldEqual, 2)
+		So(apiStatistics[0].ErrorCount, ShouldEqual, 1)
+		So(apiStatistics[0].StatusCount, ShouldResemble, map[string]int64{"2xx": 2, "4xx": 1, "5xx": 1})
+		So(apiStatistics[1].Path, ShouldEqual, "GET /b")
+		So(apiStatistics[1].Count, ShouldEqual, 2)
+	})
+}
+
+func TestGetClientStatistic(t *testing.T) {
+	Convey("TestGetClientStatistic", t, FailureHalts, func() {
+		for _, event := range []entity.APIStatisticEvent{
+			{Path: "GET /b", UserAgent: "agent-a", ClientID: "partner-a", ClientIP: "10.0.0.1"},
+			{Path: "GET /b", UserAgent: "agent-a", ClientID: "partner-a", ClientIP: "10.0.0.1"},
+			{Path: "GET /b", UserAgent: "agent-a", ClientID: "partner-a", ClientIP: "10.0.0.2"},
+			{Path: "GET /b", UserAgent: "agent-b", ClientID: "partner-b", ClientIP: "10.0.0.3"},
+		} {
+			So(dom.RecordAPIStatistic(event), ShouldBeNil)
+		}
+		dom.FlushAPIStatistic()
+
+		testCases := []struct {
+			testID   int
+			testType string
+			testDesc string
+			top      int
+			expected int
+		}{
+			{
+				testID:   1,
+				testDesc: "Success get top 1",
+				testType: "P",
+				top:      1,
+				expected: 1,
+			},
+			{
+				testID:   2,
+				testDesc: "Success get default top",
+				testType: "P",
+				top:      0,
+				expected: 2,
+			},
+		}
+
+		for _, tc := range testCases {
+			t.Logf("%d - [%s] : %s", tc.testID, tc.testType, tc.testDesc)
+			clientStatistic, err := dom.GetClientStatistic(ctx, tc.top)
+			So(err, ShouldBeNil)
+			So(len(clientStatistic.TopClients), ShouldBeGreaterThanOrEqualTo, tc.expected)
+			So(clientStatistic.TopClients[0].Count, ShouldBeGreaterThanOrEqualTo, 3)
+			So(clientStatistic.UniqueIP, ShouldBeGreaterThanOrEqualTo, 3)
+		}
+	})
+}
+
+func TestGetCacheStatistic(t *testing.T) {
+	Convey("TestGetCacheStatistic", t, FailureHalts, func() {
 		testCases := []struct {
 			testID   int
 			testType string
@@ -738,12 +1069,609 @@ func TestGetAPIStatistic(t *testing.T) {
 
 		for _, tc := range testCases {
 			t.Logf("%d - [%s] : %s", tc.testID, tc.testType, tc.testDesc)
-			_, err := dom.GetAPIStatistic()
+			_, err := dom.GetCacheStatistic(ctx)
 			if tc.testType == "P" {
 				So(err, ShouldBeNil)
 			} else {
 				So(err, ShouldNotBeNil)
 			}
 		}
+
+		t.Log("2 - [P] : Success count hit & miss written by statistic buffer")
+		cacheDom := domain.InitWithRepository(repository.NewMemory(), domain.Options{FarmCacheTTL: time.Minute, PondCacheTTL: time.Minute})
+		defer cacheDom.Close()
+		_, err := cacheDom.CreateFarm(ctx, entity.CreateFarmRequest{ID: "cachestat-farm", Name: "name test", Description: "test"})
+		So(err, ShouldBeNil)
+		for i := 0; i < 3; i++ {
+			_, err = cacheDom.GetFarmByID(ctx, "cachestat-farm")
+			So(err, ShouldBeNil)
+		}
+		cacheDom.FlushAPIStatistic()
+		cacheStatistics, err := cacheDom.GetCacheStatistic(ctx)
+		So(err, ShouldBeNil)
+		So(cacheStatistics[0].Entity, ShouldEqual, "farm")
+		So(cacheStatistics[0].Hit, ShouldEqual, 2)
+		So(cacheStatistics[0].Miss, ShouldEqual, 1)
+	})
+}
+
+// racingFarm run write after reading farm and before returning it, as a write landing while farm is loaded into cache
+type racingFarm struct {
+	repository.FarmRepository
+	write func()
+}
+
+func (r *racingFarm) GetByID(ctx context.Context, farmID string) (*entity.Farm, error) {
+	farm, err := r.FarmRepository.GetByID(ctx, farmID)
+	if r.write != nil {
+		write := r.write
+		r.write = nil
+		write()
+	}
+
+	return farm, err
+}
+
+func TestCacheInvalidatedWhileLoading(t *testing.T) {
+	Convey("TestCacheInvalidatedWhileLoading", t, FailureHalts, func() {
+		racingRepo := repository.NewMemory()
+		farmRepo := &racingFarm{FarmRepository: racingRepo.Farm}
+		racingRepo.Farm = farmRepo
+
+		racingDom := domain.InitWithRepository(racingRepo, domain.Options{FarmCacheTTL: time.Hour})
+		defer racingDom.Close()
+
+		_, err := racingDom.CreateFarm(ctx, entity.CreateFarmRequest{ID: "racing-farm", Name: "old", Description: "test"})
+		So(err, ShouldBeNil)
+
+		t.Log("1 - [P] : Success not cache value read before concurrent write")
+		farmRepo.write = func() {
+			// same as UpdateFarm, which cannot be called here since it read the farm being loaded
+			So(racingRepo.Farm.Save(ctx, entity.Farm{ID: "racing-farm", Name: "new", Description: "test"}), ShouldBeNil)
+			racingDom.InvalidateCache(ctx, "farm", []string{"racing-farm"})
+		}
+		farm, err := racingDom.GetFarmByID(ctx, "racing-farm")
+		So(err, ShouldBeNil)
+		So(farm.Name, ShouldEqual, "old")
+
+		farm, err = racingDom.GetFarmByID(ctx, "racing-farm")
+		So(err, ShouldBeNil)
+		So(farm.Name, ShouldEqual, "new")
+	})
+}
+
+func TestResetAPIStatistic(t *testing.T) {
+	Convey("TestResetAPIStatistic", t, FailureHalts, func() {
+		resetDom := domain.InitWithRepository(repository.NewMemory(), domain.Options{})
+		defer resetDom.Close()
+
+		So(resetDom.RecordAPIStatistic(entity.APIStatisticEvent{Path: "GET /c", StatusCode: 200}), ShouldBeNil)
+		So(resetDom.RecordAPIStatistic(entity.APIStatisticEvent{Path: "GET /c", StatusCode: 500}), ShouldBeNil)
+
+		t.Log("1 - [P] : Success reset, statistic archived before zeroed")
+		snapshots, err := resetDom.ResetAPIStatistic(ctx)
+		So(err, ShouldBeNil)
+		So(len(snapshots), ShouldEqual, 1)
+		So(snapshots[0].Count, ShouldEqual, 2)
+		So(snapshots[0].Status5xx, ShouldEqual, 1)
+		So(snapshots[0].Reason, ShouldEqual, entity.APIStatisticSnapshotReasonReset)
+
+		apiStatistics, err := resetDom.GetAPIStatistic(ctx, entity.APIStatisticParam{})
+		So(err, ShouldBeNil)
+		So(len(apiStatistics), ShouldEqual, 0)
+
+		t.Log("2 - [P] : Success get history")
+		history, err := resetDom.GetAPIStatisticHistory(ctx, entity.APIStatisticSnapshotParam{Path: "GET /c"})
+		So(err, ShouldBeNil)
+		So(len(history), ShouldEqual, 1)
+
+		t.Log("3 - [N] : Failed get history, from after to")
+		_, err = resetDom.GetAPIStatisticHistory(ctx, entity.APIStatisticSnapshotParam{
+			From: time.Now(),
+			To:   time.Now().Add(-time.Hour),
+		})
+		So(err, ShouldEqual, entity.ErrorAPIStatisticRangeInvalid)
+
+		t.Log("4 - [N] : Failed get history, limit above max")
+		_, err = resetDom.GetAPIStatisticHistory(ctx, entity.APIStatisticSnapshotParam{Limit: entity.MaxAPIStatisticHistoryLimit + 1})
+		So(err, ShouldEqual, entity.ErrorAPIStatisticHistoryLimitTooLarge)
+	})
+}
+
+func TestGetResourceStatistics(t *testing.T) {
+	Convey("TestGetResourceStatistics", t, FailureHalts, func() {
+		repo.Farm.Delete(ctx, "resstat-farm")
+		So(repo.Farm.Create(ctx, entity.Farm{ID: "resstat-farm", Name: "name test", Description: "test"}), ShouldBeNil)
+
+		accessedAt := time.Now().Add(-48 * time.Hour)
+		for _, access := range []string{entity.ResourceAccessRead, entity.ResourceAccessRead, entity.ResourceAccessWrite} {
+			So(dom.RecordAPIStatistic(entity.APIStatisticEvent{
+				Path:           "GET /v1/farm/{id}",
+				At:             accessedAt,
+				Resource:       entity.ResourceFarm,
+				ResourceID:     "resstat-farm",
+				ResourceAccess: access,
+			}), ShouldBeNil)
+		}
+		dom.FlushAPIStatistic()
+
+		t.Log("1 - [P] : Success get farm statistic")
+		resourceStatistic, err := dom.GetFarmStatistic(ctx, "resstat-farm")
+		So(err, ShouldBeNil)
+		So(resourceStatistic.Read, ShouldBeGreaterThanOrEqualTo, 2)
+		So(resourceStatistic.Write, ShouldBeGreaterThanOrEqualTo, 1)
+		So(resourceStatistic.LastAccessAt, ShouldNotBeNil)
+
+		t.Log("2 - [N] : Failed get farm statistic, farm not found")
+		_, err = dom.GetFarmStatistic(ctx, "resstat-unknown")
+		So(err, ShouldEqual, entity.ErrorFarmNotFound)
+
+		testCases := []struct {
+			testID   int
+			testType string
+			testDesc string
+			param    entity.ResourceStatisticParam
+		}{
+			{
+				testID:   3,
+				testDesc: "Success get most accessed farms",
+				testType: "P",
+				param:    entity.ResourceStatisticParam{Resource: entity.ResourceFarm},
+			},
+			{
+				testID:   4,
+				testDesc: "Success get dormant farms",
+				testType: "P",
+				param:    entity.ResourceStatisticParam{Resource: entity.ResourceFarm, DormantSince: time.Now().Add(-24 * time.Hour)},
+			},
+			{
+				testID:   5,
+				testDesc: "Failed get, invalid resource",
+				testType: "N",
+				param:    entity.ResourceStatisticParam{Resource: "barn"},
+			},
+		}
+
+		for _, tc := range testCases {
+			t.Logf("%d - [%s] : %s", tc.testID, tc.testType, tc.testDesc)
+			resourceStatistics, err := dom.GetResourceStatistics(ctx, tc.param)
+			if tc.testType == "P" {
+				So(err, ShouldBeNil)
+
+				found := false
+				for _, resourceStatistic := range resourceStatistics {
+					if resourceStatistic.ID == "resstat-farm" {
+						found = true
+					}
+				}
+				So(found, ShouldBeTrue)
+			} else {
+				So(err, ShouldNotBeNil)
+			}
+		}
+
+		repo.Farm.Delete(ctx, "resstat-farm")
+	})
+}
+
+func TestDormantResourceStatistics(t *testing.T) {
+	Convey("TestDormantResourceStatistics", t, FailureHalts, func() {
+		dormantRepo := repository.NewMemory()
+		dormantDom := domain.InitWithRepository(dormantRepo, domain.Options{})
+		defer dormantDom.Close()
+
+		now := time.Now().UTC()
+		for _, farm := range []entity.Farm{
+			{ID: "dormant-never", CreatedAt: now.Add(-72 * time.Hour)},
+			{ID: "dormant-old", CreatedAt: now.Add(-72 * time.Hour)},
+			{ID: "dormant-recent", CreatedAt: now.Add(-72 * time.Hour)},
+			{ID: "dormant-new", CreatedAt: now.Add(-time.Hour)},
+			{ID: "dormant-deleted", CreatedAt: now.Add(-72 * time.Hour)},
+		} {
+			So(dormantRepo.Farm.Create(ctx, farm), ShouldBeNil)
+		}
+		access := func(id string, at time.Time) {
+			So(dormantDom.RecordAPIStatistic(entity.APIStatisticEvent{
+				Path:           "GET /v1/farm/{id}",
+				At:             at,
+				Resource:       entity.ResourceFarm,
+				ResourceID:     id,
+				ResourceAccess: entity.ResourceAccessRead,
+			}), ShouldBeNil)
+		}
+		access("dormant-old", now.Add(-48*time.Hour))
+		access("dormant-recent", now)
+		access("dormant-deleted", now.Add(-48*time.Hour))
+		dormantDom.FlushAPIStatistic()
+		ids := func(resourceStatistics []entity.ResourceStatistic) (ids []string) {
+			for _, resourceStatistic := range resourceStatistics {
+				ids = append(ids, resourceStatistic.ID)
+			}
+			return ids
+		}
+
+		t.Log("1 - [P] : Success get dormant farms, never accessed farm included")
+		resourceStatistics, err := dormantDom.GetResourceStatistics(ctx, entity.ResourceStatisticParam{
+			Resource:     entity.ResourceFarm,
+			DormantSince: now.Add(-24 * time.Hour),
+		})
+		So(err, ShouldBeNil)
+		So(ids(resourceStatistics), ShouldResemble, []string{"dormant-never", "dormant-deleted", "dormant-old"})
+
+		t.Log("2 - [P] : Success delete farm, its statistic is removed")
+		So(dormantDom.DeleteFarmByID(ctx, "dormant-deleted"), ShouldBeNil)
+		resourceStatistic, err := dormantRepo.Statistic.GetResourceStatistic(ctx, entity.ResourceFarm, "dormant-deleted")
+		So(err, ShouldBeNil)
+		So(resourceStatistic.Read, ShouldEqual, 0)
+
+		resourceStatistics, err = dormantDom.GetResourceStatistics(ctx, entity.ResourceStatisticParam{
+			Resource:     entity.ResourceFarm,
+			DormantSince: now.Add(-24 * time.Hour),
+			Top:          1,
+		})
+		So(err, ShouldBeNil)
+		So(ids(resourceStatistics), ShouldResemble, []string{"dormant-never"})
+
+		t.Log("3 - [P] : Success get most accessed farms, access buffered before delete not listed")
+		access("dormant-deleted", now)
+		dormantDom.FlushAPIStatistic()
+		resourceStatistics, err = dormantDom.GetResourceStatistics(ctx, entity.ResourceStatisticParam{Resource: entity.ResourceFarm})
+		So(err, ShouldBeNil)
+		So(ids(resourceStatistics), ShouldResemble, []string{"dormant-recent", "dormant-old"})
+	})
+}
+
+// unavailableStatistic & unavailableCache fail every call as redis down
+type unavailableStatistic struct {
+	repository.StatisticRepository
+}
+
+func (r unavailableStatistic) RecordAPIStatistics(ctx context.Context, events []entity.APIStatisticEvent) error {
+	return errors.New("dial tcp: connection refused")
+}
+
+func (r unavailableStatistic) GetAPIStatisticPaths(ctx context.Context) ([]string, error) {
+	return nil, errors.New("dial tcp: connection refused")
+}
+
+func (r unavailableStatistic) IncrCacheStatistic(ctx context.Context, cacheEntity string, hit int64, miss int64) error {
+	return errors.New("dial tcp: connection refused")
+}
+
+type unavailableCache struct{}
+
+func (r unavailableCache) Get(ctx context.Context, key string) ([]byte, error) {
+	return nil, errors.New("dial tcp: connection refused")
+}
+
+func (r unavailableCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return errors.New("dial tcp: connection refused")
+}
+
+func (r unavailableCache) Del(ctx context.Context, keys ...string) error {
+	return errors.New("dial tcp: connection refused")
+}
+
+func TestRedisCircuitBreaker(t *testing.T) {
+	Convey("TestRedisCircuitBreaker", t, FailureHalts, func() {
+		unavailableRepo := repository.NewMemory()
+		unavailableRepo.Statistic = unavailableStatistic{StatisticRepository: unavailableRepo.Statistic}
+		unavailableRepo.Cache = unavailableCache{}
+
+		breakerDom := domain.InitWithRepository(unavailableRepo, domain.Options{
+			FarmCacheTTL:          domain.DefaultFarmCacheTTL,
+			RedisFailureThreshold: 2,
+			RedisOpenTimeout:      time.Hour,
+		})
+		defer breakerDom.Close()
+
+		t.Log("1 - [P] : Success farm crud while redis down")
+		_, err := breakerDom.CreateFarm(ctx, entity.CreateFarmRequest{ID: "breaker-farm", Name: "name test", Description: "test"})
+		So(err, ShouldBeNil)
+		farm, err := breakerDom.GetFarmByID(ctx, "breaker-farm")
+		So(err, ShouldBeNil)
+		So(farm, ShouldNotBeNil)
+		So(breakerDom.GetRedisCircuitBreakerStatistic().State, ShouldEqual, entity.CircuitStateOpen)
+
+		t.Log("2 - [P] : Success get api statistic from fallback while circuit open")
+		So(breakerDom.RecordAPIStatistic(entity.APIStatisticEvent{Path: "GET /d", StatusCode: 200}), ShouldBeNil)
+		breakerDom.FlushAPIStatistic()
+		apiStatistics, err := breakerDom.GetAPIStatistic(ctx, entity.APIStatisticParam{})
+		So(err, ShouldBeNil)
+		So(len(apiStatistics), ShouldEqual, 1)
+		So(apiStatistics[0].Count, ShouldEqual, 1)
+		So(breakerDom.GetAPIStatisticBufferStatistic().Failed, ShouldEqual, 0)
+		So(breakerDom.GetRedisCircuitBreakerStatistic().Rejected, ShouldBeGreaterThan, 0)
+
+		t.Log("3 - [N] : Failed reset while circuit open")
+		_, err = breakerDom.ResetAPIStatistic(ctx)
+		So(err, ShouldEqual, entity.ErrorRedisCircuitOpen)
+	})
+}
+
+func TestDomainSettings(t *testing.T) {
+	Convey("TestDomainSettings", t, FailureHalts, func() {
+		settings := &atomic.Pointer[domain.Settings]{}
+		settings.Store(&domain.Settings{StatisticSampleRatio: 1})
+		settingsDom := domain.InitWithRepository(repository.NewMemory(), domain.Options{
+			Settings:                  func() domain.Settings { return *settings.Load() },
+			StatisticFlushInterval:    time.Hour,
+			StatisticSnapshotInterval: -1,
+		})
+		defer settingsDom.Close()
+		count := func() int64 {
+			settingsDom.FlushAPIStatistic()
+			apiStatistics, err := settingsDom.GetAPIStatistic(ctx, entity.APIStatisticParam{})
+			So(err, ShouldBeNil)
+			if len(apiStatistics) == 0 {
+				return 0
+			}
+			return apiStatistics[0].Count
+		}
+
+		t.Log("1 - [P] : Success record every request")
+		for i := 0; i < 100; i++ {
+			So(settingsDom.RecordAPIStatistic(entity.APIStatisticEvent{Path: "GET /sampled", StatusCode: 200}), ShouldBeNil)
+		}
+		So(count(), ShouldEqual, 100)
+
+		t.Log("2 - [P] : Success record fraction of request once sample ratio change")
+		settings.Store(&domain.Settings{StatisticSampleRatio: 0.2})
+		for i := 0; i < 1000; i++ {
+			So(settingsDom.RecordAPIStatistic(entity.APIStatisticEvent{Path: "GET /sampled", StatusCode: 200}), ShouldBeNil)
+		}
+		sampled := count() - 100
+		So(sampled, ShouldBeBetween, 100, 300)
+		So(settingsDom.GetAPIStatisticBufferStatistic().Dropped, ShouldEqual, 0)
+	})
+}
+
+func TestRequestLogContext(t *testing.T) {
+	Convey("TestRequestLogContext", t, FailureHalts, func() {
+		logs := &bytes.Buffer{}
+		down := &atomic.Bool{}
+		logRepo := repository.NewMemory()
+		logRepo.Statistic = switchableStatistic{StatisticRepository: logRepo.Statistic, down: down}
+		logDom := domain.InitWithRepository(logRepo, domain.Options{
+			Logger:                    logger.New(logger.Options{Output: logs}),
+			StatisticSnapshotInterval: -1,
+		})
+		defer logDom.Close()
+
+		requestCtx := logger.WithRequestID(ctx, "req-1")
+		_, err := logDom.CreateFarm(requestCtx, entity.CreateFarmRequest{ID: "farm-1", Name: "alpha", Description: "first"})
+		So(err, ShouldBeNil)
+
+		t.Log("1 - [P] : Success log failed statistic delete with request id")
+		// request canceled meanwhile, statistic is not written to fallback so the failure is logged
+		canceledCtx, cancel := context.WithCancel(requestCtx)
+		cancel()
+		down.Store(true)
+		So(logDom.DeleteFarmByID(canceledCtx, "farm-1"), ShouldBeNil)
+		So(logs.String(), ShouldContainSubstring, `"msg":"Failed to delete resource statistic"`)
+		So(logs.String(), ShouldContainSubstring, `"request_id":"req-1"`)
+	})
+}
+
+// switchableStatistic & switchableCache fail every call as redis down while down is set,
+// GetAPIStatisticPaths signal waiting then wait for release when they are not nil
+type switchableStatistic struct {
+	repository.StatisticRepository
+	down    *atomic.Bool
+	waiting chan struct{}
+	release chan struct{}
+}
+
+func (r switchableStatistic) RecordAPIStatistics(ctx context.Context, events []entity.APIStatisticEvent) error {
+	if r.down.Load() {
+		return errors.New("dial tcp: connection refused")
+	}
+	return r.StatisticRepository.RecordAPIStatistics(ctx, events)
+}
+
+func (r switchableStatistic) DeleteResourceStatistics(ctx context.Context, resource string, ids []string) error {
+	if r.down.Load() {
+		return errors.New("dial tcp: connection refused")
+	}
+	return r.StatisticRepository.DeleteResourceStatistics(ctx, resource, ids)
+}
+
+func (r switchableStatistic) GetAPIStatisticPaths(ctx context.Context) ([]string, error) {
+	if r.release != nil {
+		r.waiting <- struct{}{}
+		<-r.release
+	}
+	if r.down.Load() {
+		return nil, errors.New("dial tcp: connection refused")
+	}
+	return r.StatisticRepository.GetAPIStatisticPaths(ctx)
+}
+
+type switchableCache struct {
+	repository.CacheRepository
+	down *atomic.Bool
+}
+
+func (r switchableCache) Get(ctx context.Context, key string) ([]byte, error) {
+	if r.down.Load() {
+		return nil, errors.New("dial tcp: connection refused")
+	}
+	return r.CacheRepository.Get(ctx, key)
+}
+
+func (r switchableCache) Del(ctx context.Context, keys ...string) error {
+	if r.down.Load() {
+		return errors.New("dial tcp: connection refused")
+	}
+	return r.CacheRepository.Del(ctx, keys...)
+}
+
+func TestRedisCircuitBreakerRecover(t *testing.T) {
+	Convey("TestRedisCircuitBreakerRecover", t, FailureHalts, func() {
+		down := &atomic.Bool{}
+		recoverRepo := repository.NewMemory()
+		recoverRepo.Statistic = switchableStatistic{StatisticRepository: recoverRepo.Statistic, down: down}
+		recoverRepo.Cache = switchableCache{CacheRepository: recoverRepo.Cache, down: down}
+		openTimeout := 20 * time.Millisecond
+
+		recoverDom := domain.InitWithRepository(recoverRepo, domain.Options{
+			FarmCacheTTL:              domain.DefaultFarmCacheTTL,
+			RedisFailureThreshold:     1,
+			RedisOpenTimeout:          openTimeout,
+			StatisticFlushInterval:    time.Hour,
+			StatisticSnapshotInterval: -1,
+		})
+		defer recoverDom.Close()
+		recordAPIStatistic := func() {
+			So(recoverDom.RecordAPIStatistic(entity.APIStatisticEvent{Path: "GET /recover", StatusCode: 200}), ShouldBeNil)
+			recoverDom.FlushAPIStatistic()
+		}
+
+		t.Log("1 - [P] : Success update farm while redis down, cache invalidation replayed once circuit close")
+		_, err := recoverDom.CreateFarm(ctx, entity.CreateFarmRequest{ID: "recover-farm", Name: "before", Description: "test"})
+		So(err, ShouldBeNil)
+		farm, err := recoverDom.GetFarmByID(ctx, "recover-farm")
+		So(err, ShouldBeNil)
+		So(farm.Name, ShouldEqual, "before")
+
+		down.Store(true)
+		_, err = recoverDom.UpdateFarm(ctx, "recover-farm", entity.UpdateFarmRequest{Name: "after", Description: "test"})
+		So(err, ShouldBeNil)
+		So(recoverDom.GetRedisCircuitBreakerStatistic().State, ShouldEqual, entity.CircuitStateOpen)
+
+		down.Store(false)
+		time.Sleep(openTimeout)
+		farm, err = recoverDom.GetFarmByID(ctx, "recover-farm")
+		So(err, ShouldBeNil)
+		So(farm.Name, ShouldEqual, "after")
+		So(recoverDom.GetRedisCircuitBreakerStatistic().State, ShouldEqual, entity.CircuitStateClosed)
+		farm, err = recoverDom.GetFarmByID(ctx, "recover-farm")
+		So(err, ShouldBeNil)
+		So(farm.Name, ShouldEqual, "after")
+
+		t.Log("2 - [P] : Success record to fallback once circuit open, fallback emptied once circuit close")
+		down.Store(true)
+		recordAPIStatistic()
+		recordAPIStatistic()
+		So(recoverDom.GetRedisCircuitBreakerStatistic().State, ShouldEqual, entity.CircuitStateOpen)
+		apiStatistics, err := recoverDom.GetAPIStatistic(ctx, entity.APIStatisticParam{})
+		So(err, ShouldBeNil)
+		So(apiStatistics, ShouldHaveLength, 1)
+		So(apiStatistics[0].Count, ShouldEqual, 2)
+
+		down.Store(false)
+		time.Sleep(openTimeout)
+		apiStatistics, err = recoverDom.GetAPIStatistic(ctx, entity.APIStatisticParam{})
+		So(err, ShouldBeNil)
+		So(apiStatistics, ShouldBeEmpty)
+		So(recoverDom.GetRedisCircuitBreakerStatistic().State, ShouldEqual, entity.CircuitStateClosed)
+
+		down.Store(true)
+		recordAPIStatistic()
+		recordAPIStatistic()
+		apiStatistics, err = recoverDom.GetAPIStatistic(ctx, entity.APIStatisticParam{})
+		So(err, ShouldBeNil)
+		So(apiStatistics, ShouldHaveLength, 1)
+		So(apiStatistics[0].Count, ShouldEqual, 2)
+
+		t.Log("3 - [P] : Success skip cache once circuit close, invalidation dropped while too many were pending")
+		down.Store(false)
+		time.Sleep(openTimeout)
+		farm, err = recoverDom.GetFarmByID(ctx, "recover-farm")
+		So(err, ShouldBeNil)
+		So(farm.Name, ShouldEqual, "after")
+
+		down.Store(true)
+		ids := make([]string, 10000)
+		for i := range ids {
+			ids[i] = fmt.Sprintf("pending-farm-%d", i)
+		}
+		recoverDom.InvalidateCache(ctx, "farm", ids)
+		_, err = recoverDom.UpdateFarm(ctx, "recover-farm", entity.UpdateFarmRequest{Name: "overflow", Description: "test"})
+		So(err, ShouldBeNil)
+
+		down.Store(false)
+		time.Sleep(openTimeout)
+		farm, err = recoverDom.GetFarmByID(ctx, "recover-farm")
+		So(err, ShouldBeNil)
+		So(farm.Name, ShouldEqual, "overflow")
+		So(recoverDom.GetRedisCircuitBreakerStatistic().State, ShouldEqual, entity.CircuitStateClosed)
+		farm, err = recoverDom.GetFarmByID(ctx, "recover-farm")
+		So(err, ShouldBeNil)
+		So(farm.Name, ShouldEqual, "overflow")
+	})
+}
+
+func TestRedisCircuitBreakerTrial(t *testing.T) {
+	Convey("TestRedisCircuitBreakerTrial", t, FailureHalts, func() {
+		down := &atomic.Bool{}
+		waiting, release := make(chan struct{}), make(chan struct{})
+		trialRepo := repository.NewMemory()
+		trialRepo.Statistic = switchableStatistic{StatisticRepository: trialRepo.Statistic, down: down, waiting: waiting, release: release}
+
+		trialDom := domain.InitWithRepository(trialRepo, domain.Options{
+			RedisFailureThreshold:     1,
+			RedisOpenTimeout:          time.Hour,
+			StatisticFlushInterval:    time.Hour,
+			StatisticSnapshotInterval: -1,
+		})
+		defer trialDom.Close()
+
+		t.Log("1 - [P] : Success keep circuit open, call sent before it opened does not close it")
+		done := make(chan error)
+		go func() {
+			_, err := trialDom.GetAPIStatistic(ctx, entity.APIStatisticParam{})
+			done <- err
+		}()
+		<-waiting
+
+		down.Store(true)
+		So(trialDom.RecordAPIStatistic(entity.APIStatisticEvent{Path: "GET /trial", StatusCode: 200}), ShouldBeNil)
+		trialDom.FlushAPIStatistic()
+		So(trialDom.GetRedisCircuitBreakerStatistic().State, ShouldEqual, entity.CircuitStateOpen)
+
+		down.Store(false)
+		close(release)
+		So(<-done, ShouldBeNil)
+		So(trialDom.GetRedisCircuitBreakerStatistic().State, ShouldEqual, entity.CircuitStateOpen)
+	})
+}
+
+// flakyFarm fail the first failures calls with lost connection
+type flakyFarm struct {
+	repository.FarmRepository
+	failures int
+}
+
+func (r *flakyFarm) Find(ctx context.Context, param entity.FarmParam) ([]entity.Farm, error) {
+	if r.failures > 0 {
+		r.failures--
+		return nil, driver.ErrBadConn
+	}
+
+	return r.FarmRepository.Find(ctx, param)
+}
+
+func TestDatabaseRetry(t *testing.T) {
+	Convey("TestDatabaseRetry", t, FailureHalts, func() {
+		flakyRepo := repository.NewMemory()
+		farmRepo := &flakyFarm{FarmRepository: flakyRepo.Farm}
+		flakyRepo.Farm = farmRepo
+
+		retryDom := domain.InitWithRepository(flakyRepo, domain.Options{
+			DatabaseRetryAttempts: 3,
+			DatabaseRetryBackoff:  time.Millisecond,
+		})
+		defer retryDom.Close()
+
+		t.Log("1 - [P] : Success get farm, transient error retried")
+		farmRepo.failures = 2
+		_, err := retryDom.GetFarm(ctx, entity.FarmParam{Page: 1, Limit: 10})
+		So(err, ShouldBeNil)
+
+		t.Log("2 - [N] : Failed get farm, attempts run out")
+		farmRepo.failures = 3
+		_, err = retryDom.GetFarm(ctx, entity.FarmParam{Page: 1, Limit: 10})
+		So(err, ShouldEqual, driver.ErrBadConn)
 	})
 }
@@ -1,23 +1,31 @@
 package domain
 
 import (
+	"context"
 	"database/sql"
-	"errors"
+	"strings"
 	"time"
 
 	"github.com/alvinatthariq/farmsvc-go/entity"
-	"github.com/go-sql-driver/mysql"
-	"gorm.io/gorm"
 )
 
-func (d *domain) CreateFarm(v entity.CreateFarmRequest) (farm entity.Farm, err error) {
+func (d *domain) CreateFarm(ctx context.Context, v entity.CreateFarmRequest) (farm entity.Farm, err error) {
+	// id is stored as validated, surrounding whitespace is not part of it
+	v.ID = strings.TrimSpace(v.ID)
+	if v.ID == "" {
+		// generate id if not supplied by client
+		v.ID = newID()
+	} else if !d.isIDFormatValid(v.ID) {
+		return farm, entity.ErrorFarmIDInvalidFormat
+	}
+
 	// create farm
 	farm = entity.Farm{
 		ID:          v.ID,
 		Name:        v.Name,
 		Description: v.Description,
-		CreatedAt:   time.Now().UTC(),
-		UpdatedAt:   time.Now().UTC(),
+		CreatedAt:   nowOr(v.CreatedAt),
+		UpdatedAt:   nowOr(v.UpdatedAt),
 	}
 
 	err = farm.Validate()
@@ -26,39 +34,42 @@ func (d *domain) CreateFarm(v entity.CreateFarmRequest) (farm entity.Farm, err e
 	}
 
 	// create to db
-	err = d.gorm.Create(&farm).Error
+	err = d.farmRepo.Create(ctx, farm)
 	if err != nil {
-		var mysqlError *mysql.MySQLError
-		if errors.As(err, &mysqlError) {
-			// check duplicate constraint
-			if mysqlError.Number == entity.CodeMySQLDuplicateEntry {
-				return farm, entity.ErrorFarmAlreadyExist
-			}
-		}
+		return farm, err
 	}
+	d.invalidateCache(ctx, cacheEntityFarm, farm.ID)
 
-	return farm, err
+	return farm, nil
 }
 
-func (d *domain) GetFarmByID(farmID string) (farm *entity.Farm, err error) {
-	// get from db
-	err = d.gorm.First(&farm, "id = ?", farmID).Error
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		return nil, nil
+func (d *domain) GetFarmByID(ctx context.Context, farmID string) (farm *entity.Farm, err error) {
+	found, err := d.readThrough(ctx, cacheEntityFarm, farmID, d.settings().FarmCacheTTL, &farm, func(ctx context.Context) (interface{}, error) {
+		// get from db
+		var farm *entity.Farm
+		err := d.retry(ctx, func() (err error) {
+			farm, err = d.farmRepo.GetByID(ctx, farmID)
+			return err
+		})
+		if err != nil || farm == nil {
+			return nil, err
+		}
+
+		return farm, nil
+	})
+	if err != nil || !found {
+		return nil, err
 	}
 
-	return farm, err
+	return farm, nil
 }
 
-func (d *domain) GetFarm(param entity.FarmParam) (farms []entity.Farm, err error) {
-	// get from db
-	err = d.gorm.
-		Where("is_deleted is null").
-		Where(&param).
-		Offset((param.Page - 1) * param.Limit).
-		Limit(param.Limit).
-		Find(&farms).
-		Error
+func (d *domain) GetFarm(ctx context.Context, param entity.FarmParam) (farms []entity.Farm, err error) {
+	// get from read replica, may lag behind primary
+	err = d.retry(ctx, func() (err error) {
+		farms, err = d.farmReplicaRepo.Find(ctx, param)
+		return err
+	})
 	if err != nil {
 		return farms, err
 	}
@@ -66,44 +77,60 @@ func (d *domain) GetFarm(param entity.FarmParam) (farms []entity.Farm, err error
 	return farms, nil
 }
 
-func (d *domain) UpdateFarm(farmID string, v entity.UpdateFarmRequest) (farm entity.Farm, err error) {
-	farmRes, err := d.GetFarmByID(farmID)
+func (d *domain) CountFarm(ctx context.Context) (count int64, err error) {
+	err = d.retry(ctx, func() (err error) {
+		count, err = d.farmReplicaRepo.Count(ctx)
+		return err
+	})
+
+	return count, err
+}
+
+func (d *domain) UpdateFarm(ctx context.Context, farmID string, v entity.UpdateFarmRequest) (farm entity.Farm, err error) {
+	farmRes, err := d.GetFarmByID(ctx, farmID)
 	if err != nil {
 		return farm, err
 	} else if farmRes == nil {
 		// create if not exist
-		farm, err = d.CreateFarm(entity.CreateFarmRequest{
+		farm, err = d.CreateFarm(ctx, entity.CreateFarmRequest{
 			ID:          farmID,
 			Name:        v.Name,
 			Description: v.Description,
+			CreatedAt:   v.CreatedAt,
+			UpdatedAt:   v.UpdatedAt,
 		})
 		if err != nil {
 			return farm, err
 		}
 	} else {
-		// update if exist
+		// update if exist, soft deleted farm is restored as upsert of missing farm create it
 		farm = *farmRes
 		farm.Name = v.Name
 		farm.Description = v.Description
-		farm.UpdatedAt = time.Now().UTC()
+		farm.IsDeleted, farm.DeletedAt = sql.NullBool{}, sql.NullTime{}
+		if !v.CreatedAt.IsZero() {
+			farm.CreatedAt = v.CreatedAt.UTC()
+		}
+		farm.UpdatedAt = nowOr(v.UpdatedAt)
 
 		err = farm.Validate()
 		if err != nil {
 			return farm, err
 		}
 
-		err = d.gorm.Save(&farm).Error
+		err = d.retry(ctx, func() error { return d.farmRepo.Save(ctx, farm) })
 		if err != nil {
 			return farm, err
 		}
+		d.invalidateCache(ctx, cacheEntityFarm, farm.ID)
 	}
 
 	return farm, nil
 }
 
-func (d *domain) DeleteFarmByID(farmID string) (err error) {
+func (d *domain) DeleteFarmByID(ctx context.Context, farmID string) (err error) {
 	var farm entity.Farm
-	farmRes, err := d.GetFarmByID(farmID)
+	farmRes, err := d.GetFarmByID(ctx, farmID)
 	if err != nil {
 		return err
 	} else if farmRes == nil {
@@ -114,11 +141,35 @@ func (d *domain) DeleteFarmByID(farmID string) (err error) {
 			// soft delete
 			farm.IsDeleted = sql.NullBool{Bool: true, Valid: true}
 			farm.DeletedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
-			if err := d.gorm.Save(&farm).Error; err != nil {
+			if err := d.retry(ctx, func() error { return d.farmRepo.Save(ctx, farm) }); err != nil {
 				return err
 			}
+			d.invalidateCache(ctx, cacheEntityFarm, farm.ID)
+			d.deleteResourceStatistic(ctx, entity.ResourceFarm, farm.ID)
 		}
 	}
 
 	return nil
 }
+
+func (d *domain) ValidateFarm(farm entity.Farm) error {
+	if err := farm.Validate(); err != nil {
+		return err
+	}
+
+	// id is stored as validated, surrounding whitespace is not part of it
+	if strings.TrimSpace(farm.ID) != farm.ID || !d.isIDFormatValid(farm.ID) {
+		return entity.ErrorFarmIDInvalidFormat
+	}
+
+	return nil
+}
+
+// nowOr return t in UTC, or current time when t is zero
+func nowOr(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Now().UTC()
+	}
+
+	return t.UTC()
+}
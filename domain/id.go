@@ -0,0 +1,27 @@
+package domain
+
+import (
+	"crypto/rand"
+	"sync"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+var (
+	idEntropyMu sync.Mutex
+	idEntropy   = ulid.Monotonic(rand.Reader, 0)
+)
+
+// newID generates a lexicographically sortable unique ID (ULID)
+func newID() string {
+	idEntropyMu.Lock()
+	defer idEntropyMu.Unlock()
+
+	return ulid.MustNew(ulid.Timestamp(time.Now()), idEntropy).String()
+}
+
+// isIDFormatValid check id against configured id pattern
+func (d *domain) isIDFormatValid(id string) bool {
+	return d.idPattern.MatchString(id)
+}
@@ -1,18 +1,17 @@
 package domain
 
 import (
+	"context"
 	"database/sql"
-	"errors"
+	"strings"
 	"time"
 
 	"github.com/alvinatthariq/farmsvc-go/entity"
-	"github.com/go-sql-driver/mysql"
-	"gorm.io/gorm"
 )
 
-func (d *domain) CreatePond(v entity.CreatePondRequest) (pond entity.Pond, err error) {
+func (d *domain) CreatePond(ctx context.Context, v entity.CreatePondRequest) (pond entity.Pond, err error) {
 	// get farm by id
-	farm, err := d.GetFarmByID(v.FarmID)
+	farm, err := d.GetFarmByID(ctx, v.FarmID)
 	if err != nil {
 		return pond, err
 	}
@@ -22,14 +21,23 @@ func (d *domain) CreatePond(v entity.CreatePondRequest) (pond entity.Pond, err e
 		return pond, entity.ErrorFarmNotFound
 	}
 
+	// id is stored as validated, surrounding whitespace is not part of it
+	v.ID = strings.TrimSpace(v.ID)
+	if v.ID == "" {
+		// generate id if not supplied by client
+		v.ID = newID()
+	} else if !d.isIDFormatValid(v.ID) {
+		return pond, entity.ErrorPondIDInvalidFormat
+	}
+
 	// create Pond
 	pond = entity.Pond{
 		ID:          v.ID,
 		FarmID:      v.FarmID,
 		Name:        v.Name,
 		Description: v.Description,
-		CreatedAt:   time.Now().UTC(),
-		UpdatedAt:   time.Now().UTC(),
+		CreatedAt:   nowOr(v.CreatedAt),
+		UpdatedAt:   nowOr(v.UpdatedAt),
 	}
 
 	err = pond.Validate()
@@ -38,39 +46,42 @@ func (d *domain) CreatePond(v entity.CreatePondRequest) (pond entity.Pond, err e
 	}
 
 	// create to db
-	err = d.gorm.Create(&pond).Error
+	err = d.pondRepo.Create(ctx, pond)
 	if err != nil {
-		var mysqlError *mysql.MySQLError
-		if errors.As(err, &mysqlError) {
-			// check duplicate constraint
-			if mysqlError.Number == entity.CodeMySQLDuplicateEntry {
-				return pond, entity.ErrorPondAlreadyExist
-			}
-		}
+		return pond, err
 	}
+	d.invalidateCache(ctx, cacheEntityPond, pond.ID)
 
-	return pond, err
+	return pond, nil
 }
 
-func (d *domain) GetPondByID(pondID string) (pond *entity.Pond, err error) {
-	// get from db
-	err = d.gorm.First(&pond, "id = ?", pondID).Error
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		return nil, nil
+func (d *domain) GetPondByID(ctx context.Context, pondID string) (pond *entity.Pond, err error) {
+	found, err := d.readThrough(ctx, cacheEntityPond, pondID, d.settings().PondCacheTTL, &pond, func(ctx context.Context) (interface{}, error) {
+		// get from db
+		var pond *entity.Pond
+		err := d.retry(ctx, func() (err error) {
+			pond, err = d.pondRepo.GetByID(ctx, pondID)
+			return err
+		})
+		if err != nil || pond == nil {
+			return nil, err
+		}
+
+		return pond, nil
+	})
+	if err != nil || !found {
+		return nil, err
 	}
 
-	return pond, err
+	return pond, nil
 }
 
-func (d *domain) GetPond(param entity.PondParam) (ponds []entity.Pond, err error) {
-	// get from db
-	err = d.gorm.
-		Where("is_deleted is null").
-		Where(&param).
-		Offset((param.Page - 1) * param.Limit).
-		Limit(param.Limit).
-		Find(&ponds).
-		Error
+func (d *domain) GetPond(ctx context.Context, param entity.PondParam) (ponds []entity.Pond, err error) {
+	// get from read replica, may lag behind primary
+	err = d.retry(ctx, func() (err error) {
+		ponds, err = d.pondReplicaRepo.Find(ctx, param)
+		return err
+	})
 	if err != nil {
 		return ponds, err
 	}
@@ -78,9 +89,18 @@ func (d *domain) GetPond(param entity.PondParam) (ponds []entity.Pond, err error
 	return ponds, nil
 }
 
-func (d *domain) UpdatePond(pondID string, v entity.UpdatePondRequest) (pond entity.Pond, err error) {
+func (d *domain) CountPond(ctx context.Context) (count int64, err error) {
+	err = d.retry(ctx, func() (err error) {
+		count, err = d.pondReplicaRepo.Count(ctx)
+		return err
+	})
+
+	return count, err
+}
+
+func (d *domain) UpdatePond(ctx context.Context, pondID string, v entity.UpdatePondRequest) (pond entity.Pond, err error) {
 	// get farm by id
-	farm, err := d.GetFarmByID(v.FarmID)
+	farm, err := d.GetFarmByID(ctx, v.FarmID)
 	if err != nil {
 		return pond, err
 	}
@@ -90,45 +110,52 @@ func (d *domain) UpdatePond(pondID string, v entity.UpdatePondRequest) (pond ent
 		return pond, entity.ErrorFarmNotFound
 	}
 
-	pondRes, err := d.GetPondByID(pondID)
+	pondRes, err := d.GetPondByID(ctx, pondID)
 	if err != nil {
 		return pond, err
 	} else if pondRes == nil {
 		// create if not exist
-		pond, err = d.CreatePond(entity.CreatePondRequest{
+		pond, err = d.CreatePond(ctx, entity.CreatePondRequest{
 			ID:          pondID,
 			FarmID:      v.FarmID,
 			Name:        v.Name,
 			Description: v.Description,
+			CreatedAt:   v.CreatedAt,
+			UpdatedAt:   v.UpdatedAt,
 		})
 		if err != nil {
 			return pond, err
 		}
 	} else {
-		// update if exist
+		// update if exist, soft deleted pond is restored as upsert of missing pond create it
 		pond = *pondRes
 		pond.FarmID = v.FarmID
 		pond.Name = v.Name
 		pond.Description = v.Description
-		pond.UpdatedAt = time.Now().UTC()
+		pond.IsDeleted, pond.DeletedAt = sql.NullBool{}, sql.NullTime{}
+		if !v.CreatedAt.IsZero() {
+			pond.CreatedAt = v.CreatedAt.UTC()
+		}
+		pond.UpdatedAt = nowOr(v.UpdatedAt)
 
 		err = pond.Validate()
 		if err != nil {
 			return pond, err
 		}
 
-		err := d.gorm.Save(&pond).Error
+		err := d.retry(ctx, func() error { return d.pondRepo.Save(ctx, pond) })
 		if err != nil {
 			return pond, err
 		}
+		d.invalidateCache(ctx, cacheEntityPond, pond.ID)
 	}
 
 	return pond, nil
 }
 
-func (d *domain) DeletePondByID(pondID string) (err error) {
+func (d *domain) DeletePondByID(ctx context.Context, pondID string) (err error) {
 	var pond entity.Pond
-	pondRes, err := d.GetPondByID(pondID)
+	pondRes, err := d.GetPondByID(ctx, pondID)
 	if err != nil {
 		return err
 	} else if pondRes == nil {
@@ -139,12 +166,27 @@ func (d *domain) DeletePondByID(pondID string) (err error) {
 			// soft delete
 			pond.IsDeleted = sql.NullBool{Bool: true, Valid: true}
 			pond.DeletedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
-			err = d.gorm.Save(&pond).Error
+			err = d.retry(ctx, func() error { return d.pondRepo.Save(ctx, pond) })
 			if err != nil {
 				return err
 			}
+			d.invalidateCache(ctx, cacheEntityPond, pond.ID)
+			d.deleteResourceStatistic(ctx, entity.ResourcePond, pond.ID)
 		}
 	}
 
 	return nil
 }
+
+func (d *domain) ValidatePond(pond entity.Pond) error {
+	if err := pond.Validate(); err != nil {
+		return err
+	}
+
+	// id is stored as validated, surrounding whitespace is not part of it
+	if strings.TrimSpace(pond.ID) != pond.ID || !d.isIDFormatValid(pond.ID) {
+		return entity.ErrorPondIDInvalidFormat
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package domain
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/alvinatthariq/farmsvc-go/entity"
+)
+
+// GetFarmStatistic return read & write count of farm, entity.ErrorFarmNotFound if farm not exist
+func (d *domain) GetFarmStatistic(ctx context.Context, farmID string) (resourceStatistic entity.ResourceStatistic, err error) {
+	farm, err := d.GetFarmByID(ctx, farmID)
+	if err != nil {
+		return resourceStatistic, err
+	} else if farm == nil {
+		return resourceStatistic, entity.ErrorFarmNotFound
+	}
+
+	return d.statisticRepo.GetResourceStatistic(ctx, entity.ResourceFarm, farmID)
+}
+
+// GetPondStatistic return read & write count of pond, entity.ErrorPondNotFound if pond not exist
+func (d *domain) GetPondStatistic(ctx context.Context, pondID string) (resourceStatistic entity.ResourceStatistic, err error) {
+	pond, err := d.GetPondByID(ctx, pondID)
+	if err != nil {
+		return resourceStatistic, err
+	} else if pond == nil {
+		return resourceStatistic, entity.ErrorPondNotFound
+	}
+
+	return d.statisticRepo.GetResourceStatistic(ctx, entity.ResourcePond, pondID)
+}
+
+// deleteResourceStatistic remove access count of deleted farm or pond, so it is not listed anymore.
+// Statistic is best effort, failure is only logged
+func (d *domain) deleteResourceStatistic(ctx context.Context, resource string, id string) {
+	if err := d.statisticRepo.DeleteResourceStatistics(ctx, resource, []string{id}); err != nil {
+		d.logger.WarnContext(ctx, "Failed to delete resource statistic", slog.String("resource", resource), slog.String("id", id), slog.Any("error", err))
+	}
+}
+
+// dormantResourcePageSize is farms or ponds read per query while looking for dormant one
+const dormantResourcePageSize = 500
+
+// GetResourceStatistics return most accessed farms or ponds, or dormant one when param.DormantSince is set.
+// Dormant is a farm or pond created before DormantSince and not accessed since, including never accessed one.
+// Top below 1 default to entity.DefaultResourceStatisticTop and is capped at entity.MaxResourceStatisticTop
+func (d *domain) GetResourceStatistics(ctx context.Context, param entity.ResourceStatisticParam) (resourceStatistics []entity.ResourceStatistic, err error) {
+	switch param.Resource {
+	case entity.ResourceFarm, entity.ResourcePond:
+	default:
+		return resourceStatistics, entity.ErrorResourceStatisticResourceInvalid
+	}
+
+	if param.Top < 1 {
+		param.Top = entity.DefaultResourceStatisticTop
+	} else if param.Top > entity.MaxResourceStatisticTop {
+		param.Top = entity.MaxResourceStatisticTop
+	}
+
+	if !param.DormantSince.IsZero() {
+		return d.getDormantResourceStatistics(ctx, param.Resource, param.DormantSince, param.Top)
+	}
+
+	resourceStatistics, err = d.statisticRepo.GetTopResourceStatistics(ctx, param.Resource, param.Top)
+	if err != nil {
+		return resourceStatistics, err
+	}
+
+	// statistic of resource deleted while its access was still buffered may be left behind
+	ids := make([]string, len(resourceStatistics))
+	for i, resourceStatistic := range resourceStatistics {
+		ids[i] = resourceStatistic.ID
+	}
+	live, err := d.liveResources(ctx, param.Resource, ids, "", len(ids))
+	if err != nil {
+		return resourceStatistics, err
+	}
+
+	return slices.DeleteFunc(resourceStatistics, func(resourceStatistic entity.ResourceStatistic) bool {
+		_, ok := live[resourceStatistic.ID]
+		return !ok
+	}), nil
+}
+
+// getDormantResourceStatistics scan every not deleted farm or pond by id and return at most top created before since
+// and not accessed since, never accessed first then oldest access first
+func (d *domain) getDormantResourceStatistics(ctx context.Context, resource string, since time.Time, top int) (resourceStatistics []entity.ResourceStatistic, err error) {
+	resourceStatistics = []entity.ResourceStatistic{}
+	afterID := ""
+	for {
+		live, err := d.liveResources(ctx, resource, nil, afterID, dormantResourcePageSize)
+		if err != nil {
+			return resourceStatistics, err
+		}
+
+		ids := []string{}
+		for id, createdAt := range live {
+			if createdAt.Before(since) {
+				ids = append(ids, id)
+			}
+			if id > afterID {
+				afterID = id
+			}
+		}
+
+		pageStatistics, err := d.statisticRepo.GetResourceStatistics(ctx, resource, ids)
+		if err != nil {
+			return resourceStatistics, err
+		}
+		for _, resourceStatistic := range pageStatistics {
+			if resourceStatistic.LastAccessAt == nil || resourceStatistic.LastAccessAt.Before(since) {
+				resourceStatistics = append(resourceStatistics, resourceStatistic)
+			}
+		}
+
+		sort.Slice(resourceStatistics, func(i, j int) bool {
+			lastI, lastJ := resourceStatistics[i].LastAccessAt, resourceStatistics[j].LastAccessAt
+			switch {
+			case lastI == nil && lastJ == nil:
+				return resourceStatistics[i].ID < resourceStatistics[j].ID
+			case lastI == nil || lastJ == nil:
+				return lastI == nil
+			case !lastI.Equal(*lastJ):
+				return lastI.Before(*lastJ)
+			default:
+				return resourceStatistics[i].ID < resourceStatistics[j].ID
+			}
+		})
+		if len(resourceStatistics) > top {
+			resourceStatistics = resourceStatistics[:top]
+		}
+
+		if len(live) < dormantResourcePageSize {
+			return resourceStatistics, nil
+		}
+	}
+}
+
+// liveResources return created time of not deleted farms or ponds by id, limited to ids when not empty
+// and to limit resources with id after afterID
+func (d *domain) liveResources(ctx context.Context, resource string, ids []string, afterID string, limit int) (createdAt map[string]time.Time, err error) {
+	createdAt = map[string]time.Time{}
+	if limit < 1 {
+		return createdAt, nil
+	}
+
+	err = d.retry(ctx, func() error {
+		switch resource {
+		case entity.ResourceFarm:
+			farms, err := d.farmReplicaRepo.Find(ctx, entity.FarmParam{IDs: ids, AfterID: afterID, Limit: limit, Page: 1})
+			for _, farm := range farms {
+				createdAt[farm.ID] = farm.CreatedAt
+			}
+			return err
+		default:
+			ponds, err := d.pondReplicaRepo.Find(ctx, entity.PondParam{IDs: ids, AfterID: afterID, Limit: limit, Page: 1})
+			for _, pond := range ponds {
+				createdAt[pond.ID] = pond.CreatedAt
+			}
+			return err
+		}
+	})
+
+	return createdAt, err
+}
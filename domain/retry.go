@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/alvinatthariq/farmsvc-go/repository"
+)
+
+const (
+	DefaultDatabaseRetryAttempts = 3
+	DefaultDatabaseRetryBackoff  = 50 * time.Millisecond
+)
+
+// retry call fn until it succeed, return non transient error, attempts run out or ctx is done.
+// Wait between attempts start at retryBackoff and double every attempt.
+// Only operation which is safe to run twice is retried, such as read or save of full row
+func (d *domain) retry(ctx context.Context, fn func() error) error {
+	backoff := d.retryBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= d.retryAttempts || !repository.IsTransientError(err) {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
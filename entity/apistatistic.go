@@ -1,33 +1,337 @@
 package entity
 
-var APIPaths = []string{
-	APIPathPOSTFarm,
-	APIPathGETFarm,
-	APIPathGETFarmByID,
-	APIPathPUTFarmByID,
-	APIPathDELETEFarmByID,
-	APIPathPOSTPond,
-	APIPathGETPond,
-	APIPathGETPondByID,
-	APIPathPUTPondByID,
-	APIPathDELETEPondByID,
-}
+import (
+	"math"
+	"strconv"
+	"time"
+)
 
 const (
-	APIPathPOSTFarm       = "POST /v1/farm"
-	APIPathGETFarm        = "GET /v1/farm"
-	APIPathGETFarmByID    = "GET /v1/farm/{id}"
-	APIPathPUTFarmByID    = "PUT /v1/farm/{id}"
-	APIPathDELETEFarmByID = "DELETE /v1/farm/{id}"
-	APIPathPOSTPond       = "POST /v1/pond"
-	APIPathGETPond        = "GET /v1/pond"
-	APIPathGETPondByID    = "GET /v1/pond/{id}"
-	APIPathPUTPondByID    = "PUT /v1/pond/{id}"
-	APIPathDELETEPondByID = "DELETE /v1/pond/{id}"
+	// APIPathNotFound & APIPathMethodNotAllowed are api statistic path of request not matching any route
+	APIPathNotFound         = "NOT_FOUND"
+	APIPathMethodNotAllowed = "METHOD_NOT_ALLOWED"
+
+	// ClientIDAnonymous is client id of request without client id or api key header
+	ClientIDAnonymous = "anonymous"
+
+	DefaultClientStatisticTop = 20
+	MaxClientStatisticTop     = 1000
+
+	DefaultResourceStatisticTop = 20
+	MaxResourceStatisticTop     = 1000
+
+	// DefaultAPIStatisticHistoryLimit is snapshots per page of history, MaxAPIStatisticHistoryLimit bound
+	// a page including csv export
+	DefaultAPIStatisticHistoryLimit = 100
+	MaxAPIStatisticHistoryLimit     = 10000
 )
 
 type APIStatistic struct {
-	Path            string `json:"path"`
-	Count           int64  `json:"count"`
-	UniqueUserAgent int64  `json:"unique_user_agent"`
+	Path            string              `json:"path"`
+	Count           int64               `json:"count"`
+	UniqueUserAgent int64               `json:"unique_user_agent"`
+	StatusCount     map[string]int64    `json:"status_count"`
+	ErrorCount      int64               `json:"error_count"`
+	Latency         APILatency          `json:"latency"`
+	Series          []APIStatisticPoint `json:"series,omitempty"`
+}
+
+// APIStatisticEvent is a handled request, Path is method & route template e.g. "GET /v1/farm/{id}"
+type APIStatisticEvent struct {
+	Path       string
+	UserAgent  string
+	ClientID   string
+	ClientIP   string
+	StatusCode int
+	Latency    time.Duration
+	At         time.Time
+
+	// Resource & ResourceID identify single farm or pond accessed by successful request,
+	// empty when request is not for single resource. ResourceAccess is ResourceAccessRead or ResourceAccessWrite
+	Resource       string
+	ResourceID     string
+	ResourceAccess string
+}
+
+// StatusClass return status class of event e.g. 2xx, 4xx
+func (e APIStatisticEvent) StatusClass() string {
+	return strconv.Itoa(e.StatusCode/100) + "xx"
+}
+
+// IsError is true for server error (5xx) event
+func (e APIStatisticEvent) IsError() bool {
+	return e.StatusCode >= 500
+}
+
+// LatencyBucket return upper bound label of histogram bucket containing event latency
+func (e APIStatisticEvent) LatencyBucket() string {
+	ms := float64(e.Latency) / float64(time.Millisecond)
+	for _, le := range LatencyBucketsMs {
+		if ms <= le {
+			return strconv.FormatFloat(le, 'f', -1, 64)
+		}
+	}
+
+	return LatencyBucketInf
+}
+
+type APILatency struct {
+	P50       float64            `json:"p50_ms"`
+	P95       float64            `json:"p95_ms"`
+	P99       float64            `json:"p99_ms"`
+	Histogram []APILatencyBucket `json:"histogram"`
+}
+
+// APILatencyBucket is count of request with latency less than or equal LE millisecond
+// and greater than LE of previous bucket
+type APILatencyBucket struct {
+	LE    string `json:"le"`
+	Count int64  `json:"count"`
+}
+
+const LatencyBucketInf = "+Inf"
+
+var LatencyBucketsMs = []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// NewAPILatency build latency histogram from count of each bucket label and estimate percentiles
+func NewAPILatency(bucketCount map[string]int64) (latency APILatency) {
+	var total int64
+	for _, le := range LatencyBucketsMs {
+		label := strconv.FormatFloat(le, 'f', -1, 64)
+		latency.Histogram = append(latency.Histogram, APILatencyBucket{LE: label, Count: bucketCount[label]})
+		total += bucketCount[label]
+	}
+	latency.Histogram = append(latency.Histogram, APILatencyBucket{LE: LatencyBucketInf, Count: bucketCount[LatencyBucketInf]})
+	total += bucketCount[LatencyBucketInf]
+
+	if total > 0 {
+		latency.P50 = latency.percentile(0.50, total)
+		latency.P95 = latency.percentile(0.95, total)
+		latency.P99 = latency.percentile(0.99, total)
+	}
+
+	return latency
+}
+
+// percentile estimate p-th percentile by linear interpolation within histogram bucket,
+// percentile in +Inf bucket is reported as its lower bound
+func (l APILatency) percentile(p float64, total int64) float64 {
+	rank := p * float64(total)
+
+	var cumulative float64
+	lower := 0.0
+	for i, bucket := range l.Histogram {
+		if i >= len(LatencyBucketsMs) {
+			return lower
+		}
+
+		upper := LatencyBucketsMs[i]
+		if bucket.Count > 0 && cumulative+float64(bucket.Count) >= rank {
+			fraction := (rank - cumulative) / float64(bucket.Count)
+			return math.Round((lower+(upper-lower)*fraction)*100) / 100
+		}
+
+		cumulative += float64(bucket.Count)
+		lower = upper
+	}
+
+	return lower
+}
+
+// APIStatisticBufferStatistic is state of in memory buffer of api statistic events waiting to be written,
+// Dropped is events discarded because buffer is full, Failed is events lost when write of their batch failed twice
+type APIStatisticBufferStatistic struct {
+	Buffered int64 `json:"buffered"`
+	Capacity int64 `json:"capacity"`
+	Dropped  int64 `json:"dropped"`
+	Failed   int64 `json:"failed"`
+	Flushed  int64 `json:"flushed"`
+}
+
+type APIStatisticPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Count     int64     `json:"count"`
+}
+
+type APIStatisticParam struct {
+	From        time.Time
+	To          time.Time
+	Granularity string
+}
+
+// Granularity is size of time bucket in api statistic series,
+// bucket is kept in storage until its Retention is passed
+type Granularity struct {
+	Name      string
+	Size      time.Duration
+	Retention time.Duration
+}
+
+const MaxAPIStatisticSeriesPoints = 1440
+
+var (
+	GranularityMinute = Granularity{Name: "minute", Size: time.Minute, Retention: 48 * time.Hour}
+	GranularityHour   = Granularity{Name: "hour", Size: time.Hour, Retention: 90 * 24 * time.Hour}
+	GranularityDay    = Granularity{Name: "day", Size: 24 * time.Hour, Retention: 2 * 365 * 24 * time.Hour}
+)
+
+var Granularities = []Granularity{
+	GranularityMinute,
+	GranularityHour,
+	GranularityDay,
+}
+
+func GetGranularity(name string) (granularity Granularity, ok bool) {
+	for _, granularity := range Granularities {
+		if granularity.Name == name {
+			return granularity, true
+		}
+	}
+
+	return granularity, false
+}
+
+type CacheStatistic struct {
+	Entity string `json:"entity"`
+	Hit    int64  `json:"hit"`
+	Miss   int64  `json:"miss"`
+}
+
+// ClientStatistic is top clients & user agents by request count and estimated unique client ip
+type ClientStatistic struct {
+	TopClients    []ClientCount `json:"top_clients"`
+	TopUserAgents []ClientCount `json:"top_user_agents"`
+	UniqueIP      int64         `json:"unique_ip"`
+}
+
+type ClientCount struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+const (
+	// APIStatisticSnapshotReasonPeriodic, APIStatisticSnapshotReasonReset & APIStatisticSnapshotReasonBackup
+	// is why snapshot is taken, backup one is api statistic current when backup archive was written
+	APIStatisticSnapshotReasonPeriodic = "periodic"
+	APIStatisticSnapshotReasonReset    = "reset"
+	APIStatisticSnapshotReasonBackup   = "backup"
+)
+
+// APIStatisticSnapshot is lifetime counter of one path at SnapshotAt, kept in sql so it survive redis flush.
+// Every path snapshotted at once share the same SnapshotAt
+type APIStatisticSnapshot struct {
+	ID              int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	Path            string    `json:"path" gorm:"type:varchar(255)"`
+	Count           int64     `json:"count"`
+	UniqueUserAgent int64     `json:"unique_user_agent"`
+	Status1xx       int64     `json:"status_1xx" gorm:"column:status_1xx"`
+	Status2xx       int64     `json:"status_2xx" gorm:"column:status_2xx"`
+	Status3xx       int64     `json:"status_3xx" gorm:"column:status_3xx"`
+	Status4xx       int64     `json:"status_4xx" gorm:"column:status_4xx"`
+	Status5xx       int64     `json:"status_5xx" gorm:"column:status_5xx"`
+	ErrorCount      int64     `json:"error_count"`
+	LatencyP50      float64   `json:"latency_p50_ms"`
+	LatencyP95      float64   `json:"latency_p95_ms"`
+	LatencyP99      float64   `json:"latency_p99_ms"`
+	Reason          string    `json:"reason" gorm:"type:varchar(20)"`
+	SnapshotAt      time.Time `json:"snapshot_at"`
+}
+
+func NewAPIStatisticSnapshot(apiStatistic APIStatistic, reason string, snapshotAt time.Time) APIStatisticSnapshot {
+	return APIStatisticSnapshot{
+		Path:            apiStatistic.Path,
+		Count:           apiStatistic.Count,
+		UniqueUserAgent: apiStatistic.UniqueUserAgent,
+		Status1xx:       apiStatistic.StatusCount["1xx"],
+		Status2xx:       apiStatistic.StatusCount["2xx"],
+		Status3xx:       apiStatistic.StatusCount["3xx"],
+		Status4xx:       apiStatistic.StatusCount["4xx"],
+		Status5xx:       apiStatistic.StatusCount["5xx"],
+		ErrorCount:      apiStatistic.ErrorCount,
+		LatencyP50:      apiStatistic.Latency.P50,
+		LatencyP95:      apiStatistic.Latency.P95,
+		LatencyP99:      apiStatistic.Latency.P99,
+		Reason:          reason,
+		SnapshotAt:      snapshotAt,
+	}
+}
+
+// APIStatisticSnapshotCSVHeader is column name of APIStatisticSnapshot.CSVRecord
+var APIStatisticSnapshotCSVHeader = []string{
+	"snapshot_at", "reason", "path", "count", "unique_user_agent",
+	"status_1xx", "status_2xx", "status_3xx", "status_4xx", "status_5xx", "error_count",
+	"latency_p50_ms", "latency_p95_ms", "latency_p99_ms",
+}
+
+func (s APIStatisticSnapshot) CSVRecord() []string {
+	formatInt := func(n int64) string { return strconv.FormatInt(n, 10) }
+	formatFloat := func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+
+	return []string{
+		s.SnapshotAt.UTC().Format(time.RFC3339), s.Reason, s.Path, formatInt(s.Count), formatInt(s.UniqueUserAgent),
+		formatInt(s.Status1xx), formatInt(s.Status2xx), formatInt(s.Status3xx), formatInt(s.Status4xx), formatInt(s.Status5xx), formatInt(s.ErrorCount),
+		formatFloat(s.LatencyP50), formatFloat(s.LatencyP95), formatFloat(s.LatencyP99),
+	}
+}
+
+// APIStatisticSnapshotParam filter snapshot by Path when not empty and SnapshotAt from until to, inclusive
+type APIStatisticSnapshotParam struct {
+	Path  string
+	From  time.Time
+	To    time.Time
+	Limit int
+	Page  int
+}
+
+const (
+	ResourceFarm = "farm"
+	ResourcePond = "pond"
+
+	ResourceAccessRead  = "read"
+	ResourceAccessWrite = "write"
+)
+
+// ResourceStatistic is access count of single farm or pond, last access is nil if never accessed
+type ResourceStatistic struct {
+	Resource     string     `json:"resource"`
+	ID           string     `json:"id"`
+	Read         int64      `json:"read"`
+	Write        int64      `json:"write"`
+	LastReadAt   *time.Time `json:"last_read_at"`
+	LastWriteAt  *time.Time `json:"last_write_at"`
+	LastAccessAt *time.Time `json:"last_access_at"`
+}
+
+// NewResourceStatistic build ResourceStatistic from access count and last access unix time, zero unix time is never
+func NewResourceStatistic(resource string, id string, read int64, write int64, lastReadUnix int64, lastWriteUnix int64) ResourceStatistic {
+	unixTime := func(unix int64) *time.Time {
+		if unix == 0 {
+			return nil
+		}
+		t := time.Unix(unix, 0).UTC()
+		return &t
+	}
+
+	lastAccessUnix := lastReadUnix
+	if lastWriteUnix > lastAccessUnix {
+		lastAccessUnix = lastWriteUnix
+	}
+
+	return ResourceStatistic{
+		Resource:     resource,
+		ID:           id,
+		Read:         read,
+		Write:        write,
+		LastReadAt:   unixTime(lastReadUnix),
+		LastWriteAt:  unixTime(lastWriteUnix),
+		LastAccessAt: unixTime(lastAccessUnix),
+	}
+}
+
+// ResourceStatisticParam select most accessed resources, or when DormantSince is set
+// resources not accessed since DormantSince ordered by last access, oldest first
+type ResourceStatisticParam struct {
+	Resource     string
+	Top          int
+	DormantSince time.Time
 }
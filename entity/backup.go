@@ -0,0 +1,74 @@
+package entity
+
+import "time"
+
+const (
+	// BackupFormatVersion is version of archive layout written by backup, restore reject other version
+	BackupFormatVersion = 1
+
+	BackupFileManifest              = "manifest.json"
+	BackupFileFarms                 = "farms.json"
+	BackupFilePonds                 = "ponds.json"
+	BackupFileAPIStatisticSnapshots = "api_statistic_snapshots.json"
+
+	// RestoreModeEmpty load archive only into database without farm & pond,
+	// RestoreModeMerge load archive into existing data following RestoreParam.Conflict
+	RestoreModeEmpty = "empty"
+	RestoreModeMerge = "merge"
+
+	// RestoreConflictFail abort restore when farm or pond already exist, RestoreConflictSkip keep existing one,
+	// RestoreConflictOverwrite replace existing one and RestoreConflictNewer keep whichever updated later
+	RestoreConflictFail      = "fail"
+	RestoreConflictSkip      = "skip"
+	RestoreConflictOverwrite = "overwrite"
+	RestoreConflictNewer     = "newer"
+)
+
+// BackupManifest describe content of backup archive, it is the first file of archive
+type BackupManifest struct {
+	FormatVersion int       `json:"format_version"`
+	CreatedAt     time.Time `json:"created_at"`
+	// SchemaVersion is database migration version of backed up instance
+	SchemaVersion int64 `json:"schema_version"`
+	// FarmIDs is farms backed up with their ponds, empty when every farm is backed up
+	FarmIDs           []string     `json:"farm_ids,omitempty"`
+	IncludeStatistics bool         `json:"include_statistics"`
+	Files             []BackupFile `json:"files"`
+}
+
+// BackupFile is data file of backup archive, SHA256 is hex encoded checksum of its content
+type BackupFile struct {
+	Name   string `json:"name"`
+	Count  int    `json:"count"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+type BackupParam struct {
+	// FarmIDs limit backup to these farms and their ponds, empty back up every farm
+	FarmIDs []string
+	// IncludeStatistics add api statistic snapshots and current api statistic
+	IncludeStatistics bool
+}
+
+type RestoreParam struct {
+	// Mode is RestoreModeEmpty or RestoreModeMerge, default to empty
+	Mode string
+	// Conflict is rule of farm or pond already exist on merge, default to fail
+	Conflict string
+	// IncludeStatistics restore api statistic snapshots of archive when it has them
+	IncludeStatistics bool
+}
+
+type RestoreResult struct {
+	Manifest              BackupManifest `json:"manifest"`
+	Farms                 RestoreCount   `json:"farms"`
+	Ponds                 RestoreCount   `json:"ponds"`
+	APIStatisticSnapshots RestoreCount   `json:"api_statistic_snapshots"`
+}
+
+type RestoreCount struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Skipped int `json:"skipped"`
+}
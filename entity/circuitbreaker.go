@@ -0,0 +1,28 @@
+package entity
+
+import "time"
+
+const (
+	// CircuitStateClosed let every call through
+	CircuitStateClosed = "closed"
+	// CircuitStateOpen reject every call until open timeout passed
+	CircuitStateOpen = "open"
+	// CircuitStateHalfOpen let one trial call through, success close the circuit & failure open it again
+	CircuitStateHalfOpen = "half_open"
+)
+
+var CircuitStates = []string{
+	CircuitStateClosed,
+	CircuitStateOpen,
+	CircuitStateHalfOpen,
+}
+
+type CircuitBreakerStatistic struct {
+	State string `json:"state"`
+	// Failures is consecutive failed calls since last success
+	Failures int64 `json:"failures"`
+	// OpenedAt is when the circuit last opened
+	OpenedAt *time.Time `json:"opened_at,omitempty"`
+	// Rejected is total calls not sent since start because circuit is open
+	Rejected int64 `json:"rejected"`
+}
@@ -7,6 +7,18 @@ const (
 	CodeMySQLDuplicateEntry             = 1062
 	CodeMySQLForeignKeyConstraintFailed = 1452
 	CodeMySQLTableNotExist              = 1146
+	CodeMySQLLockWaitTimeout            = 1205
+	CodeMySQLDeadlock                   = 1213
+
+	// Code PostgreSQL Error from https://www.postgresql.org/docs/current/errcodes-appendix.html
+	CodePostgresUniqueViolation      = "23505"
+	CodePostgresForeignKeyViolation  = "23503"
+	CodePostgresSerializationFailure = "40001"
+	CodePostgresDeadlockDetected     = "40P01"
+	CodePostgresAdminShutdown        = "57P01"
+	CodePostgresLockNotAvailable     = "55P03"
+	// CodePostgresClassConnection is class of connection exception codes
+	CodePostgresClassConnection = "08"
 )
 
 var (
@@ -14,6 +26,7 @@ var (
 	ErrorFarmAlreadyExist         error = fmt.Errorf("Farm Already Exist")
 	ErrorFarmIDRequired           error = fmt.Errorf("Farm ID Required")
 	ErrorFarmIDMaxLength          error = fmt.Errorf("Farm ID Max Length is 36")
+	ErrorFarmIDInvalidFormat      error = fmt.Errorf("Farm ID Invalid Format")
 	ErrorFarmNameRequired         error = fmt.Errorf("Farm Name Required")
 	ErrorFarmNameMaxLength        error = fmt.Errorf("Farm Name Max Length is 100")
 	ErrorFarmDescriptionRequired  error = fmt.Errorf("Farm Description Required")
@@ -22,8 +35,36 @@ var (
 	ErrorPondAlreadyExist         error = fmt.Errorf("Pond Already Exist")
 	ErrorPondIDRequired           error = fmt.Errorf("Pond ID Required")
 	ErrorPondIDMaxLength          error = fmt.Errorf("Pond ID Max Length is 36")
+	ErrorPondIDInvalidFormat      error = fmt.Errorf("Pond ID Invalid Format")
 	ErrorPondNameRequired         error = fmt.Errorf("Pond Name Required")
 	ErrorPondNameMaxLength        error = fmt.Errorf("Pond Name Max Length is 100")
 	ErrorPondDescriptionRequired  error = fmt.Errorf("Pond Description Required")
 	ErrorPondDescriptionMaxLength error = fmt.Errorf("Pond Description Max Length is 150")
+
+	ErrorRedisCircuitOpen error = fmt.Errorf("Redis Unavailable, Circuit Breaker Open")
+
+	ErrorRouteNotFound         error = fmt.Errorf("Route Not Found")
+	ErrorRouteMethodNotAllowed error = fmt.Errorf("Method Not Allowed")
+
+	ErrorAPIStatisticGranularityInvalid   error = fmt.Errorf("API Statistic Granularity must be minute, hour or day")
+	ErrorAPIStatisticRangeInvalid         error = fmt.Errorf("API Statistic From must be before To")
+	ErrorAPIStatisticRangeTooLarge        error = fmt.Errorf("API Statistic Range Max is 1440 points")
+	ErrorAPIStatisticHistoryLimitTooLarge error = fmt.Errorf("API Statistic History Limit Max is 10000")
+	ErrorAPIStatisticBufferFull           error = fmt.Errorf("API Statistic Buffer Full")
+	ErrorAPIStatisticBufferClosed         error = fmt.Errorf("API Statistic Buffer Closed")
+
+	ErrorResourceStatisticResourceInvalid error = fmt.Errorf("Resource Statistic Resource must be farm or pond")
+
+	ErrorBackupInvalid            error = fmt.Errorf("Backup Archive Invalid")
+	ErrorBackupChecksumMismatch   error = fmt.Errorf("Backup Archive Checksum Mismatch")
+	ErrorBackupVersionUnsupported error = fmt.Errorf("Backup Archive Format Version Unsupported")
+	ErrorBackupSchemaNewer        error = fmt.Errorf("Backup Archive Schema is Newer than Database, migrate database first")
+	ErrorRestoreModeInvalid       error = fmt.Errorf("Restore Mode must be empty or merge")
+	ErrorRestoreConflictInvalid   error = fmt.Errorf("Restore Conflict must be fail, skip, overwrite or newer")
+	ErrorRestoreDatabaseNotEmpty  error = fmt.Errorf("Restore Database Not Empty, use merge mode")
+	ErrorRestoreConflict          error = fmt.Errorf("Restore Conflict, farm or pond already exist")
+	ErrorRestorePondFarmNotFound  error = fmt.Errorf("Restore Pond Farm Not Found")
+
+	ErrorAdminDisabled     error = fmt.Errorf("Admin Endpoint Disabled, admin token is not configured")
+	ErrorAdminUnauthorized error = fmt.Errorf("Admin Token Invalid")
 )
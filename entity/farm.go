@@ -17,10 +17,14 @@ type Farm struct {
 }
 
 type FarmParam struct {
-	ID    string
-	Name  string
-	Limit int `gorm:"-"`
-	Page  int `gorm:"-"`
+	ID   string
+	Name string
+	// IDs keep only farms in the list when not empty, AfterID keep farms with id after it,
+	// so every farm can be listed page by page by id without offset
+	IDs     []string `gorm:"-"`
+	AfterID string   `gorm:"-"`
+	Limit   int      `gorm:"-"`
+	Page    int      `gorm:"-"`
 }
 
 func (f Farm) Validate() error {
@@ -51,10 +55,18 @@ func (f Farm) Validate() error {
 type UpdateFarmRequest struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
+
+	// CreatedAt & UpdatedAt keep timestamp of imported farm, zero is now. They are never read from api request
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"-"`
 }
 
 type CreateFarmRequest struct {
 	ID          string `json:"id"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
+
+	// CreatedAt & UpdatedAt keep timestamp of imported farm, zero is now. They are never read from api request
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"-"`
 }
@@ -0,0 +1,29 @@
+package entity
+
+const (
+	// HealthStatusOK is reported when every dependency is up
+	HealthStatusOK = "ok"
+	// HealthStatusDegraded is reported when only non critical dependencies are down
+	HealthStatusDegraded = "degraded"
+	// HealthStatusUnavailable is reported when any critical dependency is down
+	HealthStatusUnavailable = "unavailable"
+	// HealthStatusShuttingDown is reported once shutdown started, dependencies are not checked
+	HealthStatusShuttingDown = "shutting_down"
+
+	DependencyStatusUp   = "up"
+	DependencyStatusDown = "down"
+)
+
+type Health struct {
+	Status       string             `json:"status"`
+	Dependencies []DependencyHealth `json:"dependencies,omitempty"`
+}
+
+type DependencyHealth struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Critical bool   `json:"critical"`
+	// LatencyMs is how long the check took in millisecond
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
@@ -0,0 +1,5 @@
+package entity
+
+// DefaultIDPattern only accepts URL unreserved characters, so an ID can
+// always be used as a single path segment in /v1/farm/{id} and /v1/pond/{id}
+const DefaultIDPattern = `^[A-Za-z0-9._~-]+$`
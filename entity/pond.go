@@ -21,8 +21,12 @@ type PondParam struct {
 	ID     string
 	FarmID string
 	Name   string
-	Limit  int `gorm:"-"`
-	Page   int `gorm:"-"`
+	// IDs keep only ponds in the list when not empty, AfterID keep ponds with id after it,
+	// so every pond can be listed page by page by id without offset
+	IDs     []string `gorm:"-"`
+	AfterID string   `gorm:"-"`
+	Limit   int      `gorm:"-"`
+	Page    int      `gorm:"-"`
 }
 
 func (p Pond) Validate() error {
@@ -62,10 +66,18 @@ type CreatePondRequest struct {
 	FarmID      string `json:"farm_id"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
+
+	// CreatedAt & UpdatedAt keep timestamp of imported pond, zero is now. They are never read from api request
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"-"`
 }
 
 type UpdatePondRequest struct {
 	FarmID      string `json:"farm_id"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
+
+	// CreatedAt & UpdatedAt keep timestamp of imported pond, zero is now. They are never read from api request
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"-"`
 }
@@ -25,6 +25,7 @@ type Meta struct {
 	Message    string `json:"message"`
 	Timestamp  string `json:"timestamp"`
 	Error      string `json:"error,omitempty"`
+	RequestID  string `json:"request_id,omitempty"`
 }
 
 type HTTPEmptyResp struct {
@@ -37,7 +38,16 @@ type HTTPAPIStatisticsResp struct {
 }
 
 type HTTPAPIStatisticsData struct {
-	APIStatistics []APIStatistic `json:"api_statistics"`
+	APIStatistics   []APIStatistic              `json:"api_statistics"`
+	CacheStatistics []CacheStatistic            `json:"cache_statistics"`
+	BufferStatistic APIStatisticBufferStatistic `json:"buffer_statistic"`
+	// RedisCircuitBreaker is not closed when statistic is served from in memory fallback of this replica
+	RedisCircuitBreaker CircuitBreakerStatistic `json:"redis_circuit_breaker"`
+}
+
+type HTTPClientStatisticResp struct {
+	Meta Meta            `json:"meta"`
+	Data ClientStatistic `json:"data"`
 }
 
 type HTTPPondResp struct {
@@ -57,3 +67,36 @@ type HTTPPondsResp struct {
 type HTTPPondsData struct {
 	Ponds []Pond `json:"ponds"`
 }
+
+type HTTPAPIStatisticSnapshotsResp struct {
+	Meta Meta                          `json:"meta"`
+	Data HTTPAPIStatisticSnapshotsData `json:"data"`
+}
+
+type HTTPAPIStatisticSnapshotsData struct {
+	Snapshots []APIStatisticSnapshot `json:"snapshots"`
+}
+
+type HTTPResourceStatisticResp struct {
+	Meta Meta              `json:"meta"`
+	Data ResourceStatistic `json:"data"`
+}
+
+type HTTPResourceStatisticsResp struct {
+	Meta Meta                       `json:"meta"`
+	Data HTTPResourceStatisticsData `json:"data"`
+}
+
+type HTTPResourceStatisticsData struct {
+	ResourceStatistics []ResourceStatistic `json:"resource_statistics"`
+}
+
+type HTTPHealthResp struct {
+	Meta Meta   `json:"meta"`
+	Data Health `json:"data"`
+}
+
+type HTTPRestoreResp struct {
+	Meta Meta          `json:"meta"`
+	Data RestoreResult `json:"data"`
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/alvinatthariq/farmsvc-go/transfer"
+
+	"gorm.io/gorm"
+)
+
+// RunExport handle export command, not deleted farms & ponds are written to file or stdout
+func RunExport(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", "", "json or csv, default by output extension then json")
+	resource := flags.String("resource", "", "farm or pond, required for csv, json export both when empty")
+	output := flags.String("o", "-", "output file, - is stdout")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: farmsvc-go export [-format json|csv] [-resource farm|pond] [-o file]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *format == "" {
+		*format = transfer.FormatOf(*output)
+	}
+	if *format == transfer.FormatCSV && *resource == "" {
+		fmt.Fprintln(os.Stderr, "-resource is required for csv")
+		os.Exit(2)
+	}
+
+	InitApp(os.Stderr)
+	InitCommandDomain(false)
+	defer CloseCommandDomain()
+
+	dataset, err := transfer.Export(context.Background(), exportDB(), *resource)
+	if err != nil {
+		fatal(err)
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := transfer.Write(w, *format, *resource, dataset); err != nil {
+		fatal(err)
+	}
+	appLogger.Info("Export Completed", slog.String("output", *output), slog.Int("farms", len(dataset.Farms)), slog.Int("ponds", len(dataset.Ponds)))
+}
+
+// exportDB return first read replica when configured, otherwise primary
+func exportDB() *gorm.DB {
+	if len(dbReplicas) > 0 {
+		return dbReplicas[0]
+	}
+
+	return dbgorm
+}
+
+// RunImport handle import command, farms & ponds of file are created or overwritten
+func RunImport(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	format := flags.String("format", "", "json or csv, default by file extension then json")
+	resource := flags.String("resource", "", "farm or pond, required for csv")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: farmsvc-go import [-format json|csv] [-resource farm|pond] <file|->")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	input := flags.Arg(0)
+	if *format == "" {
+		*format = transfer.FormatOf(input)
+	}
+	if *format == transfer.FormatCSV && *resource == "" {
+		fmt.Fprintln(os.Stderr, "-resource is required for csv")
+		os.Exit(2)
+	}
+
+	InitApp(os.Stderr)
+
+	var r io.Reader = os.Stdin
+	if input != "-" {
+		f, err := os.Open(input)
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	dataset, err := transfer.Read(r, *format, *resource)
+	if err != nil {
+		fatal(fmt.Errorf("Invalid Import File %s : %w", input, err))
+	}
+
+	InitCommandDomain(true)
+	defer CloseCommandDomain()
+
+	farms, ponds, err := transfer.Import(context.Background(), dom, dataset)
+	if err != nil {
+		fatal(fmt.Errorf("Import stopped after %d farms & %d ponds : %w", farms, ponds, err))
+	}
+	appLogger.Info("Import Completed", slog.String("input", input), slog.Int("farms", farms), slog.Int("ponds", ponds))
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// setCommandConfig point config of command to sqlite database file of test, redis is unreachable
+func setCommandConfig(t *testing.T, autoMigrate string) string {
+	database := filepath.Join(t.TempDir(), "farm.db")
+	t.Setenv(EnvConfigFile, writeFile(t, "config.json", testConfigFile))
+	t.Setenv("FARMSVC_DATABASE_CONNECTION_STRING", database)
+	t.Setenv("FARMSVC_DATABASE_AUTO_MIGRATE", autoMigrate)
+	t.Setenv("FARMSVC_REDIS_HOST", "127.0.0.1:1")
+
+	return database
+}
+
+func TestImportExportCommand(t *testing.T) {
+	Convey("TestImportExportCommand", t, FailureHalts, func() {
+		setCommandConfig(t, "true")
+		farms := "id,name,description,created_at,updated_at\n" +
+			"farm-1,alpha,first,2023-06-01T00:00:00Z,2023-06-01T01:00:00Z\n" +
+			"farm-2,beta,second,2023-06-02T00:00:00Z,2023-06-02T01:00:00Z\n"
+		input := writeFile(t, "farms.csv", farms)
+		output := filepath.Join(t.TempDir(), "export.csv")
+
+		t.Log("1 - [P] : Success import csv, database migrated")
+		RunImport([]string{"-resource", "farm", input})
+
+		t.Log("2 - [P] : Success export same rows & timestamps")
+		RunExport([]string{"-resource", "farm", "-o", output})
+		raw, err := os.ReadFile(output)
+		So(err, ShouldBeNil)
+		So(string(raw), ShouldEqual, farms)
+
+		t.Log("3 - [P] : Success export json to stdout, log kept out of it")
+		stdout := os.Stdout
+		r, w, err := os.Pipe()
+		So(err, ShouldBeNil)
+		os.Stdout = w
+		RunExport([]string{"-resource", "farm", "-format", "json"})
+		os.Stdout = stdout
+		w.Close()
+		raw, err = io.ReadAll(r)
+		So(err, ShouldBeNil)
+		So(strings.HasPrefix(string(raw), "{"), ShouldBeTrue)
+		So(string(raw), ShouldContainSubstring, `"created_at": "2023-06-01T00:00:00Z"`)
+	})
+}
+
+func TestReadOnlyCommandNotMigrate(t *testing.T) {
+	Convey("TestReadOnlyCommandNotMigrate", t, FailureHalts, func() {
+		setCommandConfig(t, "true")
+		InitApp(io.Discard)
+
+		t.Log("1 - [P] : Success skip migration of read only command")
+		InitCommandDomain(false)
+		So(dbgorm.Migrator().HasTable("schema_migration"), ShouldBeFalse)
+		CloseCommandDomain()
+
+		t.Log("2 - [P] : Success apply migration of writing command")
+		InitCommandDomain(true)
+		So(dbgorm.Migrator().HasTable("schema_migration"), ShouldBeTrue)
+		CloseCommandDomain()
+	})
+}
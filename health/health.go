@@ -0,0 +1,160 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alvinatthariq/farmsvc-go/entity"
+
+	"github.com/go-redis/redis"
+)
+
+// DefaultTimeout is max wait of a dependency check
+const DefaultTimeout = 2 * time.Second
+
+// ErrTimeout is reported when dependency check does not finish in time
+var ErrTimeout = errors.New("health check timed out")
+
+// CheckFunc return error when dependency is not usable
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name     string
+	critical bool
+	fn       CheckFunc
+}
+
+// Health check registered dependencies, used by readiness probe
+type Health struct {
+	timeout time.Duration
+	checks  []check
+
+	shuttingDown atomic.Bool
+}
+
+// New create health without dependency, zero or negative timeout use DefaultTimeout
+func New(timeout time.Duration) *Health {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	return &Health{
+		timeout: timeout,
+	}
+}
+
+// Register add dependency check, service is unavailable when a critical dependency is down
+// and degraded when a non critical one is down
+func (h *Health) Register(name string, critical bool, fn CheckFunc) {
+	h.checks = append(h.checks, check{
+		name:     name,
+		critical: critical,
+		fn:       fn,
+	})
+}
+
+// RegisterDB add critical check pinging sql database
+func (h *Health) RegisterDB(driver string, db *sql.DB) {
+	h.Register(driver, true, db.PingContext)
+}
+
+// RegisterRedis add non critical check pinging redis, only statistic & cache are kept in redis
+func (h *Health) RegisterRedis(redisClient *redis.Client) {
+	h.Register("redis", false, func(ctx context.Context) error {
+		return redisClient.WithContext(ctx).Ping().Err()
+	})
+}
+
+// ShutDown make every following Check report shutting down
+func (h *Health) ShutDown() {
+	h.shuttingDown.Store(true)
+}
+
+// RegisterCircuitBreaker add non critical check reporting down while circuit of state is not closed
+func (h *Health) RegisterCircuitBreaker(name string, state func() string) {
+	h.Register(name, false, func(ctx context.Context) error {
+		if current := state(); current != entity.CircuitStateClosed {
+			return fmt.Errorf("circuit breaker is %s", current)
+		}
+
+		return nil
+	})
+}
+
+// Check run every dependency check concurrently, dependencies are reported in registered order
+func (h *Health) Check(ctx context.Context) entity.Health {
+	if h.shuttingDown.Load() {
+		return entity.Health{
+			Status: entity.HealthStatusShuttingDown,
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	dependencies := make([]entity.DependencyHealth, len(h.checks))
+
+	var wg sync.WaitGroup
+	for i, c := range h.checks {
+		wg.Add(1)
+		go func(i int, c check) {
+			defer wg.Done()
+			dependencies[i] = runCheck(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	health := entity.Health{
+		Status:       entity.HealthStatusOK,
+		Dependencies: dependencies,
+	}
+	for _, dependency := range dependencies {
+		if dependency.Status == entity.DependencyStatusUp {
+			continue
+		}
+
+		if dependency.Critical {
+			health.Status = entity.HealthStatusUnavailable
+			break
+		}
+		health.Status = entity.HealthStatusDegraded
+	}
+
+	return health
+}
+
+// runCheck wait until check return or ctx is done, whichever first,
+// so client which ignore ctx cannot block the probe
+func runCheck(ctx context.Context, c check) entity.DependencyHealth {
+	start := time.Now()
+
+	result := make(chan error, 1)
+	go func() {
+		result <- c.fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-result:
+	case <-ctx.Done():
+		err = ErrTimeout
+	}
+
+	dependency := entity.DependencyHealth{
+		Name:      c.name,
+		Status:    entity.DependencyStatusUp,
+		Critical:  c.critical,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		dependency.Status = entity.DependencyStatusDown
+		dependency.Error = err.Error()
+	}
+
+	return dependency
+}
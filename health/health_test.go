@@ -0,0 +1,110 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alvinatthariq/farmsvc-go/entity"
+	"github.com/alvinatthariq/farmsvc-go/health"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCheck(t *testing.T) {
+	Convey("TestCheck", t, FailureHalts, func() {
+		up := func(ctx context.Context) error { return nil }
+		down := func(ctx context.Context) error { return errors.New("connection refused") }
+		hang := func(ctx context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		}
+
+		testCases := []struct {
+			testID         int
+			testType       string
+			testDesc       string
+			prepare        func(h *health.Health)
+			expectedStatus string
+			expectedDeps   []string
+		}{
+			{
+				testID:         1,
+				testDesc:       "Success check without dependency",
+				testType:       "P",
+				prepare:        func(h *health.Health) {},
+				expectedStatus: entity.HealthStatusOK,
+			},
+			{
+				testID:   2,
+				testDesc: "Success check, every dependency up",
+				testType: "P",
+				prepare: func(h *health.Health) {
+					h.Register("mysql", true, up)
+					h.Register("redis", false, up)
+				},
+				expectedStatus: entity.HealthStatusOK,
+				expectedDeps:   []string{entity.DependencyStatusUp, entity.DependencyStatusUp},
+			},
+			{
+				testID:   3,
+				testDesc: "Degraded, non critical dependency down",
+				testType: "N",
+				prepare: func(h *health.Health) {
+					h.Register("mysql", true, up)
+					h.Register("redis", false, down)
+				},
+				expectedStatus: entity.HealthStatusDegraded,
+				expectedDeps:   []string{entity.DependencyStatusUp, entity.DependencyStatusDown},
+			},
+			{
+				testID:   4,
+				testDesc: "Unavailable, critical dependency down",
+				testType: "N",
+				prepare: func(h *health.Health) {
+					h.Register("mysql", true, down)
+					h.Register("redis", false, down)
+				},
+				expectedStatus: entity.HealthStatusUnavailable,
+				expectedDeps:   []string{entity.DependencyStatusDown, entity.DependencyStatusDown},
+			},
+			{
+				testID:   5,
+				testDesc: "Unavailable, critical dependency timed out",
+				testType: "N",
+				prepare: func(h *health.Health) {
+					h.Register("mysql", true, hang)
+				},
+				expectedStatus: entity.HealthStatusUnavailable,
+				expectedDeps:   []string{entity.DependencyStatusDown},
+			},
+			{
+				testID:   6,
+				testDesc: "Shutting down, dependencies not checked",
+				testType: "N",
+				prepare: func(h *health.Health) {
+					h.Register("mysql", true, up)
+					h.ShutDown()
+				},
+				expectedStatus: entity.HealthStatusShuttingDown,
+			},
+		}
+
+		for _, tc := range testCases {
+			t.Logf("%d - [%s] : %s", tc.testID, tc.testType, tc.testDesc)
+			h := health.New(50 * time.Millisecond)
+			tc.prepare(h)
+
+			result := h.Check(context.Background())
+			So(result.Status, ShouldEqual, tc.expectedStatus)
+			So(len(result.Dependencies), ShouldEqual, len(tc.expectedDeps))
+			for i, dependency := range result.Dependencies {
+				So(dependency.Status, ShouldEqual, tc.expectedDeps[i])
+				if dependency.Status == entity.DependencyStatusDown {
+					So(dependency.Error, ShouldNotBeEmpty)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,125 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+
+	// KeyRequestID is attribute key of request id added to every log line of a request
+	KeyRequestID = "request_id"
+	// KeyTraceID & KeySpanID is attribute key of trace added to every log line of a traced request
+	KeyTraceID = "trace_id"
+	KeySpanID  = "span_id"
+)
+
+type Options struct {
+	// Level is minimum level logged, use *slog.LevelVar to change it at runtime, default to info
+	Level slog.Leveler
+	// Format is json or text, default to json
+	Format string
+	// Output default to stdout
+	Output io.Writer
+}
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	loggerKey
+)
+
+// New create structured logger, request id & trace id in context passed to *Context method is added to every line
+func New(opt Options) *slog.Logger {
+	if opt.Level == nil {
+		opt.Level = slog.LevelInfo
+	}
+	if opt.Output == nil {
+		opt.Output = os.Stdout
+	}
+
+	handlerOpt := &slog.HandlerOptions{
+		Level: opt.Level,
+	}
+
+	var handler slog.Handler
+	switch opt.Format {
+	case FormatText:
+		handler = slog.NewTextHandler(opt.Output, handlerOpt)
+	default:
+		handler = slog.NewJSONHandler(opt.Output, handlerOpt)
+	}
+
+	return slog.New(&contextHandler{Handler: handler})
+}
+
+// ParseLevel parse level name debug, info, warn or error, case insensitive
+func ParseLevel(level string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.TrimSpace(level))); err != nil {
+		return l, fmt.Errorf("Invalid Log Level %q, must be debug, info, warn or error", level)
+	}
+
+	return l, nil
+}
+
+// contextHandler add request id from context to every record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := RequestID(ctx); requestID != "" {
+		record.AddAttrs(slog.String(KeyRequestID, requestID))
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(
+			slog.String(KeyTraceID, spanContext.TraceID().String()),
+			slog.String(KeySpanID, spanContext.SpanID().String()),
+		)
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// WithRequestID return context carrying request id
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestID return request id carried by context, empty if none
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// WithLogger return context carrying logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, logger)
+}
+
+// FromContext return logger carried by context, slog.Default if none
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey).(*slog.Logger); ok {
+		return logger
+	}
+
+	return slog.Default()
+}
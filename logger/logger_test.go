@@ -0,0 +1,42 @@
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/alvinatthariq/farmsvc-go/logger"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLogger(t *testing.T) {
+	Convey("TestLogger", t, FailureHalts, func() {
+		var buf bytes.Buffer
+		log := logger.New(logger.Options{Level: slog.LevelInfo, Output: &buf})
+
+		t.Log("1 - [P] : Success log with request id from context")
+		ctx := logger.WithRequestID(context.Background(), "req-1")
+		log.InfoContext(ctx, "hello", slog.Int("n", 1))
+
+		var line map[string]interface{}
+		So(json.Unmarshal(buf.Bytes(), &line), ShouldBeNil)
+		So(line["msg"], ShouldEqual, "hello")
+		So(line[logger.KeyRequestID], ShouldEqual, "req-1")
+		So(line["n"], ShouldEqual, 1)
+
+		t.Log("2 - [P] : Success skip line below level")
+		buf.Reset()
+		log.DebugContext(ctx, "hidden")
+		So(buf.Len(), ShouldEqual, 0)
+
+		t.Log("3 - [N] : Failed parse unknown level")
+		_, err := logger.ParseLevel("verbose")
+		So(err, ShouldNotBeNil)
+		level, err := logger.ParseLevel("WARN")
+		So(err, ShouldBeNil)
+		So(level, ShouldEqual, slog.LevelWarn)
+	})
+}
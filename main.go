@@ -1,37 +1,132 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/alvinatthariq/farmsvc-go/backup"
 	"github.com/alvinatthariq/farmsvc-go/controllers"
 	"github.com/alvinatthariq/farmsvc-go/domain"
-	"github.com/alvinatthariq/farmsvc-go/entity"
+	"github.com/alvinatthariq/farmsvc-go/health"
+	"github.com/alvinatthariq/farmsvc-go/logger"
+	"github.com/alvinatthariq/farmsvc-go/metrics"
+	"github.com/alvinatthariq/farmsvc-go/migration"
+	"github.com/alvinatthariq/farmsvc-go/repository"
+	"github.com/alvinatthariq/farmsvc-go/telemetry"
 
 	"github.com/go-redis/redis"
 	"github.com/gorilla/mux"
-	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
 	"gorm.io/gorm/schema"
+	"gorm.io/plugin/opentelemetry/tracing"
 )
 
 var (
 	dbgorm      *gorm.DB
+	dbReplicas  []*gorm.DB
 	router      *mux.Router
 	redisClient *redis.Client
+	appLogger   *slog.Logger
+	logOutput   io.Writer
 	err         error
 
 	dom domain.DomainItf
 )
 
+const usage = `usage: farmsvc-go [command] [arguments]
+
+commands:
+  serve    start http server, default when command is omitted
+  migrate  apply or revert database migrations
+  seed     load demo farms & ponds from fixture file
+  export   dump farms & ponds as json or csv
+  import   load farms & ponds from json or csv
+  stats    print api statistic
+  backup   write archive of farms, ponds & api statistic
+  restore  load archive of backup into database
+
+run "farmsvc-go <command> -h" for arguments of command`
+
+var commands = map[string]func(args []string){
+	"serve":   RunServe,
+	"migrate": RunMigrate,
+	"seed":    RunSeed,
+	"export":  RunExport,
+	"import":  RunImport,
+	"stats":   RunStats,
+	"backup":  RunBackup,
+	"restore": RunRestore,
+}
+
 func main() {
-	// Load Configurations from config.json using Viper
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	run, ok := commands[command]
+	if !ok {
+		fmt.Fprintln(os.Stderr, usage)
+		if command == "help" {
+			return
+		}
+		os.Exit(2)
+	}
+	run(args)
+}
+
+// InitApp load config and initialize logger, output of command other than serve is written to stdout
+// so log is written to stderr instead
+func InitApp(output io.Writer) {
+	// Load Configurations from config file & environment using Viper
 	LoadAppConfig()
 
+	// Initialize structured logger, log package output is written through it too
+	InitLogger(output)
+}
+
+// RunServe handle serve command, server run until SIGINT or SIGTERM
+func RunServe(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: farmsvc-go serve")
+	}
+	flags.Parse(args)
+
+	InitApp(os.Stdout)
+
+	// Initialize tracing
+	shutdownTracing, err := telemetry.Init(telemetry.Options{
+		Exporter:        AppConfig.Tracing.Exporter,
+		OTLPEndpoint:    AppConfig.Tracing.OTLPEndpoint,
+		OTLPInsecure:    AppConfig.Tracing.OTLPInsecure,
+		ServiceName:     AppConfig.Tracing.ServiceName,
+		SampleRatioFunc: runtimeTraceSampleRatio,
+	})
+	if err != nil {
+		fatal(err)
+	}
+
 	// Initialize Database SQL
-	ConnectSQL(AppConfig.MySQL.ConnectionString)
-	MigrateSQL()
+	ConnectSQL(AppConfig.Database)
+
+	if AppConfig.Database.AutoMigrate {
+		MigrateSQL()
+	}
 
 	// Initialize Redis
 	ConnectRedis()
@@ -40,33 +135,307 @@ func main() {
 	router = mux.NewRouter().StrictSlash(true)
 
 	// Initialize domain
-	dom = domain.Init(dbgorm, redisClient)
+	dom = domain.Init(dbgorm, redisClient, domainOptions())
 
-	// Initialize controller
-	controllers.Init(dbgorm, router, dom)
+	// Initialize prometheus metrics
+	appMetrics := metrics.New()
+	sqlDB, err := dbgorm.DB()
+	if err != nil {
+		fatal(err)
+	}
+	appMetrics.RegisterDB(AppConfig.Database.Driver, sqlDB)
+	replicaDBs := replicaSQLDBs()
+	for i, replicaDB := range replicaDBs {
+		appMetrics.RegisterDB(replicaName(i), replicaDB)
+	}
+	appMetrics.RegisterRedis(redisClient)
+	appMetrics.RegisterDomain(dom)
+
+	// Initialize readiness check
+	appHealth := health.New(health.DefaultTimeout)
+	appHealth.RegisterDB(AppConfig.Database.Driver, sqlDB)
+	// list & report queries fall back to primary while replica is down
+	for i, replicaDB := range replicaDBs {
+		appHealth.Register(replicaName(i), false, replicaDB.PingContext)
+	}
+	appHealth.RegisterRedis(redisClient)
+	appHealth.RegisterCircuitBreaker("redis_circuit_breaker", func() string {
+		return dom.GetRedisCircuitBreakerStatistic().State
+	})
+
+	// Initialize controller, trusted proxies is checked by config validation
+	trustedProxies, err := controllers.ParseTrustedProxies(AppConfig.Server.TrustedProxies)
+	if err != nil {
+		fatal(err)
+	}
+	controllers.Init(dbgorm, router, dom, appMetrics, controllers.Options{
+		AdminToken: AppConfig.Admin.Token,
+		Logger:     appLogger,
+		Health:     appHealth,
+		Backup:     backup.New(dbgorm, dom),
+
+		TrustedProxies: trustedProxies,
+
+		RequestTimeout: AppConfig.Server.RequestTimeout,
+		RouteTimeouts:  AppConfig.Server.RouteTimeouts,
+	})
+
+	// Apply log level, trace & statistic sampling and cache ttl change without restart
+	WatchConfig()
 
 	// Start the server
-	log.Println(fmt.Sprintf("Starting Server on port %s", AppConfig.Port))
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%v", AppConfig.Port), router))
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%v", AppConfig.Port),
+		Handler:           router,
+		ReadTimeout:       AppConfig.Server.ReadTimeout,
+		ReadHeaderTimeout: AppConfig.Server.ReadHeaderTimeout,
+		WriteTimeout:      AppConfig.Server.WriteTimeout,
+		IdleTimeout:       AppConfig.Server.IdleTimeout,
+	}
+	go func() {
+		appLogger.Info("Starting Server", slog.String("port", AppConfig.Port))
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal(err)
+		}
+	}()
+
+	// Wait for interrupt signal then shutdown
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	appLogger.Info("Shutting Down Server...")
+
+	// fail readiness first, so load balancer stop routing new requests before listener is closed
+	appHealth.ShutDown()
+	time.Sleep(AppConfig.Server.DrainDelay)
+
+	// wait in flight requests
+	ctx, cancel := context.WithTimeout(context.Background(), AppConfig.Server.GracePeriod)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		appLogger.Error("Error Shutdown Server", slog.Any("error", err))
+	}
+
+	// flush buffered api statistic, stop snapshot worker
+	dom.Close()
+
+	// flush pending spans
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		appLogger.Error("Error Shutdown Tracing", slog.Any("error", err))
+	}
+
+	if err := redisClient.Close(); err != nil {
+		appLogger.Error("Error Close Redis", slog.Any("error", err))
+	}
+	if err := sqlDB.Close(); err != nil {
+		appLogger.Error("Error Close Database", slog.Any("error", err))
+	}
+	for i, replicaDB := range replicaDBs {
+		if err := replicaDB.Close(); err != nil {
+			appLogger.Error("Error Close Database", slog.String("database", replicaName(i)), slog.Any("error", err))
+		}
+	}
+	appLogger.Info("Server Stopped")
 }
 
-func ConnectSQL(connectionString string) {
-	dbgorm, err = gorm.Open(mysql.Open(connectionString), &gorm.Config{
+// domainOptions return domain options from AppConfig
+func domainOptions() domain.Options {
+	idPattern, err := regexp.Compile(AppConfig.ID.Pattern)
+	if err != nil {
+		fatal(fmt.Errorf("Invalid ID Pattern : %w", err))
+	}
+
+	return domain.Options{
+		IDPattern: idPattern,
+		// cache ttl & statistic sample ratio change on config reload
+		Settings: runtimeDomainSettings,
+
+		StatisticBufferSize:    AppConfig.Redis.Statistic.BufferSize,
+		StatisticBatchSize:     AppConfig.Redis.Statistic.BatchSize,
+		StatisticFlushInterval: AppConfig.Redis.Statistic.FlushInterval,
+
+		StatisticSnapshotInterval: AppConfig.Redis.Statistic.SnapshotInterval,
+		RedisKeyPrefix:            AppConfig.Redis.KeyPrefix,
+		RedisFailureThreshold:     AppConfig.Redis.CircuitBreaker.FailureThreshold,
+		RedisOpenTimeout:          AppConfig.Redis.CircuitBreaker.OpenTimeout,
+
+		DatabaseRetryAttempts: AppConfig.Database.RetryAttempts,
+		DatabaseRetryBackoff:  AppConfig.Database.RetryBackoff,
+
+		ReadReplicas:           dbReplicas,
+		ReplicaRecheckInterval: AppConfig.Database.ReplicaRecheckInterval,
+
+		Logger: appLogger,
+	}
+}
+
+// InitCommandDomain connect database & redis then create domain for command other than serve,
+// pending migrations are applied when migrate and database.auto_migrate are true. Read only command pass false
+func InitCommandDomain(migrate bool) {
+	ConnectSQL(AppConfig.Database)
+	if migrate && AppConfig.Database.AutoMigrate {
+		MigrateSQL()
+	}
+	ConnectRedis()
+
+	opt := domainOptions()
+	// periodic snapshot is left to the server
+	opt.StatisticSnapshotInterval = -1
+	dom = domain.Init(dbgorm, redisClient, opt)
+}
+
+// CloseCommandDomain flush domain then close database & redis
+func CloseCommandDomain() {
+	dom.Close()
+	redisClient.Close()
+	if sqlDB, err := dbgorm.DB(); err == nil {
+		sqlDB.Close()
+	}
+	for _, replica := range replicaSQLDBs() {
+		replica.Close()
+	}
+}
+
+func InitLogger(output io.Writer) {
+	logOutput = output
+
+	// log level is checked by config validation, it change on config reload
+	currentRuntime.Store(AppConfig.runtime())
+	appLogger = logger.New(logger.Options{
+		Output: output,
+		Level:  runtimeLogLevel{},
+		Format: AppConfig.Log.Format,
+	})
+	slog.SetDefault(appLogger)
+}
+
+// fatal log err then exit
+func fatal(err error) {
+	appLogger.Error("Fatal Error", slog.Any("error", err))
+	os.Exit(1)
+}
+
+func ConnectSQL(cfg DatabaseConfig) {
+	dialector, err := repository.NewDialector(cfg.Driver, cfg.ConnectionString)
+	if err != nil {
+		fatal(err)
+	}
+
+	// database may still be starting, e.g. container started together with the service
+	err = retryConnect("Database", cfg.ConnectRetries, cfg.ConnectRetryBackoff, cfg.ConnectRetryMaxBackoff, func() (err error) {
+		dbgorm, err = openSQL(cfg, dialector, false)
+		return err
+	})
+	if err != nil {
+		fatal(fmt.Errorf("Cannot connect to DB : %w", err))
+	}
+	appLogger.Info("Connected to Database...", slog.String("driver", dbgorm.Dialector.Name()))
+
+	// replica is not pinged, it may come up later and primary serve its queries meanwhile
+	for i, connectionString := range cfg.Replicas {
+		dialector, err := repository.NewDialector(cfg.Driver, connectionString)
+		if err != nil {
+			fatal(err)
+		}
+
+		replica, err := openSQL(cfg, dialector, true)
+		if err != nil {
+			fatal(fmt.Errorf("Cannot open %s : %w", replicaName(i), err))
+		}
+		dbReplicas = append(dbReplicas, replica)
+	}
+	if len(dbReplicas) > 0 {
+		appLogger.Info("Read Replicas Configured...", slog.Int("replicas", len(dbReplicas)))
+	}
+}
+
+// openSQL open gorm of dialector with pool setting of cfg and tracing,
+// disablePing skip checking the database is reachable
+func openSQL(cfg DatabaseConfig, dialector gorm.Dialector, disablePing bool) (*gorm.DB, error) {
+	db, err := gorm.Open(dialector, &gorm.Config{
 		NamingStrategy: schema.NamingStrategy{
 			SingularTable: true,
 		},
+		// same as gorm default logger, except written with app log, so export to stdout is not polluted
+		Logger: gormlogger.New(log.New(logOutput, "\r\n", log.LstdFlags), gormlogger.Config{
+			SlowThreshold: 200 * time.Millisecond,
+			LogLevel:      gormlogger.Warn,
+			Colorful:      false,
+		}),
+		DisableAutomaticPing: disablePing,
 	})
 	if err != nil {
-		log.Fatal(err)
-		panic("Cannot connect to DB")
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	// span of every query, query variables are not recorded
+	err = db.Use(tracing.NewPlugin(tracing.WithoutMetrics(), tracing.WithoutQueryVariables()))
+	if err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// replicaSQLDBs return sql.DB of every read replica
+func replicaSQLDBs() (sqlDBs []*sql.DB) {
+	for _, replica := range dbReplicas {
+		sqlDB, err := replica.DB()
+		if err != nil {
+			fatal(err)
+		}
+		sqlDBs = append(sqlDBs, sqlDB)
+	}
+
+	return sqlDBs
+}
+
+// replicaName return name of i-th read replica used in log, metrics & readiness
+func replicaName(i int) string {
+	return fmt.Sprintf("%s_replica_%d", dbgorm.Dialector.Name(), i+1)
+}
+
+// retryConnect call connect until it succeed or retries run out, wait backoff before first retry
+// and double it every retry up to maxBackoff
+func retryConnect(name string, retries int, backoff time.Duration, maxBackoff time.Duration, connect func() error) (err error) {
+	for attempt := 0; ; attempt++ {
+		err = connect()
+		if err == nil || attempt >= retries {
+			return err
+		}
+
+		appLogger.Warn("Cannot connect, retrying...",
+			slog.String("dependency", name),
+			slog.Int("attempt", attempt+1),
+			slog.Duration("backoff", backoff),
+			slog.Any("error", err),
+		)
+		time.Sleep(backoff)
+
+		backoff *= 2
+		if maxBackoff > 0 && backoff > maxBackoff {
+			backoff = maxBackoff
+		}
 	}
-	log.Println("Connected to Database...")
 }
 
 func MigrateSQL() {
-	dbgorm.AutoMigrate(&entity.Farm{})
-	dbgorm.AutoMigrate(&entity.Pond{})
-	log.Println("Database Migration Completed...")
+	if err := migration.New(dbgorm).Up(); err != nil {
+		fatal(err)
+	}
+	appLogger.Info("Database Migration Completed...")
 }
 
 func ConnectRedis() {
@@ -74,5 +443,13 @@ func ConnectRedis() {
 		Addr:     AppConfig.Redis.Host,
 		Password: AppConfig.Redis.Password,
 	})
-	log.Println("Connected to Redis...")
+	// only statistic & cache is kept in redis, they fall back to memory until redis is reachable
+	if err := redisClient.Ping().Err(); err != nil {
+		appLogger.Warn("Cannot connect to Redis, statistic & cache fall back to memory",
+			slog.String("host", AppConfig.Redis.Host),
+			slog.Any("error", err),
+		)
+		return
+	}
+	appLogger.Info("Connected to Redis...", slog.String("host", AppConfig.Redis.Host))
 }
@@ -0,0 +1,170 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/alvinatthariq/farmsvc-go/domain"
+	"github.com/alvinatthariq/farmsvc-go/entity"
+
+	"github.com/go-redis/redis"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type redisCollector struct {
+	redisClient *redis.Client
+
+	hits       *prometheus.Desc
+	misses     *prometheus.Desc
+	timeouts   *prometheus.Desc
+	totalConns *prometheus.Desc
+	idleConns  *prometheus.Desc
+	staleConns *prometheus.Desc
+}
+
+func newRedisCollector(redisClient *redis.Client) *redisCollector {
+	fqName := func(name string) string {
+		return prometheus.BuildFQName(namespace, "redis_pool", name)
+	}
+
+	return &redisCollector{
+		redisClient: redisClient,
+		hits:        prometheus.NewDesc(fqName("hits_total"), "Total times free connection was found in the pool.", nil, nil),
+		misses:      prometheus.NewDesc(fqName("misses_total"), "Total times free connection was not found in the pool.", nil, nil),
+		timeouts:    prometheus.NewDesc(fqName("timeouts_total"), "Total times a wait timeout occurred.", nil, nil),
+		totalConns:  prometheus.NewDesc(fqName("connections"), "Number of connections in the pool.", nil, nil),
+		idleConns:   prometheus.NewDesc(fqName("idle_connections"), "Number of idle connections in the pool.", nil, nil),
+		staleConns:  prometheus.NewDesc(fqName("stale_connections_total"), "Total stale connections removed from the pool.", nil, nil),
+	}
+}
+
+func (c *redisCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.timeouts
+	ch <- c.totalConns
+	ch <- c.idleConns
+	ch <- c.staleConns
+}
+
+func (c *redisCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.redisClient.PoolStats()
+
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(c.timeouts, prometheus.CounterValue, float64(stats.Timeouts))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(stats.TotalConns))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stats.IdleConns))
+	ch <- prometheus.MustNewConstMetric(c.staleConns, prometheus.CounterValue, float64(stats.StaleConns))
+}
+
+// DomainRefreshInterval is how long farm & pond count and cache counters are served from memory before
+// they are read again, so scrape does not cost a database count & redis call every time
+var DomainRefreshInterval = 15 * time.Second
+
+// domainRefreshTimeout bound refresh, so slow database does not hold every scrape
+const domainRefreshTimeout = 5 * time.Second
+
+type domainCollector struct {
+	dom domain.DomainItf
+
+	mu              sync.Mutex
+	refreshedAt     time.Time
+	farmCount       int64
+	farmErr         error
+	pondCount       int64
+	pondErr         error
+	cacheStatistics []entity.CacheStatistic
+	cacheErr        error
+
+	farms           *prometheus.Desc
+	ponds           *prometheus.Desc
+	cacheRequests   *prometheus.Desc
+	statisticBuffer *prometheus.Desc
+	statisticEvents *prometheus.Desc
+	redisCircuit    *prometheus.Desc
+	redisRejected   *prometheus.Desc
+}
+
+func newDomainCollector(dom domain.DomainItf) *domainCollector {
+	return &domainCollector{
+		dom:             dom,
+		farms:           prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "farms"), "Number of farms not deleted.", nil, nil),
+		ponds:           prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "ponds"), "Number of ponds not deleted.", nil, nil),
+		cacheRequests:   prometheus.NewDesc(prometheus.BuildFQName(namespace, "cache", "requests_total"), "Total cache lookups by entity and result.", []string{"entity", "result"}, nil),
+		statisticBuffer: prometheus.NewDesc(prometheus.BuildFQName(namespace, "statistic_buffer", "events"), "Number of api statistic events waiting to be written.", nil, nil),
+		statisticEvents: prometheus.NewDesc(prometheus.BuildFQName(namespace, "statistic_buffer", "events_total"), "Total api statistic events by result.", []string{"result"}, nil),
+		redisCircuit:    prometheus.NewDesc(prometheus.BuildFQName(namespace, "redis_circuit", "state"), "Current state of redis circuit breaker, 1 for the current state.", []string{"state"}, nil),
+		redisRejected:   prometheus.NewDesc(prometheus.BuildFQName(namespace, "redis_circuit", "rejected_total"), "Total statistic & cache calls not sent to redis because circuit is open.", nil, nil),
+	}
+}
+
+func (c *domainCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.farms
+	ch <- c.ponds
+	ch <- c.cacheRequests
+	ch <- c.statisticBuffer
+	ch <- c.statisticEvents
+	ch <- c.redisCircuit
+	ch <- c.redisRejected
+}
+
+// refresh read farm & pond count and cache counters once DomainRefreshInterval passed,
+// concurrent scrapes wait for the same refresh
+func (c *domainCollector) refresh() {
+	if time.Since(c.refreshedAt) < DomainRefreshInterval {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), domainRefreshTimeout)
+	defer cancel()
+
+	c.farmCount, c.farmErr = c.dom.CountFarm(ctx)
+	c.pondCount, c.pondErr = c.dom.CountPond(ctx)
+	c.cacheStatistics, c.cacheErr = c.dom.GetCacheStatistic(ctx)
+	c.refreshedAt = time.Now()
+}
+
+func (c *domainCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.refresh()
+
+	if c.farmErr != nil {
+		ch <- prometheus.NewInvalidMetric(c.farms, c.farmErr)
+	} else {
+		ch <- prometheus.MustNewConstMetric(c.farms, prometheus.GaugeValue, float64(c.farmCount))
+	}
+
+	if c.pondErr != nil {
+		ch <- prometheus.NewInvalidMetric(c.ponds, c.pondErr)
+	} else {
+		ch <- prometheus.MustNewConstMetric(c.ponds, prometheus.GaugeValue, float64(c.pondCount))
+	}
+
+	if c.cacheErr != nil {
+		ch <- prometheus.NewInvalidMetric(c.cacheRequests, c.cacheErr)
+	} else {
+		for _, cacheStat := range c.cacheStatistics {
+			ch <- prometheus.MustNewConstMetric(c.cacheRequests, prometheus.CounterValue, float64(cacheStat.Hit), cacheStat.Entity, "hit")
+			ch <- prometheus.MustNewConstMetric(c.cacheRequests, prometheus.CounterValue, float64(cacheStat.Miss), cacheStat.Entity, "miss")
+		}
+	}
+
+	bufferStat := c.dom.GetAPIStatisticBufferStatistic()
+	ch <- prometheus.MustNewConstMetric(c.statisticBuffer, prometheus.GaugeValue, float64(bufferStat.Buffered))
+	ch <- prometheus.MustNewConstMetric(c.statisticEvents, prometheus.CounterValue, float64(bufferStat.Flushed), "flushed")
+	ch <- prometheus.MustNewConstMetric(c.statisticEvents, prometheus.CounterValue, float64(bufferStat.Dropped), "dropped")
+	ch <- prometheus.MustNewConstMetric(c.statisticEvents, prometheus.CounterValue, float64(bufferStat.Failed), "failed")
+
+	breakerStat := c.dom.GetRedisCircuitBreakerStatistic()
+	for _, state := range entity.CircuitStates {
+		value := 0.0
+		if state == breakerStat.State {
+			value = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.redisCircuit, prometheus.GaugeValue, value, state)
+	}
+	ch <- prometheus.MustNewConstMetric(c.redisRejected, prometheus.CounterValue, float64(breakerStat.Rejected))
+}
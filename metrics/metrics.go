@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alvinatthariq/farmsvc-go/domain"
+
+	"github.com/go-redis/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "farmsvc"
+
+// Metrics hold prometheus registry of the service, exposed in prometheus text format by Handler
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests        *prometheus.CounterVec
+	httpRequestDuration *prometheus.HistogramVec
+}
+
+// New create metrics with http & go runtime collectors registered
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Total HTTP requests by route, method and status code.",
+		}, []string{"route", "method", "status"}),
+		httpRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route, method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+	}
+
+	m.registry.MustRegister(
+		m.httpRequests,
+		m.httpRequestDuration,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return m
+}
+
+// ObserveHTTPRequest record a handled request, route is route template e.g. /v1/farm/{id}
+func (m *Metrics) ObserveHTTPRequest(route string, method string, statusCode int, latency time.Duration) {
+	status := strconv.Itoa(statusCode)
+	m.httpRequests.WithLabelValues(route, method, status).Inc()
+	m.httpRequestDuration.WithLabelValues(route, method, status).Observe(latency.Seconds())
+}
+
+// RegisterDB expose connection pool stats of db
+func (m *Metrics) RegisterDB(dbName string, db *sql.DB) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, dbName))
+}
+
+// RegisterRedis expose connection pool stats of redis client
+func (m *Metrics) RegisterRedis(redisClient *redis.Client) {
+	m.registry.MustRegister(newRedisCollector(redisClient))
+}
+
+// RegisterDomain expose domain gauges such as live farm & pond count
+func (m *Metrics) RegisterDomain(dom domain.DomainItf) {
+	m.registry.MustRegister(newDomainCollector(dom))
+}
+
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
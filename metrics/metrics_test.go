@@ -0,0 +1,84 @@
+package metrics_test
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alvinatthariq/farmsvc-go/domain"
+	"github.com/alvinatthariq/farmsvc-go/entity"
+	"github.com/alvinatthariq/farmsvc-go/metrics"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// countingDomain count calls reaching database & redis
+type countingDomain struct {
+	domain.DomainItf
+	calls int
+}
+
+func (d *countingDomain) CountFarm(ctx context.Context) (int64, error) {
+	d.calls++
+	return 3, nil
+}
+
+func (d *countingDomain) CountPond(ctx context.Context) (int64, error) {
+	d.calls++
+	return 5, nil
+}
+
+func (d *countingDomain) GetCacheStatistic(ctx context.Context) ([]entity.CacheStatistic, error) {
+	d.calls++
+	return []entity.CacheStatistic{{Entity: "farm", Hit: 2, Miss: 1}}, nil
+}
+
+func (d *countingDomain) GetAPIStatisticBufferStatistic() entity.APIStatisticBufferStatistic {
+	return entity.APIStatisticBufferStatistic{}
+}
+
+func (d *countingDomain) GetRedisCircuitBreakerStatistic() entity.CircuitBreakerStatistic {
+	return entity.CircuitBreakerStatistic{State: entity.CircuitStateClosed}
+}
+
+func scrape(m *metrics.Metrics) string {
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(rec.Body)
+	So(err, ShouldBeNil)
+
+	return string(body)
+}
+
+func TestDomainCollector(t *testing.T) {
+	Convey("TestDomainCollector", t, FailureHalts, func() {
+		refreshInterval := metrics.DomainRefreshInterval
+		defer func() { metrics.DomainRefreshInterval = refreshInterval }()
+		metrics.DomainRefreshInterval = time.Hour
+
+		dom := &countingDomain{}
+		m := metrics.New()
+		m.RegisterDomain(dom)
+
+		t.Log("1 - [P] : Success expose farm & pond count and cache counters")
+		body := scrape(m)
+		So(body, ShouldContainSubstring, "farmsvc_farms 3")
+		So(body, ShouldContainSubstring, "farmsvc_ponds 5")
+		So(body, ShouldContainSubstring, `farmsvc_cache_requests_total{entity="farm",result="hit"} 2`)
+		So(dom.calls, ShouldEqual, 3)
+
+		t.Log("2 - [P] : Success serve next scrape from memory until refresh interval pass")
+		for i := 0; i < 3; i++ {
+			So(strings.Contains(scrape(m), "farmsvc_farms 3"), ShouldBeTrue)
+		}
+		So(dom.calls, ShouldEqual, 3)
+
+		t.Log("3 - [P] : Success read again once refresh interval passed")
+		metrics.DomainRefreshInterval = 0
+		scrape(m)
+		So(dom.calls, ShouldEqual, 6)
+	})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alvinatthariq/farmsvc-go/migration"
+)
+
+const migrateUsage = "usage: farmsvc-go migrate up|down|status|to <version>"
+
+// RunMigrate handle migrate subcommand
+func RunMigrate(args []string) {
+	if len(args) < 1 {
+		log.Fatal(migrateUsage)
+	}
+
+	InitApp(os.Stderr)
+	ConnectSQL(AppConfig.Database)
+
+	migrator := migration.New(dbgorm)
+
+	var err error
+	switch args[0] {
+	case "up":
+		err = migrator.Up()
+	case "down":
+		err = migrator.Down()
+	case "to":
+		if len(args) < 2 {
+			log.Fatal(migrateUsage)
+		}
+		version, parseErr := strconv.ParseInt(args[1], 10, 64)
+		if parseErr != nil {
+			log.Fatal(fmt.Errorf("Invalid Migration Version : %w", parseErr))
+		}
+		err = migrator.To(version)
+	case "status":
+		err = printMigrationStatus(migrator)
+	default:
+		log.Fatal(migrateUsage)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	version, err := migrator.Current()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println(fmt.Sprintf("Database Schema Version %d", version))
+}
+
+func printMigrationStatus(migrator *migration.Migrator) error {
+	statuses, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+	for _, status := range statuses {
+		appliedAt := "pending"
+		if status.Applied {
+			appliedAt = status.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+	}
+
+	return w.Flush()
+}
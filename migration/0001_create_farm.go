@@ -0,0 +1,39 @@
+package migration
+
+import (
+	"database/sql"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type farm0001 struct {
+	ID          string `gorm:"primaryKey;type:varchar(36)"`
+	Name        string `gorm:"type:varchar(100)"`
+	Description string `gorm:"type:varchar(150)"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   sql.NullTime
+	IsDeleted   sql.NullBool
+}
+
+func (farm0001) TableName() string {
+	return "farm"
+}
+
+func init() {
+	register(Migration{
+		Version: 1,
+		Name:    "create_farm",
+		Up: func(tx *gorm.DB) error {
+			// table may already exist from AutoMigrate before versioned migration
+			if tx.Migrator().HasTable(&farm0001{}) {
+				return nil
+			}
+			return tx.Migrator().CreateTable(&farm0001{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&farm0001{})
+		},
+	})
+}
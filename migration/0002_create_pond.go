@@ -0,0 +1,40 @@
+package migration
+
+import (
+	"database/sql"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type pond0002 struct {
+	ID          string `gorm:"primaryKey;type:varchar(36)"`
+	FarmID      string `gorm:"type:varchar(36)"`
+	Name        string `gorm:"type:varchar(100)"`
+	Description string `gorm:"type:varchar(150)"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   sql.NullTime
+	IsDeleted   sql.NullBool
+}
+
+func (pond0002) TableName() string {
+	return "pond"
+}
+
+func init() {
+	register(Migration{
+		Version: 2,
+		Name:    "create_pond",
+		Up: func(tx *gorm.DB) error {
+			// table may already exist from AutoMigrate before versioned migration
+			if tx.Migrator().HasTable(&pond0002{}) {
+				return nil
+			}
+			return tx.Migrator().CreateTable(&pond0002{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&pond0002{})
+		},
+	})
+}
@@ -0,0 +1,46 @@
+package migration
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type apiStatisticSnapshot0003 struct {
+	ID              int64  `gorm:"primaryKey;autoIncrement"`
+	Path            string `gorm:"type:varchar(255);index:idx_api_statistic_snapshot_path_snapshot_at,priority:1"`
+	Count           int64
+	UniqueUserAgent int64
+	Status1xx       int64 `gorm:"column:status_1xx"`
+	Status2xx       int64 `gorm:"column:status_2xx"`
+	Status3xx       int64 `gorm:"column:status_3xx"`
+	Status4xx       int64 `gorm:"column:status_4xx"`
+	Status5xx       int64 `gorm:"column:status_5xx"`
+	ErrorCount      int64
+	LatencyP50      float64
+	LatencyP95      float64
+	LatencyP99      float64
+	Reason          string    `gorm:"type:varchar(20)"`
+	SnapshotAt      time.Time `gorm:"index:idx_api_statistic_snapshot_snapshot_at;index:idx_api_statistic_snapshot_path_snapshot_at,priority:2"`
+}
+
+func (apiStatisticSnapshot0003) TableName() string {
+	return "api_statistic_snapshot"
+}
+
+func init() {
+	register(Migration{
+		Version: 3,
+		Name:    "create_api_statistic_snapshot",
+		Up: func(tx *gorm.DB) error {
+			// table may be left by failed run on mysql, which commit ddl
+			if tx.Migrator().HasTable(&apiStatisticSnapshot0003{}) {
+				return nil
+			}
+			return tx.Migrator().CreateTable(&apiStatisticSnapshot0003{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&apiStatisticSnapshot0003{})
+		},
+	})
+}
@@ -0,0 +1,56 @@
+package migration
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+type farm0004 struct {
+	ID string `gorm:"primaryKey;type:varchar(36)"`
+}
+
+func (farm0004) TableName() string {
+	return "farm"
+}
+
+// pond0004 only declare relation of the constraint, columns are created by 0002
+type pond0004 struct {
+	ID     string   `gorm:"primaryKey;type:varchar(36)"`
+	FarmID string   `gorm:"type:varchar(36)"`
+	Farm   farm0004 `gorm:"foreignKey:FarmID;constraint:OnDelete:CASCADE"`
+}
+
+func (pond0004) TableName() string {
+	return "pond"
+}
+
+func init() {
+	register(Migration{
+		Version: 4,
+		Name:    "add_pond_farm_foreign_key",
+		Up: func(tx *gorm.DB) error {
+			// constraint may be left by failed run on mysql, which commit ddl
+			if tx.Migrator().HasConstraint(&pond0004{}, "Farm") {
+				return nil
+			}
+
+			// pond created before the constraint may refer farm removed since, constraint cannot be added over it
+			var orphans int64
+			err := tx.Table("pond").Where("farm_id NOT IN (?)", tx.Table("farm").Select("id")).Count(&orphans).Error
+			if err != nil {
+				return err
+			} else if orphans > 0 {
+				return fmt.Errorf("%d ponds refer farm not found, fix or remove them before migrating", orphans)
+			}
+
+			return tx.Migrator().CreateConstraint(&pond0004{}, "Farm")
+		},
+		Down: func(tx *gorm.DB) error {
+			if !tx.Migrator().HasConstraint(&pond0004{}, "Farm") {
+				return nil
+			}
+			return tx.Migrator().DropConstraint(&pond0004{}, "Farm")
+		},
+	})
+}
@@ -0,0 +1,341 @@
+package migration
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LockTimeout is how long To wait for migration run by another replica before giving up
+var LockTimeout = 5 * time.Minute
+
+const (
+	// lockName is mysql named lock & lockKey is postgres advisory lock key held while migrating, shared by every replica
+	lockName       = "farmsvc_schema_migration"
+	lockKey  int64 = 7_307_934_651_072_413_696
+	// lockRetryInterval is wait between attempts to take sqlite lock row
+	lockRetryInterval = 100 * time.Millisecond
+)
+
+// Migration is one versioned schema step. Up & Down must be idempotent, MySQL commit DDL implicitly
+// so step failed halfway may have changed schema without being recorded, and it is run again
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// SchemaMigration is row of applied migration in schema_migration table
+type SchemaMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"type:varchar(100)"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migration"
+}
+
+// SchemaMigrationLock is lock row held while migrating sqlite, which has no named lock
+type SchemaMigrationLock struct {
+	ID       int       `gorm:"primaryKey;autoIncrement:false"`
+	LockedAt time.Time `gorm:"not null"`
+}
+
+func (SchemaMigrationLock) TableName() string {
+	return "schema_migration_lock"
+}
+
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+var migrations []Migration
+
+// register add migration, called from init of each migration file. Version registered twice panic
+func register(m Migration) {
+	if isRegistered(m.Version) {
+		panic(fmt.Sprintf("migration version %d registered twice", m.Version))
+	}
+
+	migrations = append(migrations, m)
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+}
+
+type Migrator struct {
+	db *gorm.DB
+}
+
+func New(db *gorm.DB) *Migrator {
+	return &Migrator{
+		db: db,
+	}
+}
+
+// Latest return version of last registered migration
+func Latest() int64 {
+	if len(migrations) < 1 {
+		return 0
+	}
+
+	return migrations[len(migrations)-1].Version
+}
+
+// Up apply all pending migrations
+func (m *Migrator) Up() error {
+	return m.To(Latest())
+}
+
+// Down rollback last applied migration
+func (m *Migrator) Down() error {
+	current, err := m.Current()
+	if err != nil {
+		return err
+	}
+
+	var target int64
+	for _, migration := range migrations {
+		if migration.Version < current {
+			target = migration.Version
+		}
+	}
+
+	return m.To(target)
+}
+
+// To apply or rollback migrations until schema is at version, 0 rollback everything.
+// Replicas starting together migrate one at a time, the others wait then find migrations applied
+func (m *Migrator) To(version int64) error {
+	if version != 0 && !isRegistered(version) {
+		return fmt.Errorf("migration version %d not found", version)
+	}
+
+	return m.withLock(func() error {
+		return m.to(version)
+	})
+}
+
+func (m *Migrator) to(version int64) error {
+	// table is created only here while holding the lock, reading version never change schema
+	if err := m.db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return fmt.Errorf("create schema_migration table : %w", err)
+	}
+
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+
+	// apply pending migrations up to version in ascending order
+	for _, migration := range migrations {
+		if migration.Version > version {
+			break
+		}
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		if err := m.run(migration, true); err != nil {
+			return err
+		}
+	}
+
+	// rollback applied migrations above version in descending order
+	for i := len(migrations) - 1; i >= 0; i-- {
+		migration := migrations[i]
+		if migration.Version <= version {
+			break
+		}
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if err := m.run(migration, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Current return version of last applied migration, 0 if none applied
+func (m *Migrator) Current() (version int64, err error) {
+	applied, err := m.applied()
+	if err != nil {
+		return 0, err
+	}
+
+	for v := range applied {
+		if v > version {
+			version = v
+		}
+	}
+
+	return version, nil
+}
+
+// Status return every registered migration and whether it is applied
+func (m *Migrator) Status() (statuses []Status, err error) {
+	applied, err := m.applied()
+	if err != nil {
+		return statuses, err
+	}
+
+	for _, migration := range migrations {
+		schemaMigration, ok := applied[migration.Version]
+		statuses = append(statuses, Status{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			Applied:   ok,
+			AppliedAt: schemaMigration.AppliedAt,
+		})
+	}
+
+	return statuses, nil
+}
+
+// applied return applied migrations by version, none when schema_migration table is not created yet
+func (m *Migrator) applied() (applied map[int64]SchemaMigration, err error) {
+	applied = map[int64]SchemaMigration{}
+	if !m.db.Migrator().HasTable(&SchemaMigration{}) {
+		return applied, nil
+	}
+
+	var schemaMigrations []SchemaMigration
+	if err := m.db.Find(&schemaMigrations).Error; err != nil {
+		return applied, err
+	}
+
+	for _, schemaMigration := range schemaMigrations {
+		applied[schemaMigration.Version] = schemaMigration
+	}
+
+	return applied, nil
+}
+
+// run migration up or down and record it in schema_migration within one transaction. Transaction make it atomic
+// on PostgreSQL & SQLite only, MySQL commit each DDL statement so failed step is left partly applied & unrecorded
+func (m *Migrator) run(migration Migration, up bool) error {
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		if up {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+
+			return tx.Create(&SchemaMigration{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				AppliedAt: time.Now().UTC(),
+			}).Error
+		}
+
+		if err := migration.Down(tx); err != nil {
+			return err
+		}
+
+		return tx.Delete(&SchemaMigration{}, "version = ?", migration.Version).Error
+	})
+	if err != nil {
+		direction := "up"
+		if !up {
+			direction = "down"
+		}
+		return fmt.Errorf("migration %d_%s %s : %w", migration.Version, migration.Name, direction, err)
+	}
+
+	return nil
+}
+
+// withLock run fn while holding migration lock shared by every replica, waiting up to LockTimeout for it.
+// MySQL & PostgreSQL lock is bound to connection, so it is released when the process die
+func (m *Migrator) withLock(fn func() error) error {
+	switch m.db.Dialector.Name() {
+	case "mysql":
+		return m.db.Connection(func(conn *gorm.DB) error {
+			var acquired sql.NullInt64
+			if err := conn.Raw("SELECT GET_LOCK(?, ?)", lockName, int(LockTimeout.Seconds())).Row().Scan(&acquired); err != nil {
+				return fmt.Errorf("acquire migration lock : %w", err)
+			} else if acquired.Int64 != 1 {
+				return fmt.Errorf("acquire migration lock : not released after %s", LockTimeout)
+			}
+			defer conn.Exec("SELECT RELEASE_LOCK(?)", lockName)
+
+			return fn()
+		})
+	case "postgres":
+		return m.db.Connection(func(conn *gorm.DB) error {
+			// advisory lock wait forever unless bound by lock_timeout of the session
+			if err := conn.Exec(fmt.Sprintf("SET lock_timeout = %d", LockTimeout.Milliseconds())).Error; err != nil {
+				return err
+			}
+			defer conn.Exec("RESET lock_timeout")
+
+			if err := conn.Exec("SELECT pg_advisory_lock(?)", lockKey).Error; err != nil {
+				return fmt.Errorf("acquire migration lock : %w", err)
+			}
+			defer conn.Exec("SELECT pg_advisory_unlock(?)", lockKey)
+
+			return fn()
+		})
+	default:
+		return m.withLockRow(fn)
+	}
+}
+
+// withLockRow run fn while holding lock row, row older than LockTimeout is left by a process died while migrating
+func (m *Migrator) withLockRow(fn func() error) error {
+	if err := m.db.AutoMigrate(&SchemaMigrationLock{}); err != nil {
+		return fmt.Errorf("create schema_migration_lock table : %w", err)
+	}
+
+	deadline := time.Now().Add(LockTimeout)
+	for {
+		// only write once lock look free, so waiting replicas do not contend with the one migrating
+		now := time.Now().UTC()
+		var locks []SchemaMigrationLock
+		if err := m.db.Find(&locks).Error; err != nil {
+			return fmt.Errorf("acquire migration lock : %w", err)
+		}
+		if len(locks) > 0 && locks[0].LockedAt.Before(now.Add(-LockTimeout)) {
+			if err := m.db.Delete(&SchemaMigrationLock{}, "id = ? AND locked_at = ?", locks[0].ID, locks[0].LockedAt).Error; err != nil {
+				return fmt.Errorf("acquire migration lock : %w", err)
+			}
+			locks = nil
+		}
+
+		if len(locks) < 1 {
+			res := m.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&SchemaMigrationLock{ID: 1, LockedAt: now})
+			if res.Error != nil {
+				return fmt.Errorf("acquire migration lock : %w", res.Error)
+			} else if res.RowsAffected == 1 {
+				break
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("acquire migration lock : not released after %s", LockTimeout)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+	defer m.db.Delete(&SchemaMigrationLock{}, "id = ?", 1)
+
+	return fn()
+}
+
+func isRegistered(version int64) bool {
+	for _, migration := range migrations {
+		if migration.Version == version {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,116 @@
+package migration_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/alvinatthariq/farmsvc-go/migration"
+	"github.com/alvinatthariq/farmsvc-go/repository"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+func TestMigrator(t *testing.T) {
+	Convey("TestMigrator", t, FailureHalts, func() {
+		dialector, err := repository.NewDialector(repository.DriverSQLite, "file::memory:")
+		So(err, ShouldBeNil)
+
+		db, err := gorm.Open(dialector, &gorm.Config{
+			NamingStrategy: schema.NamingStrategy{
+				SingularTable: true,
+			},
+		})
+		So(err, ShouldBeNil)
+
+		// every connection of in memory sqlite has its own database
+		sqlDB, err := db.DB()
+		So(err, ShouldBeNil)
+		sqlDB.SetMaxOpenConns(1)
+
+		migrator := migration.New(db)
+
+		t.Log("1 - [P] : Success read version of new database without creating table")
+		version, err := migrator.Current()
+		So(err, ShouldBeNil)
+		So(version, ShouldEqual, 0)
+		statuses, err := migrator.Status()
+		So(err, ShouldBeNil)
+		So(statuses[0].Applied, ShouldBeFalse)
+		So(db.Migrator().HasTable(&migration.SchemaMigration{}), ShouldBeFalse)
+
+		t.Log("2 - [P] : Success migrate up")
+		So(migrator.Up(), ShouldBeNil)
+		version, err = migrator.Current()
+		So(err, ShouldBeNil)
+		So(version, ShouldEqual, migration.Latest())
+		So(db.Migrator().HasTable("farm"), ShouldBeTrue)
+		So(db.Migrator().HasTable("pond"), ShouldBeTrue)
+		So(db.Migrator().HasConstraint("pond", "fk_pond_farm"), ShouldBeTrue)
+
+		t.Log("3 - [P] : Success migrate down")
+		So(migrator.Down(), ShouldBeNil)
+		statuses, err = migrator.Status()
+		So(err, ShouldBeNil)
+		So(statuses[len(statuses)-1].Applied, ShouldBeFalse)
+
+		t.Log("4 - [P] : Success migrate to 0")
+		So(migrator.To(0), ShouldBeNil)
+		So(db.Migrator().HasTable("farm"), ShouldBeFalse)
+
+		t.Log("5 - [N] : Failed migrate to unknown version")
+		So(migrator.To(99999), ShouldNotBeNil)
+
+		t.Log("6 - [P] : Success rerun every step left applied but unrecorded, as failed step on mysql")
+		So(migrator.Up(), ShouldBeNil)
+		So(db.Where("1 = 1").Delete(&migration.SchemaMigration{}).Error, ShouldBeNil)
+		So(migrator.Up(), ShouldBeNil)
+		version, err = migrator.Current()
+		So(err, ShouldBeNil)
+		So(version, ShouldEqual, migration.Latest())
+	})
+}
+
+func TestMigratorConcurrent(t *testing.T) {
+	Convey("TestMigratorConcurrent", t, FailureHalts, func() {
+		path := filepath.Join(t.TempDir(), "farm.db")
+
+		t.Log("1 - [P] : Success migrate up from replicas starting together")
+		errs := make(chan error, 4)
+		for i := 0; i < cap(errs); i++ {
+			dialector, err := repository.NewDialector(repository.DriverSQLite, path)
+			So(err, ShouldBeNil)
+			db, err := gorm.Open(dialector, &gorm.Config{
+				NamingStrategy: schema.NamingStrategy{
+					SingularTable: true,
+				},
+			})
+			So(err, ShouldBeNil)
+
+			go func() {
+				errs <- migration.New(db).Up()
+			}()
+		}
+		for i := 0; i < cap(errs); i++ {
+			So(<-errs, ShouldBeNil)
+		}
+
+		dialector, err := repository.NewDialector(repository.DriverSQLite, path)
+		So(err, ShouldBeNil)
+		db, err := gorm.Open(dialector, &gorm.Config{
+			NamingStrategy: schema.NamingStrategy{
+				SingularTable: true,
+			},
+		})
+		So(err, ShouldBeNil)
+
+		var applied int64
+		So(db.Model(&migration.SchemaMigration{}).Count(&applied).Error, ShouldBeNil)
+		So(applied, ShouldEqual, migration.Latest())
+
+		var locks int64
+		So(db.Model(&migration.SchemaMigrationLock{}).Count(&locks).Error, ShouldBeNil)
+		So(locks, ShouldEqual, 0)
+	})
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/alvinatthariq/farmsvc-go/domain"
+	"github.com/alvinatthariq/farmsvc-go/logger"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// runtimeConfig is config applied without restart. It is replaced as a whole on reload, so every reader see
+// either the old or the new config and never a mix of both
+type runtimeConfig struct {
+	logLevel         slog.Level
+	traceSampleRatio float64
+	domain           domain.Settings
+}
+
+// currentRuntime is read by app logger, tracer sampler & domain on every use
+var currentRuntime atomic.Pointer[runtimeConfig]
+
+// runtime return runtime config of c, c must be valid
+func (c *Config) runtime() *runtimeConfig {
+	level, _ := logger.ParseLevel(c.Log.Level)
+
+	return &runtimeConfig{
+		logLevel:         level,
+		traceSampleRatio: c.Tracing.SampleRatio,
+		domain: domain.Settings{
+			FarmCacheTTL:         c.Redis.Cache.FarmTTL,
+			PondCacheTTL:         c.Redis.Cache.PondTTL,
+			StatisticSampleRatio: c.Redis.Statistic.SampleRatio,
+		},
+	}
+}
+
+// runtimeLogLevel is minimum level of app logger from current runtime config
+type runtimeLogLevel struct{}
+
+func (runtimeLogLevel) Level() slog.Level {
+	return currentRuntime.Load().logLevel
+}
+
+func runtimeTraceSampleRatio() float64 {
+	return currentRuntime.Load().traceSampleRatio
+}
+
+func runtimeDomainSettings() domain.Settings {
+	return currentRuntime.Load().domain
+}
+
+// reloadable return value of every config key applied without restart, keyed by viper key.
+// Change of other key is logged and ignored until restart
+func (c *Config) reloadable() map[string]interface{} {
+	return map[string]interface{}{
+		"log.level":                    c.Log.Level,
+		"tracing.sample_ratio":         c.Tracing.SampleRatio,
+		"redis.cache.farm_ttl":         c.Redis.Cache.FarmTTL,
+		"redis.cache.pond_ttl":         c.Redis.Cache.PondTTL,
+		"redis.statistic.sample_ratio": c.Redis.Statistic.SampleRatio,
+	}
+}
+
+// configReloader apply reloadable config of changed config file or on SIGHUP
+type configReloader struct {
+	current Config
+}
+
+// WatchConfig reload config when config file change or SIGHUP is received until the process exit
+func WatchConfig() {
+	r := &configReloader{current: *AppConfig}
+
+	// reload one at a time, file change while a reload is pending is merged into it
+	triggers := make(chan string, 1)
+	if AppConfig.file != "" {
+		if err := watchConfigFile(AppConfig.file, triggers); err != nil {
+			appLogger.Warn("Cannot watch config file, reload on SIGHUP only", slog.String("file", AppConfig.file), slog.Any("error", err))
+		}
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-hup:
+				r.reload("SIGHUP")
+			case trigger := <-triggers:
+				r.reload(trigger)
+			}
+		}
+	}()
+}
+
+// watchConfigFile send to triggers when file is written or replaced. Directory of file is watched rather than
+// file itself, since editor & kubernetes config map replace the file, or the symlink pointing to it
+func watchConfigFile(file string, triggers chan<- string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	file = filepath.Clean(file)
+	if err := watcher.Add(filepath.Dir(file)); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	realFile, _ := filepath.EvalSymlinks(file)
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				currentRealFile, _ := filepath.EvalSymlinks(file)
+				written := filepath.Clean(event.Name) == file && event.Op&(fsnotify.Write|fsnotify.Create) != 0
+				if !written && currentRealFile == realFile {
+					continue
+				}
+				realFile = currentRealFile
+
+				select {
+				case triggers <- "file " + event.Op.String():
+				default:
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				appLogger.Warn("Config file watch error", slog.Any("error", err))
+			}
+		}
+	}()
+
+	return nil
+}
+
+// reload load config again and apply every reloadable key at once, invalid config is ignored entirely
+func (r *configReloader) reload(trigger string) {
+	config, err := loadConfig()
+	if err == nil {
+		err = config.Validate()
+	}
+	if err != nil {
+		appLogger.Error("Config reload rejected, keep current config", slog.String("trigger", trigger), slog.Any("error", err))
+		return
+	}
+
+	current, next := r.current.reloadable(), config.reloadable()
+	for _, key := range diffConfig(reflect.ValueOf(r.current), reflect.ValueOf(*config), "") {
+		if _, ok := next[key]; !ok {
+			appLogger.Warn("Config changed, restart to apply", slog.String("trigger", trigger), slog.String("key", key))
+		}
+	}
+
+	changed := false
+	for key, value := range next {
+		if value != current[key] {
+			changed = true
+			appLogger.Info("Config applied",
+				slog.String("trigger", trigger),
+				slog.String("key", key),
+				slog.String("old", fmt.Sprint(current[key])),
+				slog.String("new", fmt.Sprint(value)),
+			)
+		}
+	}
+	if !changed {
+		appLogger.Info("Config reloaded, nothing to apply", slog.String("trigger", trigger))
+		return
+	}
+
+	// only reloadable key is taken, other change still need restart
+	r.current.Log.Level = config.Log.Level
+	r.current.Tracing.SampleRatio = config.Tracing.SampleRatio
+	r.current.Redis.Cache = config.Redis.Cache
+	r.current.Redis.Statistic.SampleRatio = config.Redis.Statistic.SampleRatio
+	currentRuntime.Store(r.current.runtime())
+}
+
+// diffConfig return viper key of every field differing between a & b, nested struct is compared field by field
+func diffConfig(a reflect.Value, b reflect.Value, prefix string) (keys []string) {
+	for i := 0; i < a.NumField(); i++ {
+		field := a.Type().Field(i)
+		key := field.Tag.Get("mapstructure")
+		if key == "" {
+			continue
+		}
+		key = prefix + key
+
+		if field.Type.Kind() == reflect.Struct {
+			keys = append(keys, diffConfig(a.Field(i), b.Field(i), key+".")...)
+			continue
+		}
+		if !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
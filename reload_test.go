@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alvinatthariq/farmsvc-go/logger"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDiffConfig(t *testing.T) {
+	Convey("TestDiffConfig", t, FailureHalts, func() {
+		testCases := []struct {
+			testID   int
+			testType string
+			testDesc string
+			modify   func(config *Config)
+			expected []string
+		}{
+			{testID: 1, testType: "P", testDesc: "Success diff unchanged config", modify: func(config *Config) {}},
+			{testID: 2, testType: "P", testDesc: "Success diff top level & nested key", modify: func(config *Config) {
+				config.Port = "9090"
+				config.Redis.Cache.FarmTTL = time.Minute
+				config.Redis.CircuitBreaker.OpenTimeout = time.Minute
+			}, expected: []string{"redis.cache.farm_ttl", "redis.circuit_breaker.open_timeout", "port"}},
+			{testID: 3, testType: "P", testDesc: "Success diff map & list key", modify: func(config *Config) {
+				config.Server.RouteTimeouts = map[string]time.Duration{"GET /v1/farm": time.Second}
+				config.Database.Replicas = []string{"file:replica.db"}
+			}, expected: []string{"database.replicas", "server.route_timeouts"}},
+			{testID: 4, testType: "P", testDesc: "Success diff deprecated section", modify: func(config *Config) {
+				config.MySQL.ConnectionString = "root:@tcp(db:3306)/farm_db"
+			}, expected: []string{"mysql.connection_string"}},
+			{testID: 5, testType: "P", testDesc: "Success ignore field which is not config key", modify: func(config *Config) {
+				config.file = "other.json"
+			}},
+		}
+
+		for _, tc := range testCases {
+			t.Logf("%d - [%s] : %s", tc.testID, tc.testType, tc.testDesc)
+			config, err := loadTestConfig(t, nil)
+			So(err, ShouldBeNil)
+			next := *config
+			tc.modify(&next)
+
+			So(diffConfig(reflect.ValueOf(*config), reflect.ValueOf(next), ""), ShouldResemble, tc.expected)
+		}
+	})
+}
+
+func TestConfigReload(t *testing.T) {
+	Convey("TestConfigReload", t, FailureHalts, func() {
+		file := writeFile(t, "config.json", testConfigFile)
+		t.Setenv(EnvConfigFile, file)
+		config, err := loadConfig()
+		So(err, ShouldBeNil)
+		So(config.Validate(), ShouldBeNil)
+
+		logs := &bytes.Buffer{}
+		appLogger = logger.New(logger.Options{Output: logs, Level: slog.LevelDebug})
+		currentRuntime.Store(config.runtime())
+		r := &configReloader{current: *config}
+
+		testCases := []struct {
+			testID   int
+			testType string
+			testDesc string
+			content  string
+			logs     []string
+		}{
+			{
+				testID:   1,
+				testType: "P",
+				testDesc: "Success apply reloadable key, other change wait for restart",
+				content: `{
+					"port": "9090",
+					"database": {"driver": "sqlite", "connection_string": "file::memory:"},
+					"redis": {"host": "localhost:6379", "cache": {"farm_ttl": "1m"}, "statistic": {"sample_ratio": 0.5}},
+					"log": {"level": "debug"},
+					"tracing": {"sample_ratio": 0.1}
+				}`,
+				logs: []string{`"msg":"Config applied"`, `"key":"log.level","old":"info","new":"debug"`, `"msg":"Config changed, restart to apply"`, `"key":"port"`},
+			},
+			{
+				testID:   2,
+				testType: "N",
+				testDesc: "Failed reload invalid config, current config kept",
+				content: `{
+					"database": {"driver": "sqlite", "connection_string": "file::memory:"},
+					"redis": {"host": "localhost:6379", "cache": {"farm_ttl": "2m"}},
+					"log": {"level": "verbose"}
+				}`,
+				logs: []string{`"msg":"Config reload rejected, keep current config"`, "log.level (FARMSVC_LOG_LEVEL)"},
+			},
+			{
+				testID:   3,
+				testType: "N",
+				testDesc: "Failed reload malformed config file, current config kept",
+				content:  `{"log": {"level": "warn"`,
+				logs:     []string{`"msg":"Config reload rejected, keep current config"`, "Cannot read config file"},
+			},
+		}
+
+		for _, tc := range testCases {
+			t.Logf("%d - [%s] : %s", tc.testID, tc.testType, tc.testDesc)
+			logs.Reset()
+			So(os.WriteFile(file, []byte(tc.content), 0o600), ShouldBeNil)
+			r.reload("test")
+
+			for _, line := range tc.logs {
+				So(logs.String(), ShouldContainSubstring, line)
+			}
+
+			// every case end with the config of the first one
+			runtime := currentRuntime.Load()
+			So(runtime.logLevel, ShouldEqual, slog.LevelDebug)
+			So(runtime.traceSampleRatio, ShouldEqual, 0.1)
+			So(runtime.domain.FarmCacheTTL, ShouldEqual, time.Minute)
+			So(runtime.domain.StatisticSampleRatio, ShouldEqual, 0.5)
+			So(runtimeLogLevel{}.Level(), ShouldEqual, slog.LevelDebug)
+			So(r.current.Port, ShouldEqual, "8080")
+		}
+	})
+}
+
+func TestWatchConfigFile(t *testing.T) {
+	Convey("TestWatchConfigFile", t, FailureHalts, func() {
+		file := writeFile(t, "config.json", testConfigFile)
+		triggers := make(chan string, 1)
+		So(watchConfigFile(file, triggers), ShouldBeNil)
+
+		t.Log("1 - [P] : Success trigger reload when config file is written")
+		So(os.WriteFile(file, []byte(testConfigFile), 0o600), ShouldBeNil)
+		select {
+		case trigger := <-triggers:
+			So(trigger, ShouldStartWith, "file ")
+		case <-time.After(5 * time.Second):
+			So("no trigger", ShouldBeEmpty)
+		}
+		// a write may be seen as more than one event
+		time.Sleep(100 * time.Millisecond)
+		select {
+		case <-triggers:
+		default:
+		}
+
+		t.Log("2 - [P] : Success ignore other file of the directory")
+		So(os.WriteFile(file+".bak", []byte(testConfigFile), 0o600), ShouldBeNil)
+		select {
+		case trigger := <-triggers:
+			So(trigger, ShouldBeEmpty)
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+}
@@ -0,0 +1,330 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alvinatthariq/farmsvc-go/entity"
+)
+
+// memoryAPIBucketPruneInterval is how often buckets past their retention are removed
+const memoryAPIBucketPruneInterval = time.Minute
+
+type memoryStatistic struct {
+	mu             sync.RWMutex
+	apiCount       map[string]int64
+	apiBuckets     map[string]*memoryAPIBucket
+	bucketsPruned  time.Time
+	apiStatus      map[string]map[string]int64
+	apiErrors      map[string]int64
+	apiLatency     map[string]map[string]int64
+	userAgents     map[string]map[string]struct{}
+	cacheStats     map[string]entity.CacheStatistic
+	clients        map[string]int64
+	userAgentCount map[string]int64
+	clientIPs      map[string]struct{}
+	locks          map[string]time.Time
+	resources      map[string]map[string]*memoryResourceAccess
+}
+
+// memoryAPIBucket is a series bucket, expiredAt is moved on every write as redis EXPIRE
+type memoryAPIBucket struct {
+	count     int64
+	expiredAt time.Time
+}
+
+type memoryResourceAccess struct {
+	read, write         int64
+	lastRead, lastWrite int64
+}
+
+func NewMemoryStatistic() StatisticRepository {
+	return &memoryStatistic{
+		apiCount:       map[string]int64{},
+		apiBuckets:     map[string]*memoryAPIBucket{},
+		apiStatus:      map[string]map[string]int64{},
+		apiErrors:      map[string]int64{},
+		apiLatency:     map[string]map[string]int64{},
+		userAgents:     map[string]map[string]struct{}{},
+		cacheStats:     map[string]entity.CacheStatistic{},
+		clients:        map[string]int64{},
+		userAgentCount: map[string]int64{},
+		clientIPs:      map[string]struct{}{},
+		locks:          map[string]time.Time{},
+		resources:      map[string]map[string]*memoryResourceAccess{},
+	}
+}
+
+func (r *memoryStatistic) RecordAPIStatistics(ctx context.Context, events []entity.APIStatisticEvent) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for _, event := range events {
+		r.recordAPIStatistic(event, now)
+	}
+	r.pruneAPIBuckets(now)
+
+	return nil
+}
+
+// pruneAPIBuckets remove buckets past their retention, at most once every memoryAPIBucketPruneInterval
+func (r *memoryStatistic) pruneAPIBuckets(now time.Time) {
+	if now.Sub(r.bucketsPruned) < memoryAPIBucketPruneInterval {
+		return
+	}
+	r.bucketsPruned = now
+
+	for key, bucket := range r.apiBuckets {
+		if !now.Before(bucket.expiredAt) {
+			delete(r.apiBuckets, key)
+		}
+	}
+}
+
+func (r *memoryStatistic) recordAPIStatistic(event entity.APIStatisticEvent, now time.Time) {
+	r.apiCount[event.Path]++
+	for _, granularity := range entity.Granularities {
+		key := apiStatisticBucketKey(event.Path, granularity, bucketOf(event.At, granularity))
+		bucket := r.apiBuckets[key]
+		if bucket == nil {
+			bucket = &memoryAPIBucket{}
+			r.apiBuckets[key] = bucket
+		}
+		bucket.count++
+		bucket.expiredAt = now.Add(granularity.Retention)
+	}
+
+	if r.userAgents[event.Path] == nil {
+		r.userAgents[event.Path] = map[string]struct{}{}
+		r.apiStatus[event.Path] = map[string]int64{}
+		r.apiLatency[event.Path] = map[string]int64{}
+	}
+	r.userAgents[event.Path][event.UserAgent] = struct{}{}
+
+	r.apiStatus[event.Path][event.StatusClass()]++
+	if event.IsError() {
+		r.apiErrors[event.Path]++
+	}
+	r.apiLatency[event.Path][event.LatencyBucket()]++
+
+	r.clients[event.ClientID]++
+	r.userAgentCount[event.UserAgent]++
+	if event.ClientIP != "" {
+		r.clientIPs[event.ClientIP] = struct{}{}
+	}
+
+	if event.Resource != "" && event.ResourceID != "" {
+		if r.resources[event.Resource] == nil {
+			r.resources[event.Resource] = map[string]*memoryResourceAccess{}
+		}
+		access := r.resources[event.Resource][event.ResourceID]
+		if access == nil {
+			access = &memoryResourceAccess{}
+			r.resources[event.Resource][event.ResourceID] = access
+		}
+
+		switch event.ResourceAccess {
+		case entity.ResourceAccessRead:
+			access.read++
+			access.lastRead = event.At.Unix()
+		case entity.ResourceAccessWrite:
+			access.write++
+			access.lastWrite = event.At.Unix()
+		}
+	}
+}
+
+func (r *memoryStatistic) GetResourceStatistic(ctx context.Context, resource string, id string) (resourceStatistic entity.ResourceStatistic, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.resourceStatistic(resource, id), nil
+}
+
+func (r *memoryStatistic) resourceStatistic(resource string, id string) entity.ResourceStatistic {
+	access := r.resources[resource][id]
+	if access == nil {
+		access = &memoryResourceAccess{}
+	}
+
+	return entity.NewResourceStatistic(resource, id, access.read, access.write, access.lastRead, access.lastWrite)
+}
+
+func (r *memoryStatistic) GetTopResourceStatistics(ctx context.Context, resource string, top int) (resourceStatistics []entity.ResourceStatistic, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := map[string]int64{}
+	for id, access := range r.resources[resource] {
+		counts[id] = access.read + access.write
+	}
+
+	resourceStatistics = []entity.ResourceStatistic{}
+	for _, count := range topCounts(counts, top) {
+		resourceStatistics = append(resourceStatistics, r.resourceStatistic(resource, count.Name))
+	}
+
+	return resourceStatistics, nil
+}
+
+func (r *memoryStatistic) GetResourceStatistics(ctx context.Context, resource string, ids []string) (resourceStatistics []entity.ResourceStatistic, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	resourceStatistics = []entity.ResourceStatistic{}
+	for _, id := range ids {
+		resourceStatistics = append(resourceStatistics, r.resourceStatistic(resource, id))
+	}
+
+	return resourceStatistics, nil
+}
+
+func (r *memoryStatistic) DeleteResourceStatistics(ctx context.Context, resource string, ids []string) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range ids {
+		delete(r.resources[resource], id)
+	}
+
+	return nil
+}
+
+func (r *memoryStatistic) GetClientStatistic(ctx context.Context, top int) (clientStatistic entity.ClientStatistic, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return entity.ClientStatistic{
+		TopClients:    topCounts(r.clients, top),
+		TopUserAgents: topCounts(r.userAgentCount, top),
+		UniqueIP:      int64(len(r.clientIPs)),
+	}, nil
+}
+
+// topCounts return top n of counts ordered by count descending, then name descending as redis ZREVRANGE
+func topCounts(counts map[string]int64, top int) []entity.ClientCount {
+	clientCounts := []entity.ClientCount{}
+	for name, count := range counts {
+		clientCounts = append(clientCounts, entity.ClientCount{Name: name, Count: count})
+	}
+
+	sort.Slice(clientCounts, func(i, j int) bool {
+		if clientCounts[i].Count != clientCounts[j].Count {
+			return clientCounts[i].Count > clientCounts[j].Count
+		}
+		return clientCounts[i].Name > clientCounts[j].Name
+	})
+
+	if len(clientCounts) > top {
+		clientCounts = clientCounts[:top]
+	}
+
+	return clientCounts
+}
+
+func (r *memoryStatistic) GetAPIStatisticPaths(ctx context.Context) (apiPaths []string, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for apiPath := range r.apiCount {
+		apiPaths = append(apiPaths, apiPath)
+	}
+	sort.Strings(apiPaths)
+
+	return apiPaths, nil
+}
+
+func (r *memoryStatistic) GetAPIStatistic(ctx context.Context, apiPath string) (apiStatistic entity.APIStatistic, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	statusCount := map[string]int64{}
+	for class, n := range r.apiStatus[apiPath] {
+		statusCount[class] = n
+	}
+
+	return entity.APIStatistic{
+		Path:            apiPath,
+		Count:           r.apiCount[apiPath],
+		UniqueUserAgent: int64(len(r.userAgents[apiPath])),
+		StatusCount:     statusCount,
+		ErrorCount:      r.apiErrors[apiPath],
+		Latency:         entity.NewAPILatency(r.apiLatency[apiPath]),
+	}, nil
+}
+
+func (r *memoryStatistic) GetAPIStatisticSeries(ctx context.Context, apiPath string, granularity entity.Granularity, from time.Time, to time.Time) (series []entity.APIStatisticPoint, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	expiredBefore := bucketOf(now.Add(-granularity.Retention), granularity)
+	for bucket := bucketOf(from, granularity); !bucket.After(to); bucket = bucket.Add(granularity.Size) {
+		point := entity.APIStatisticPoint{
+			Timestamp: bucket,
+		}
+		// bucket past its retention is expired in redis
+		if apiBucket := r.apiBuckets[apiStatisticBucketKey(apiPath, granularity, bucket)]; apiBucket != nil &&
+			!bucket.Before(expiredBefore) && now.Before(apiBucket.expiredAt) {
+			point.Count = apiBucket.count
+		}
+		series = append(series, point)
+	}
+
+	return series, nil
+}
+
+func (r *memoryStatistic) IncrCacheStatistic(ctx context.Context, cacheEntity string, hit int64, miss int64) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cacheStat := r.cacheStats[cacheEntity]
+	cacheStat.Hit += hit
+	cacheStat.Miss += miss
+	r.cacheStats[cacheEntity] = cacheStat
+
+	return nil
+}
+
+func (r *memoryStatistic) GetCacheStatistic(ctx context.Context, cacheEntity string) (cacheStatistic entity.CacheStatistic, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cacheStatistic = r.cacheStats[cacheEntity]
+	cacheStatistic.Entity = cacheEntity
+
+	return cacheStatistic, nil
+}
+
+func (r *memoryStatistic) ResetAPIStatistic(ctx context.Context) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.apiCount = map[string]int64{}
+	r.apiBuckets = map[string]*memoryAPIBucket{}
+	r.apiStatus = map[string]map[string]int64{}
+	r.apiErrors = map[string]int64{}
+	r.apiLatency = map[string]map[string]int64{}
+	r.userAgents = map[string]map[string]struct{}{}
+	r.clients = map[string]int64{}
+	r.userAgentCount = map[string]int64{}
+	r.clientIPs = map[string]struct{}{}
+
+	return nil
+}
+
+func (r *memoryStatistic) Lock(ctx context.Context, name string, ttl time.Duration) (acquired bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if expiredAt, ok := r.locks[name]; ok && now.Before(expiredAt) {
+		return false, nil
+	}
+	r.locks[name] = now.Add(ttl)
+
+	return true, nil
+}
@@ -0,0 +1,423 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alvinatthariq/farmsvc-go/entity"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	// key of api statistic under key prefix + apiStatisticKeyPrefix
+	apiStatisticKeyPrefix        = "stat:api:"
+	apiStatisticPathsKey         = "paths"
+	apiStatisticCountKeyPrefix   = "count:"
+	apiStatisticUAKeyPrefix      = "ua:"
+	apiStatisticBucketKeyPrefix  = "bucket:"
+	apiStatisticStatusKeyPrefix  = "status:"
+	apiStatisticLatencyKeyPrefix = "latency:"
+	apiStatisticStatusFieldError = "error"
+
+	// key of client statistic under key prefix + clientStatisticKeyPrefix
+	clientStatisticKeyPrefix     = "stat:client:"
+	clientStatisticClientsKey    = "ids"
+	clientStatisticUserAgentsKey = "useragents"
+	clientStatisticIPsKey        = "ips"
+
+	// key of resource statistic under key prefix + resourceStatisticKeyPrefix + resource
+	resourceStatisticKeyPrefix       = "stat:resource:"
+	resourceStatisticIDKeyPrefix     = ":id:"
+	resourceStatisticTopKey          = ":top"
+	resourceStatisticFieldLastPrefix = "last_"
+
+	// clientStatisticMaxMembers is max clients & user agents kept, least requested is removed first
+	clientStatisticMaxMembers = 10000
+	cacheStatisticKeyPrefix   = "stat:cache:"
+	cacheStatisticFieldHit    = "hit"
+	cacheStatisticFieldMiss   = "miss"
+	lockKeyPrefix             = "lock:"
+
+	// redisScanCount is number of keys asked per SCAN when resetting statistic
+	redisScanCount = 1000
+)
+
+type redisStatistic struct {
+	redisClient *redis.Client
+	keyPrefix   string
+}
+
+// NewRedisStatistic create statistic repository which store every key under keyPrefix + "stat:"
+func NewRedisStatistic(redisClient *redis.Client, keyPrefix string) StatisticRepository {
+	return &redisStatistic{
+		redisClient: redisClient,
+		keyPrefix:   keyPrefix,
+	}
+}
+
+func (r *redisStatistic) client(ctx context.Context) *redis.Client {
+	return redisWithContext(ctx, r.redisClient)
+}
+
+func (r *redisStatistic) apiKey(key string) string {
+	return r.keyPrefix + apiStatisticKeyPrefix + key
+}
+
+func (r *redisStatistic) clientKey(key string) string {
+	return r.keyPrefix + clientStatisticKeyPrefix + key
+}
+
+// RecordAPIStatistics write batch in MULTI / EXEC, so failed batch is not partially counted and can be written again
+func (r *redisStatistic) RecordAPIStatistics(ctx context.Context, events []entity.APIStatisticEvent) (err error) {
+	_, err = r.client(ctx).TxPipelined(func(pipe redis.Pipeliner) error {
+		for _, event := range events {
+			r.recordAPIStatistic(pipe, event)
+		}
+
+		// keep sorted sets bounded
+		pipe.ZRemRangeByRank(r.clientKey(clientStatisticClientsKey), 0, -clientStatisticMaxMembers-1)
+		pipe.ZRemRangeByRank(r.clientKey(clientStatisticUserAgentsKey), 0, -clientStatisticMaxMembers-1)
+
+		return nil
+	})
+
+	return err
+}
+
+func (r *redisStatistic) recordAPIStatistic(pipe redis.Pipeliner, event entity.APIStatisticEvent) {
+	pipe.SAdd(r.apiKey(apiStatisticPathsKey), event.Path)
+	pipe.IncrBy(r.apiKey(apiStatisticCountKeyPrefix+event.Path), 1)
+	pipe.PFAdd(r.apiKey(apiStatisticUAKeyPrefix+event.Path), event.UserAgent)
+
+	for _, granularity := range entity.Granularities {
+		key := r.apiKey(apiStatisticBucketKey(event.Path, granularity, bucketOf(event.At, granularity)))
+		pipe.IncrBy(key, 1)
+		pipe.Expire(key, granularity.Retention)
+	}
+
+	statusKey := r.apiKey(apiStatisticStatusKeyPrefix + event.Path)
+	pipe.HIncrBy(statusKey, event.StatusClass(), 1)
+	if event.IsError() {
+		pipe.HIncrBy(statusKey, apiStatisticStatusFieldError, 1)
+	}
+	pipe.HIncrBy(r.apiKey(apiStatisticLatencyKeyPrefix+event.Path), event.LatencyBucket(), 1)
+
+	pipe.ZIncrBy(r.clientKey(clientStatisticClientsKey), 1, event.ClientID)
+	pipe.ZIncrBy(r.clientKey(clientStatisticUserAgentsKey), 1, event.UserAgent)
+	if event.ClientIP != "" {
+		pipe.PFAdd(r.clientKey(clientStatisticIPsKey), event.ClientIP)
+	}
+
+	if event.Resource != "" && event.ResourceID != "" {
+		key := r.resourceKey(event.Resource, resourceStatisticIDKeyPrefix+event.ResourceID)
+		pipe.HIncrBy(key, event.ResourceAccess, 1)
+		pipe.HSet(key, resourceStatisticFieldLastPrefix+event.ResourceAccess, event.At.Unix())
+		pipe.ZIncrBy(r.resourceKey(event.Resource, resourceStatisticTopKey), 1, event.ResourceID)
+	}
+}
+
+func (r *redisStatistic) resourceKey(resource string, key string) string {
+	return r.keyPrefix + resourceStatisticKeyPrefix + resource + key
+}
+
+func (r *redisStatistic) GetResourceStatistic(ctx context.Context, resource string, id string) (resourceStatistic entity.ResourceStatistic, err error) {
+	resourceStatistics, err := r.GetResourceStatistics(ctx, resource, []string{id})
+	if err != nil {
+		return resourceStatistic, err
+	}
+
+	return resourceStatistics[0], nil
+}
+
+func (r *redisStatistic) GetTopResourceStatistics(ctx context.Context, resource string, top int) (resourceStatistics []entity.ResourceStatistic, err error) {
+	ids, err := r.client(ctx).ZRevRange(r.resourceKey(resource, resourceStatisticTopKey), 0, int64(top-1)).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	return r.GetResourceStatistics(ctx, resource, ids)
+}
+
+// GetResourceStatistics return access count of every resource id in one round trip, ordered as ids
+func (r *redisStatistic) GetResourceStatistics(ctx context.Context, resource string, ids []string) (resourceStatistics []entity.ResourceStatistic, err error) {
+	resourceStatistics = []entity.ResourceStatistic{}
+	if len(ids) < 1 {
+		return resourceStatistics, nil
+	}
+
+	cmds := make([]*redis.StringStringMapCmd, len(ids))
+	_, err = r.client(ctx).Pipelined(func(pipe redis.Pipeliner) error {
+		for i, id := range ids {
+			cmds[i] = pipe.HGetAll(r.resourceKey(resource, resourceStatisticIDKeyPrefix+id))
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	for i, id := range ids {
+		counts := parseInt64Map(cmds[i].Val())
+		resourceStatistics = append(resourceStatistics, entity.NewResourceStatistic(
+			resource,
+			id,
+			counts[entity.ResourceAccessRead],
+			counts[entity.ResourceAccessWrite],
+			counts[resourceStatisticFieldLastPrefix+entity.ResourceAccessRead],
+			counts[resourceStatisticFieldLastPrefix+entity.ResourceAccessWrite],
+		))
+	}
+
+	return resourceStatistics, nil
+}
+
+// DeleteResourceStatistics remove access count of resource ids and remove them from leaderboard
+func (r *redisStatistic) DeleteResourceStatistics(ctx context.Context, resource string, ids []string) (err error) {
+	if len(ids) < 1 {
+		return nil
+	}
+
+	members := make([]interface{}, len(ids))
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		members[i] = id
+		keys[i] = r.resourceKey(resource, resourceStatisticIDKeyPrefix+id)
+	}
+
+	_, err = r.client(ctx).Pipelined(func(pipe redis.Pipeliner) error {
+		pipe.ZRem(r.resourceKey(resource, resourceStatisticTopKey), members...)
+		pipe.Del(keys...)
+		return nil
+	})
+
+	return err
+}
+
+func (r *redisStatistic) GetClientStatistic(ctx context.Context, top int) (clientStatistic entity.ClientStatistic, err error) {
+	clientStatistic.TopClients, err = r.topMembers(ctx, r.clientKey(clientStatisticClientsKey), top)
+	if err != nil {
+		return clientStatistic, err
+	}
+
+	clientStatistic.TopUserAgents, err = r.topMembers(ctx, r.clientKey(clientStatisticUserAgentsKey), top)
+	if err != nil {
+		return clientStatistic, err
+	}
+
+	clientStatistic.UniqueIP, err = r.client(ctx).PFCount(r.clientKey(clientStatisticIPsKey)).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return clientStatistic, err
+	}
+
+	return clientStatistic, nil
+}
+
+func (r *redisStatistic) topMembers(ctx context.Context, key string, top int) (clientCounts []entity.ClientCount, err error) {
+	members, err := r.client(ctx).ZRevRangeWithScores(key, 0, int64(top-1)).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	clientCounts = []entity.ClientCount{}
+	for _, member := range members {
+		name, _ := member.Member.(string)
+		clientCounts = append(clientCounts, entity.ClientCount{
+			Name:  name,
+			Count: int64(member.Score),
+		})
+	}
+
+	return clientCounts, nil
+}
+
+func (r *redisStatistic) GetAPIStatisticPaths(ctx context.Context) (apiPaths []string, err error) {
+	apiPaths, err = r.client(ctx).SMembers(r.apiKey(apiStatisticPathsKey)).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	sort.Strings(apiPaths)
+
+	return apiPaths, nil
+}
+
+func (r *redisStatistic) GetAPIStatistic(ctx context.Context, apiPath string) (apiStatistic entity.APIStatistic, err error) {
+	apiStatistic.Path = apiPath
+
+	res := r.client(ctx).Get(r.apiKey(apiStatisticCountKeyPrefix + apiPath))
+	if res.Err() != nil {
+		if !errors.Is(res.Err(), redis.Nil) {
+			return apiStatistic, res.Err()
+		}
+	}
+
+	count, err := res.Int64()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			return apiStatistic, err
+		}
+	}
+
+	resUa := r.client(ctx).PFCount(r.apiKey(apiStatisticUAKeyPrefix + apiPath))
+	if resUa.Err() != nil {
+		if !errors.Is(resUa.Err(), redis.Nil) {
+			return apiStatistic, resUa.Err()
+		}
+	}
+
+	statusCount, err := r.client(ctx).HGetAll(r.apiKey(apiStatisticStatusKeyPrefix + apiPath)).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return apiStatistic, err
+	}
+
+	latencyCount, err := r.client(ctx).HGetAll(r.apiKey(apiStatisticLatencyKeyPrefix + apiPath)).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return apiStatistic, err
+	}
+
+	apiStatistic.Count = count
+	apiStatistic.UniqueUserAgent = resUa.Val()
+	apiStatistic.StatusCount = map[string]int64{}
+	for field, value := range statusCount {
+		n, _ := strconv.ParseInt(value, 10, 64)
+		if field == apiStatisticStatusFieldError {
+			apiStatistic.ErrorCount = n
+		} else {
+			apiStatistic.StatusCount[field] = n
+		}
+	}
+	apiStatistic.Latency = entity.NewAPILatency(parseInt64Map(latencyCount))
+
+	return apiStatistic, nil
+}
+
+func (r *redisStatistic) IncrCacheStatistic(ctx context.Context, cacheEntity string, hit int64, miss int64) (err error) {
+	key := r.keyPrefix + cacheStatisticKeyPrefix + cacheEntity
+
+	_, err = r.client(ctx).Pipelined(func(pipe redis.Pipeliner) error {
+		if hit != 0 {
+			pipe.HIncrBy(key, cacheStatisticFieldHit, hit)
+		}
+		if miss != 0 {
+			pipe.HIncrBy(key, cacheStatisticFieldMiss, miss)
+		}
+		return nil
+	})
+
+	return err
+}
+
+func (r *redisStatistic) GetCacheStatistic(ctx context.Context, cacheEntity string) (cacheStatistic entity.CacheStatistic, err error) {
+	cacheStatistic.Entity = cacheEntity
+
+	res, err := r.client(ctx).HGetAll(r.keyPrefix + cacheStatisticKeyPrefix + cacheEntity).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return cacheStatistic, err
+	}
+
+	cacheStatistic.Hit, _ = strconv.ParseInt(res[cacheStatisticFieldHit], 10, 64)
+	cacheStatistic.Miss, _ = strconv.ParseInt(res[cacheStatisticFieldMiss], 10, 64)
+
+	return cacheStatistic, nil
+}
+
+func (r *redisStatistic) GetAPIStatisticSeries(ctx context.Context, apiPath string, granularity entity.Granularity, from time.Time, to time.Time) (series []entity.APIStatisticPoint, err error) {
+	var keys []string
+	for bucket := bucketOf(from, granularity); !bucket.After(to); bucket = bucket.Add(granularity.Size) {
+		keys = append(keys, r.apiKey(apiStatisticBucketKey(apiPath, granularity, bucket)))
+		series = append(series, entity.APIStatisticPoint{
+			Timestamp: bucket,
+		})
+	}
+	if len(keys) < 1 {
+		return series, nil
+	}
+
+	values, err := r.client(ctx).MGet(keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, value := range values {
+		if str, ok := value.(string); ok {
+			series[i].Count, _ = strconv.ParseInt(str, 10, 64)
+		}
+	}
+
+	return series, nil
+}
+
+// apiStatisticBucketKey return key of granularity bucket of apiPath, without key prefix
+func apiStatisticBucketKey(apiPath string, granularity entity.Granularity, bucket time.Time) string {
+	return apiStatisticBucketKeyPrefix + granularity.Name + ":" + apiPath + ":" + strconv.FormatInt(bucket.Unix(), 10)
+}
+
+func (r *redisStatistic) ResetAPIStatistic(ctx context.Context) (err error) {
+	for _, prefix := range []string{r.apiKey(""), r.clientKey("")} {
+		err = r.deleteByPrefix(ctx, prefix)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteByPrefix remove every key starting with prefix, key written while scanning may be kept
+func (r *redisStatistic) deleteByPrefix(ctx context.Context, prefix string) (err error) {
+	var cursor uint64
+	for {
+		var keys []string
+		keys, cursor, err = r.client(ctx).Scan(cursor, escapeGlob(prefix)+"*", redisScanCount).Result()
+		if err != nil {
+			return err
+		}
+
+		if len(keys) > 0 {
+			err = r.client(ctx).Del(keys...).Err()
+			if err != nil {
+				return err
+			}
+		}
+
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+func (r *redisStatistic) Lock(ctx context.Context, name string, ttl time.Duration) (acquired bool, err error) {
+	return r.client(ctx).SetNX(r.keyPrefix+lockKeyPrefix+name, time.Now().Unix(), ttl).Result()
+}
+
+// escapeGlob escape glob special character of redis MATCH pattern
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		switch c {
+		case '*', '?', '[', ']', '\\':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(c)
+	}
+
+	return b.String()
+}
+
+// bucketOf return start time of granularity bucket containing t
+func bucketOf(t time.Time, granularity entity.Granularity) time.Time {
+	return t.UTC().Truncate(granularity.Size)
+}
+
+func parseInt64Map(m map[string]string) map[string]int64 {
+	res := map[string]int64{}
+	for k, v := range m {
+		res[k], _ = strconv.ParseInt(v, 10, 64)
+	}
+
+	return res
+}
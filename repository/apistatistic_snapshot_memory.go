@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/alvinatthariq/farmsvc-go/entity"
+)
+
+type memoryStatisticSnapshot struct {
+	mu        sync.RWMutex
+	snapshots []entity.APIStatisticSnapshot
+	lastID    int64
+}
+
+func NewMemoryStatisticSnapshot() StatisticSnapshotRepository {
+	return &memoryStatisticSnapshot{}
+}
+
+func (r *memoryStatisticSnapshot) Create(ctx context.Context, snapshots []entity.APIStatisticSnapshot) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// assign id back to snapshots the same way as gorm
+	for i := range snapshots {
+		r.lastID++
+		snapshots[i].ID = r.lastID
+		r.snapshots = append(r.snapshots, snapshots[i])
+	}
+
+	return nil
+}
+
+func (r *memoryStatisticSnapshot) Find(ctx context.Context, param entity.APIStatisticSnapshotParam) (snapshots []entity.APIStatisticSnapshot, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	snapshots = []entity.APIStatisticSnapshot{}
+	for _, snapshot := range r.snapshots {
+		if param.Path != "" && snapshot.Path != param.Path {
+			continue
+		}
+		if !param.From.IsZero() && snapshot.SnapshotAt.Before(param.From) {
+			continue
+		}
+		if !param.To.IsZero() && snapshot.SnapshotAt.After(param.To) {
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		if !snapshots[i].SnapshotAt.Equal(snapshots[j].SnapshotAt) {
+			return snapshots[i].SnapshotAt.Before(snapshots[j].SnapshotAt)
+		}
+		return snapshots[i].Path < snapshots[j].Path
+	})
+
+	start, end := pageBounds(len(snapshots), param.Page, param.Limit)
+
+	return snapshots[start:end], nil
+}
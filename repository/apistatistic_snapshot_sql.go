@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"github.com/alvinatthariq/farmsvc-go/entity"
+
+	"gorm.io/gorm"
+)
+
+type sqlStatisticSnapshot struct {
+	gorm *gorm.DB
+}
+
+func NewSQLStatisticSnapshot(gorm *gorm.DB) StatisticSnapshotRepository {
+	return &sqlStatisticSnapshot{
+		gorm: gorm,
+	}
+}
+
+func (r *sqlStatisticSnapshot) Create(ctx context.Context, snapshots []entity.APIStatisticSnapshot) (err error) {
+	if len(snapshots) < 1 {
+		return nil
+	}
+
+	return r.gorm.WithContext(ctx).Create(&snapshots).Error
+}
+
+func (r *sqlStatisticSnapshot) Find(ctx context.Context, param entity.APIStatisticSnapshotParam) (snapshots []entity.APIStatisticSnapshot, err error) {
+	query := r.gorm.WithContext(ctx).Model(&entity.APIStatisticSnapshot{})
+	if param.Path != "" {
+		query = query.Where("path = ?", param.Path)
+	}
+	if !param.From.IsZero() {
+		query = query.Where("snapshot_at >= ?", param.From)
+	}
+	if !param.To.IsZero() {
+		query = query.Where("snapshot_at <= ?", param.To)
+	}
+	if param.Limit > 0 {
+		query = query.Offset((param.Page - 1) * param.Limit).Limit(param.Limit)
+	}
+
+	err = query.
+		Order("snapshot_at, path").
+		Find(&snapshots).
+		Error
+
+	return snapshots, err
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryCacheItem struct {
+	value     []byte
+	expiredAt time.Time
+}
+
+type memoryCache struct {
+	mu    sync.RWMutex
+	items map[string]memoryCacheItem
+}
+
+func NewMemoryCache() CacheRepository {
+	return &memoryCache{
+		items: map[string]memoryCacheItem{},
+	}
+}
+
+func (r *memoryCache) Get(ctx context.Context, key string) (value []byte, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	item, ok := r.items[key]
+	if !ok {
+		return nil, ErrCacheMiss
+	} else if time.Now().After(item.expiredAt) {
+		delete(r.items, key)
+		return nil, ErrCacheMiss
+	}
+
+	return item.value, nil
+}
+
+func (r *memoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.items[key] = memoryCacheItem{
+		value:     value,
+		expiredAt: time.Now().Add(ttl),
+	}
+
+	return nil
+}
+
+func (r *memoryCache) Del(ctx context.Context, keys ...string) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, key := range keys {
+		delete(r.items, key)
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+type redisCache struct {
+	redisClient *redis.Client
+	keyPrefix   string
+}
+
+// NewRedisCache create cache repository which store every key under keyPrefix + "cache:"
+func NewRedisCache(redisClient *redis.Client, keyPrefix string) CacheRepository {
+	return &redisCache{
+		redisClient: redisClient,
+		keyPrefix:   keyPrefix + "cache:",
+	}
+}
+
+func (r *redisCache) client(ctx context.Context) *redis.Client {
+	return redisWithContext(ctx, r.redisClient)
+}
+
+func (r *redisCache) Get(ctx context.Context, key string) (value []byte, err error) {
+	value, err = r.client(ctx).Get(r.keyPrefix + key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrCacheMiss
+	}
+
+	return value, err
+}
+
+func (r *redisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) (err error) {
+	return r.client(ctx).Set(r.keyPrefix+key, value, ttl).Err()
+}
+
+// Del remove every key with one command
+func (r *redisCache) Del(ctx context.Context, keys ...string) (err error) {
+	if len(keys) < 1 {
+		return nil
+	}
+
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = r.keyPrefix + key
+	}
+
+	return r.client(ctx).Del(prefixed...).Err()
+}
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/alvinatthariq/farmsvc-go/entity"
+)
+
+type memoryFarm struct {
+	mu    sync.RWMutex
+	farms map[string]entity.Farm
+}
+
+func NewMemoryFarm() FarmRepository {
+	return &memoryFarm{
+		farms: map[string]entity.Farm{},
+	}
+}
+
+func (r *memoryFarm) Create(ctx context.Context, farm entity.Farm) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.farms[farm.ID]; ok {
+		return entity.ErrorFarmAlreadyExist
+	}
+	r.farms[farm.ID] = farm
+
+	return nil
+}
+
+func (r *memoryFarm) GetByID(ctx context.Context, farmID string) (farm *entity.Farm, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	f, ok := r.farms[farmID]
+	if !ok {
+		return nil, nil
+	}
+
+	return &f, nil
+}
+
+func (r *memoryFarm) Find(ctx context.Context, param entity.FarmParam) (farms []entity.Farm, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, farm := range r.farms {
+		if farm.IsDeleted.Valid {
+			continue
+		}
+		if param.ID != "" && farm.ID != param.ID {
+			continue
+		}
+		if len(param.IDs) > 0 && !slices.Contains(param.IDs, farm.ID) {
+			continue
+		}
+		if param.AfterID != "" && farm.ID <= param.AfterID {
+			continue
+		}
+		if param.Name != "" && farm.Name != param.Name {
+			continue
+		}
+		farms = append(farms, farm)
+	}
+
+	// sort by primary key, same as sql order
+	sort.Slice(farms, func(i, j int) bool {
+		return farms[i].ID < farms[j].ID
+	})
+
+	start, end := pageBounds(len(farms), param.Page, param.Limit)
+
+	return farms[start:end], nil
+}
+
+func (r *memoryFarm) Count(ctx context.Context) (count int64, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, farm := range r.farms {
+		if !farm.IsDeleted.Valid {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (r *memoryFarm) Save(ctx context.Context, farm entity.Farm) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.farms[farm.ID] = farm
+
+	return nil
+}
+
+func (r *memoryFarm) Delete(ctx context.Context, farmID string) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.farms, farmID)
+
+	return nil
+}
+
+// pageBounds return start & end index of page the same way as sql offset & limit,
+// page below 1 is treated as first page and limit below 1 as no limit
+func pageBounds(total int, page int, limit int) (start int, end int) {
+	if limit < 1 {
+		return 0, total
+	}
+
+	start = (page - 1) * limit
+	if start < 0 {
+		start = 0
+	}
+	if start > total {
+		start = total
+	}
+
+	end = start + limit
+	if end > total {
+		end = total
+	}
+
+	return start, end
+}
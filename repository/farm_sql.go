@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/alvinatthariq/farmsvc-go/entity"
+
+	"gorm.io/gorm"
+)
+
+type sqlFarm struct {
+	gorm *gorm.DB
+}
+
+func NewSQLFarm(gorm *gorm.DB) FarmRepository {
+	return &sqlFarm{
+		gorm: gorm,
+	}
+}
+
+func (r *sqlFarm) Create(ctx context.Context, farm entity.Farm) (err error) {
+	err = translateSQLError(r.gorm.WithContext(ctx).Create(&farm).Error)
+	switch {
+	case errors.Is(err, errDuplicateKey):
+		return entity.ErrorFarmAlreadyExist
+	}
+
+	return err
+}
+
+func (r *sqlFarm) GetByID(ctx context.Context, farmID string) (farm *entity.Farm, err error) {
+	err = r.gorm.WithContext(ctx).First(&farm, "id = ?", farmID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	return farm, err
+}
+
+func (r *sqlFarm) Find(ctx context.Context, param entity.FarmParam) (farms []entity.Farm, err error) {
+	query := r.gorm.WithContext(ctx).
+		Where("is_deleted is null").
+		Where(&param)
+	if len(param.IDs) > 0 {
+		query = query.Where("id IN ?", param.IDs)
+	}
+	if param.AfterID != "" {
+		query = query.Where("id > ?", param.AfterID)
+	}
+
+	err = query.
+		Order("id").
+		Offset((param.Page - 1) * param.Limit).
+		Limit(param.Limit).
+		Find(&farms).
+		Error
+
+	return farms, err
+}
+
+func (r *sqlFarm) Count(ctx context.Context) (count int64, err error) {
+	err = r.gorm.WithContext(ctx).
+		Model(&entity.Farm{}).
+		Where("is_deleted is null").
+		Count(&count).
+		Error
+
+	return count, err
+}
+
+func (r *sqlFarm) Save(ctx context.Context, farm entity.Farm) (err error) {
+	return r.gorm.WithContext(ctx).Save(&farm).Error
+}
+
+func (r *sqlFarm) Delete(ctx context.Context, farmID string) (err error) {
+	return r.gorm.WithContext(ctx).Where("id = ?", farmID).Delete(&entity.Farm{}).Error
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/alvinatthariq/farmsvc-go/entity"
+)
+
+type memoryPond struct {
+	mu    sync.RWMutex
+	ponds map[string]entity.Pond
+	farms FarmRepository
+}
+
+// NewMemoryPond create pond repository which check farm of pond exist in farms on write, as foreign key of sql does
+func NewMemoryPond(farms FarmRepository) PondRepository {
+	return &memoryPond{
+		ponds: map[string]entity.Pond{},
+		farms: farms,
+	}
+}
+
+// checkFarm return entity.ErrorFarmNotFound when farm of pond is not stored, soft deleted farm is still stored
+func (r *memoryPond) checkFarm(ctx context.Context, pond entity.Pond) error {
+	farm, err := r.farms.GetByID(ctx, pond.FarmID)
+	if err != nil {
+		return err
+	} else if farm == nil {
+		return entity.ErrorFarmNotFound
+	}
+
+	return nil
+}
+
+func (r *memoryPond) Create(ctx context.Context, pond entity.Pond) (err error) {
+	if err := r.checkFarm(ctx, pond); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.ponds[pond.ID]; ok {
+		return entity.ErrorPondAlreadyExist
+	}
+	r.ponds[pond.ID] = pond
+
+	return nil
+}
+
+func (r *memoryPond) GetByID(ctx context.Context, pondID string) (pond *entity.Pond, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, ok := r.ponds[pondID]
+	if !ok {
+		return nil, nil
+	}
+
+	return &p, nil
+}
+
+func (r *memoryPond) Find(ctx context.Context, param entity.PondParam) (ponds []entity.Pond, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, pond := range r.ponds {
+		if pond.IsDeleted.Valid {
+			continue
+		}
+		if param.ID != "" && pond.ID != param.ID {
+			continue
+		}
+		if len(param.IDs) > 0 && !slices.Contains(param.IDs, pond.ID) {
+			continue
+		}
+		if param.AfterID != "" && pond.ID <= param.AfterID {
+			continue
+		}
+		if param.FarmID != "" && pond.FarmID != param.FarmID {
+			continue
+		}
+		if param.Name != "" && pond.Name != param.Name {
+			continue
+		}
+		ponds = append(ponds, pond)
+	}
+
+	// sort by primary key, same as sql order
+	sort.Slice(ponds, func(i, j int) bool {
+		return ponds[i].ID < ponds[j].ID
+	})
+
+	start, end := pageBounds(len(ponds), param.Page, param.Limit)
+
+	return ponds[start:end], nil
+}
+
+func (r *memoryPond) Count(ctx context.Context) (count int64, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, pond := range r.ponds {
+		if !pond.IsDeleted.Valid {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (r *memoryPond) Save(ctx context.Context, pond entity.Pond) (err error) {
+	if err := r.checkFarm(ctx, pond); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.ponds[pond.ID] = pond
+
+	return nil
+}
+
+func (r *memoryPond) Delete(ctx context.Context, pondID string) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.ponds, pondID)
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/alvinatthariq/farmsvc-go/entity"
+
+	"gorm.io/gorm"
+)
+
+type sqlPond struct {
+	gorm *gorm.DB
+}
+
+func NewSQLPond(gorm *gorm.DB) PondRepository {
+	return &sqlPond{
+		gorm: gorm,
+	}
+}
+
+func (r *sqlPond) Create(ctx context.Context, pond entity.Pond) (err error) {
+	err = translateSQLError(r.gorm.WithContext(ctx).Create(&pond).Error)
+	switch {
+	case errors.Is(err, errDuplicateKey):
+		return entity.ErrorPondAlreadyExist
+	case errors.Is(err, errForeignKeyViolate):
+		return entity.ErrorFarmNotFound
+	}
+
+	return err
+}
+
+func (r *sqlPond) GetByID(ctx context.Context, pondID string) (pond *entity.Pond, err error) {
+	err = r.gorm.WithContext(ctx).First(&pond, "id = ?", pondID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	return pond, err
+}
+
+func (r *sqlPond) Find(ctx context.Context, param entity.PondParam) (ponds []entity.Pond, err error) {
+	query := r.gorm.WithContext(ctx).
+		Where("is_deleted is null").
+		Where(&param)
+	if len(param.IDs) > 0 {
+		query = query.Where("id IN ?", param.IDs)
+	}
+	if param.AfterID != "" {
+		query = query.Where("id > ?", param.AfterID)
+	}
+
+	err = query.
+		Order("id").
+		Offset((param.Page - 1) * param.Limit).
+		Limit(param.Limit).
+		Find(&ponds).
+		Error
+
+	return ponds, err
+}
+
+func (r *sqlPond) Count(ctx context.Context) (count int64, err error) {
+	err = r.gorm.WithContext(ctx).
+		Model(&entity.Pond{}).
+		Where("is_deleted is null").
+		Count(&count).
+		Error
+
+	return count, err
+}
+
+func (r *sqlPond) Save(ctx context.Context, pond entity.Pond) (err error) {
+	err = translateSQLError(r.gorm.WithContext(ctx).Save(&pond).Error)
+	if errors.Is(err, errForeignKeyViolate) {
+		return entity.ErrorFarmNotFound
+	}
+
+	return err
+}
+
+func (r *sqlPond) Delete(ctx context.Context, pondID string) (err error) {
+	return r.gorm.WithContext(ctx).Where("id = ?", pondID).Delete(&entity.Pond{}).Error
+}
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/alvinatthariq/farmsvc-go/telemetry"
+
+	"github.com/go-redis/redis"
+)
+
+// redisWithContext return clone of redisClient bound to ctx, every command of the clone is traced
+// as child span of ctx. go-redis v6 does not cancel a command by ctx, command is bounded by
+// read & write timeout of redisClient instead
+func redisWithContext(ctx context.Context, redisClient *redis.Client) *redis.Client {
+	client := redisClient.WithContext(ctx)
+	telemetry.WrapRedis(client)
+
+	return client
+}
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/alvinatthariq/farmsvc-go/entity"
+
+	"gorm.io/gorm"
+)
+
+const DefaultReplicaRecheckInterval = 10 * time.Second
+
+type replica struct {
+	gorm *gorm.DB
+	// downUntil is unix nano time until replica is skipped, zero if available
+	downUntil atomic.Int64
+}
+
+// ReplicaSet spread list & report queries across read replicas in turn. Replica failing a query is skipped
+// until recheck interval passed and the query is run again on primary, so primary serve every read while
+// no replica is available
+type ReplicaSet struct {
+	primary         *gorm.DB
+	replicas        []*replica
+	recheckInterval time.Duration
+	next            atomic.Uint64
+}
+
+// NewReplicaSet create replica set of primary, zero or negative recheckInterval use DefaultReplicaRecheckInterval
+func NewReplicaSet(primary *gorm.DB, replicas []*gorm.DB, recheckInterval time.Duration) *ReplicaSet {
+	if recheckInterval <= 0 {
+		recheckInterval = DefaultReplicaRecheckInterval
+	}
+
+	s := &ReplicaSet{
+		primary:         primary,
+		recheckInterval: recheckInterval,
+	}
+	for _, db := range replicas {
+		s.replicas = append(s.replicas, &replica{gorm: db})
+	}
+
+	return s
+}
+
+// pick return next available replica, nil if none
+func (s *ReplicaSet) pick() *replica {
+	now := time.Now().UnixNano()
+	for range s.replicas {
+		r := s.replicas[s.next.Add(1)%uint64(len(s.replicas))]
+		if r.downUntil.Load() <= now {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// read run fn on next available replica, then on primary when replica failed or none is available.
+// Replica is only marked down on connection or transient error, other error such as invalid query
+// would fail on primary as well and is returned as is, so is error caused by ctx since the caller gave up
+func (s *ReplicaSet) read(ctx context.Context, fn func(gorm *gorm.DB) error) error {
+	if r := s.pick(); r != nil {
+		err := fn(r.gorm)
+		if err == nil || ctx.Err() != nil || !(isConnectionError(err) || IsTransientError(err)) {
+			return err
+		}
+		r.downUntil.Store(time.Now().Add(s.recheckInterval).UnixNano())
+	}
+
+	return fn(s.primary)
+}
+
+// replicaFarm serve Find & Count from replica, other methods go to primary
+type replicaFarm struct {
+	FarmRepository
+	replicas *ReplicaSet
+}
+
+// NewReplicaFarm create farm repository which read list & count from replicas, every write and
+// lookup by id go to primary so a farm just written is always found
+func NewReplicaFarm(replicas *ReplicaSet) FarmRepository {
+	return &replicaFarm{
+		FarmRepository: NewSQLFarm(replicas.primary),
+		replicas:       replicas,
+	}
+}
+
+func (r *replicaFarm) Find(ctx context.Context, param entity.FarmParam) (farms []entity.Farm, err error) {
+	err = r.replicas.read(ctx, func(gorm *gorm.DB) (err error) {
+		farms, err = NewSQLFarm(gorm).Find(ctx, param)
+		return err
+	})
+
+	return farms, err
+}
+
+func (r *replicaFarm) Count(ctx context.Context) (count int64, err error) {
+	err = r.replicas.read(ctx, func(gorm *gorm.DB) (err error) {
+		count, err = NewSQLFarm(gorm).Count(ctx)
+		return err
+	})
+
+	return count, err
+}
+
+// replicaPond serve Find & Count from replica, other methods go to primary
+type replicaPond struct {
+	PondRepository
+	replicas *ReplicaSet
+}
+
+// NewReplicaPond create pond repository which read list & count from replicas, every write and
+// lookup by id go to primary so a pond just written is always found
+func NewReplicaPond(replicas *ReplicaSet) PondRepository {
+	return &replicaPond{
+		PondRepository: NewSQLPond(replicas.primary),
+		replicas:       replicas,
+	}
+}
+
+func (r *replicaPond) Find(ctx context.Context, param entity.PondParam) (ponds []entity.Pond, err error) {
+	err = r.replicas.read(ctx, func(gorm *gorm.DB) (err error) {
+		ponds, err = NewSQLPond(gorm).Find(ctx, param)
+		return err
+	})
+
+	return ponds, err
+}
+
+func (r *replicaPond) Count(ctx context.Context) (count int64, err error) {
+	err = r.replicas.read(ctx, func(gorm *gorm.DB) (err error) {
+		count, err = NewSQLPond(gorm).Count(ctx)
+		return err
+	})
+
+	return count, err
+}
+
+// replicaStatisticSnapshot serve Find from replica, Create go to primary
+type replicaStatisticSnapshot struct {
+	StatisticSnapshotRepository
+	replicas *ReplicaSet
+}
+
+// NewReplicaStatisticSnapshot create statistic snapshot repository which read from replicas
+func NewReplicaStatisticSnapshot(replicas *ReplicaSet) StatisticSnapshotRepository {
+	return &replicaStatisticSnapshot{
+		StatisticSnapshotRepository: NewSQLStatisticSnapshot(replicas.primary),
+		replicas:                    replicas,
+	}
+}
+
+func (r *replicaStatisticSnapshot) Find(ctx context.Context, param entity.APIStatisticSnapshotParam) (snapshots []entity.APIStatisticSnapshot, err error) {
+	err = r.replicas.read(ctx, func(gorm *gorm.DB) (err error) {
+		snapshots, err = NewSQLStatisticSnapshot(gorm).Find(ctx, param)
+		return err
+	})
+
+	return snapshots, err
+}
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alvinatthariq/farmsvc-go/entity"
+
+	"github.com/go-redis/redis"
+	"gorm.io/gorm"
+)
+
+// ErrCacheMiss is returned by CacheRepository.Get when key is not cached
+var ErrCacheMiss = errors.New("cache miss")
+
+type FarmRepository interface {
+	// Create return entity.ErrorFarmAlreadyExist if farm id already exist
+	Create(ctx context.Context, farm entity.Farm) (err error)
+	// GetByID return nil farm without error if not found, soft deleted farm included
+	GetByID(ctx context.Context, farmID string) (farm *entity.Farm, err error)
+	// Find return not deleted farms matching param, paginated by param.Page & param.Limit
+	Find(ctx context.Context, param entity.FarmParam) (farms []entity.Farm, err error)
+	// Count return number of not deleted farms
+	Count(ctx context.Context) (count int64, err error)
+	Save(ctx context.Context, farm entity.Farm) (err error)
+	// Delete permanently remove farm
+	Delete(ctx context.Context, farmID string) (err error)
+}
+
+type PondRepository interface {
+	// Create return entity.ErrorPondAlreadyExist if pond id already exist
+	Create(ctx context.Context, pond entity.Pond) (err error)
+	// GetByID return nil pond without error if not found, soft deleted pond included
+	GetByID(ctx context.Context, pondID string) (pond *entity.Pond, err error)
+	// Find return not deleted ponds matching param, paginated by param.Page & param.Limit
+	Find(ctx context.Context, param entity.PondParam) (ponds []entity.Pond, err error)
+	// Count return number of not deleted ponds
+	Count(ctx context.Context) (count int64, err error)
+	Save(ctx context.Context, pond entity.Pond) (err error)
+	// Delete permanently remove pond
+	Delete(ctx context.Context, pondID string) (err error)
+}
+
+type StatisticRepository interface {
+	// RecordAPIStatistics increment lifetime, granularity bucket, status class & latency counter of every event path
+	RecordAPIStatistics(ctx context.Context, events []entity.APIStatisticEvent) (err error)
+	// GetAPIStatisticPaths return every path ever recorded, sorted
+	GetAPIStatisticPaths(ctx context.Context) (apiPaths []string, err error)
+	GetAPIStatistic(ctx context.Context, apiPath string) (apiStatistic entity.APIStatistic, err error)
+	// GetAPIStatisticSeries return count of every granularity bucket of apiPath from until to, inclusive
+	GetAPIStatisticSeries(ctx context.Context, apiPath string, granularity entity.Granularity, from time.Time, to time.Time) (series []entity.APIStatisticPoint, err error)
+	// GetClientStatistic return top clients & user agents by request count
+	GetClientStatistic(ctx context.Context, top int) (clientStatistic entity.ClientStatistic, err error)
+	// GetResourceStatistic return access count of resource id, zero if never accessed
+	GetResourceStatistic(ctx context.Context, resource string, id string) (resourceStatistic entity.ResourceStatistic, err error)
+	// GetTopResourceStatistics return top resources by access count
+	GetTopResourceStatistics(ctx context.Context, resource string, top int) (resourceStatistics []entity.ResourceStatistic, err error)
+	// GetResourceStatistics return access count of every resource id, ordered as ids
+	GetResourceStatistics(ctx context.Context, resource string, ids []string) (resourceStatistics []entity.ResourceStatistic, err error)
+	// DeleteResourceStatistics remove access count of resource ids, they are no longer listed by GetTopResourceStatistics
+	DeleteResourceStatistics(ctx context.Context, resource string, ids []string) (err error)
+	// IncrCacheStatistic add hit & miss count of cacheEntity
+	IncrCacheStatistic(ctx context.Context, cacheEntity string, hit int64, miss int64) (err error)
+	GetCacheStatistic(ctx context.Context, cacheEntity string) (cacheStatistic entity.CacheStatistic, err error)
+	// ResetAPIStatistic remove every api & client statistic, resource & cache statistic is kept
+	ResetAPIStatistic(ctx context.Context) (err error)
+	// Lock acquire lock name shared between replicas until ttl passed, acquired is false if already locked
+	Lock(ctx context.Context, name string, ttl time.Duration) (acquired bool, err error)
+}
+
+type StatisticSnapshotRepository interface {
+	Create(ctx context.Context, snapshots []entity.APIStatisticSnapshot) (err error)
+	// Find return snapshots matching param ordered by snapshot time then path,
+	// paginated by param.Page & param.Limit, limit below 1 return every snapshot
+	Find(ctx context.Context, param entity.APIStatisticSnapshotParam) (snapshots []entity.APIStatisticSnapshot, err error)
+}
+
+type CacheRepository interface {
+	// Get return ErrCacheMiss if key not exist or expired
+	Get(ctx context.Context, key string) (value []byte, err error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) (err error)
+	Del(ctx context.Context, keys ...string) (err error)
+}
+
+type Repository struct {
+	Farm              FarmRepository
+	Pond              PondRepository
+	Statistic         StatisticRepository
+	StatisticSnapshot StatisticSnapshotRepository
+	Cache             CacheRepository
+
+	// FarmReplica, PondReplica & StatisticSnapshotReplica serve list & report queries, which may lag behind
+	// the primary one. Nil when there is no read replica, primary repository is used instead
+	FarmReplica              FarmRepository
+	PondReplica              PondRepository
+	StatisticSnapshotReplica StatisticSnapshotRepository
+}
+
+// NewSQL create repository backed by gorm for farm, pond & statistic snapshot, and redis for statistic & cache,
+// every redis key is prefixed by redisKeyPrefix so multiple service can share one redis
+func NewSQL(gorm *gorm.DB, redisClient *redis.Client, redisKeyPrefix string) Repository {
+	return Repository{
+		Farm:              NewSQLFarm(gorm),
+		Pond:              NewSQLPond(gorm),
+		Statistic:         NewRedisStatistic(redisClient, redisKeyPrefix),
+		StatisticSnapshot: NewSQLStatisticSnapshot(gorm),
+		Cache:             NewRedisCache(redisClient, redisKeyPrefix),
+	}
+}
+
+// NewSQLWithReplica create repository like NewSQL, list & report queries are served by replicas
+func NewSQLWithReplica(replicas *ReplicaSet, redisClient *redis.Client, redisKeyPrefix string) Repository {
+	repo := NewSQL(replicas.primary, redisClient, redisKeyPrefix)
+	repo.FarmReplica = NewReplicaFarm(replicas)
+	repo.PondReplica = NewReplicaPond(replicas)
+	repo.StatisticSnapshotReplica = NewReplicaStatisticSnapshot(replicas)
+
+	return repo
+}
+
+// NewMemory create repository which keep everything in memory, data is lost on exit
+func NewMemory() Repository {
+	farm := NewMemoryFarm()
+	return Repository{
+		Farm:              farm,
+		Pond:              NewMemoryPond(farm),
+		Statistic:         NewMemoryStatistic(),
+		StatisticSnapshot: NewMemoryStatisticSnapshot(),
+		Cache:             NewMemoryCache(),
+	}
+}
@@ -0,0 +1,389 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/alvinatthariq/farmsvc-go/entity"
+	"github.com/alvinatthariq/farmsvc-go/migration"
+	"github.com/alvinatthariq/farmsvc-go/repository"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	. "github.com/smartystreets/goconvey/convey"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+	"gorm.io/plugin/opentelemetry/tracing"
+)
+
+// TestFarmFind run the same cases against memory & sqlite repository, so both order & filter alike
+func TestFarmFind(t *testing.T) {
+	Convey("TestFarmFind", t, FailureHalts, func() {
+		ctx := context.Background()
+		db := openSQLite()
+		So(db.AutoMigrate(&entity.Farm{}), ShouldBeNil)
+		farmRepos := []repository.FarmRepository{repository.NewMemoryFarm(), repository.NewSQLFarm(db)}
+		for _, farmRepo := range farmRepos {
+			// inserted out of id order
+			for _, farm := range []entity.Farm{
+				{ID: "farm-3", Name: "alpha"},
+				{ID: "farm-1", Name: "alpha"},
+				{ID: "farm-2", Name: "beta"},
+				{ID: "farm-4", Name: "alpha", IsDeleted: sql.NullBool{Bool: true, Valid: true}},
+			} {
+				So(farmRepo.Create(ctx, farm), ShouldBeNil)
+			}
+		}
+
+		testCases := []struct {
+			testID   int
+			testType string
+			testDesc string
+			param    entity.FarmParam
+			expected []string
+		}{
+			{
+				testID:   1,
+				testDesc: "Success find all, soft deleted excluded",
+				testType: "P",
+				param:    entity.FarmParam{Limit: 10},
+				expected: []string{"farm-1", "farm-2", "farm-3"},
+			},
+			{
+				testID:   2,
+				testDesc: "Success find by name",
+				testType: "P",
+				param:    entity.FarmParam{Name: "alpha", Limit: 10},
+				expected: []string{"farm-1", "farm-3"},
+			},
+			{
+				testID:   3,
+				testDesc: "Success find second page",
+				testType: "P",
+				param:    entity.FarmParam{Limit: 2, Page: 2},
+				expected: []string{"farm-3"},
+			},
+			{
+				testID:   4,
+				testDesc: "Success find page out of range",
+				testType: "P",
+				param:    entity.FarmParam{Limit: 2, Page: 3},
+				expected: []string{},
+			},
+			{
+				testID:   5,
+				testDesc: "Success find by ids, soft deleted excluded",
+				testType: "P",
+				param:    entity.FarmParam{IDs: []string{"farm-3", "farm-4", "farm-1"}, Limit: 10, Page: 1},
+				expected: []string{"farm-1", "farm-3"},
+			},
+			{
+				testID:   6,
+				testDesc: "Success find after id",
+				testType: "P",
+				param:    entity.FarmParam{AfterID: "farm-1", Limit: 1, Page: 1},
+				expected: []string{"farm-2"},
+			},
+		}
+
+		for _, tc := range testCases {
+			t.Logf("%d - [%s] : %s", tc.testID, tc.testType, tc.testDesc)
+			for _, farmRepo := range farmRepos {
+				farms, err := farmRepo.Find(ctx, tc.param)
+				So(err, ShouldBeNil)
+
+				ids := []string{}
+				for _, farm := range farms {
+					ids = append(ids, farm.ID)
+				}
+				So(ids, ShouldResemble, tc.expected)
+			}
+		}
+	})
+}
+
+func TestMemoryFarmCreate(t *testing.T) {
+	Convey("TestMemoryFarmCreate", t, FailureHalts, func() {
+		ctx := context.Background()
+		farmRepo := repository.NewMemoryFarm()
+
+		So(farmRepo.Create(ctx, entity.Farm{ID: "farm-1"}), ShouldBeNil)
+		So(farmRepo.Create(ctx, entity.Farm{ID: "farm-1"}), ShouldEqual, entity.ErrorFarmAlreadyExist)
+	})
+}
+
+// openSQLite open in memory sqlite, pool is limited to one connection since every connection has its own memory database
+func openSQLite() *gorm.DB {
+	dialector, err := repository.NewDialector(repository.DriverSQLite, "file::memory:")
+	So(err, ShouldBeNil)
+
+	db, err := gorm.Open(dialector, &gorm.Config{
+		NamingStrategy: schema.NamingStrategy{
+			SingularTable: true,
+		},
+	})
+	So(err, ShouldBeNil)
+
+	sqlDB, err := db.DB()
+	So(err, ShouldBeNil)
+	sqlDB.SetMaxOpenConns(1)
+
+	return db
+}
+
+func TestSQLFarmCreate(t *testing.T) {
+	Convey("TestSQLFarmCreate", t, FailureHalts, func() {
+		ctx := context.Background()
+		db := openSQLite()
+		So(db.AutoMigrate(&entity.Farm{}), ShouldBeNil)
+
+		farmRepo := repository.NewSQLFarm(db)
+
+		So(farmRepo.Create(ctx, entity.Farm{ID: "farm-1"}), ShouldBeNil)
+		So(farmRepo.Create(ctx, entity.Farm{ID: "farm-1"}), ShouldEqual, entity.ErrorFarmAlreadyExist)
+
+		canceledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		So(farmRepo.Create(canceledCtx, entity.Farm{ID: "farm-2"}), ShouldWrap, context.Canceled)
+		farm, err := farmRepo.GetByID(ctx, "farm-2")
+		So(err, ShouldBeNil)
+		So(farm, ShouldBeNil)
+	})
+}
+
+// TestPondCreate run the same cases against memory & sqlite repository, so both reject pond of unknown farm
+func TestPondCreate(t *testing.T) {
+	Convey("TestPondCreate", t, FailureHalts, func() {
+		ctx := context.Background()
+		db := openSQLite()
+		So(migration.New(db).Up(), ShouldBeNil)
+
+		memoryFarm := repository.NewMemoryFarm()
+		repos := []struct {
+			farmRepo repository.FarmRepository
+			pondRepo repository.PondRepository
+		}{
+			{farmRepo: memoryFarm, pondRepo: repository.NewMemoryPond(memoryFarm)},
+			{farmRepo: repository.NewSQLFarm(db), pondRepo: repository.NewSQLPond(db)},
+		}
+
+		testCases := []struct {
+			testID   int
+			testType string
+			testDesc string
+			pond     entity.Pond
+			expected error
+		}{
+			{testID: 1, testType: "P", testDesc: "Success create pond", pond: entity.Pond{ID: "pond-1", FarmID: "farm-1"}},
+			{testID: 2, testType: "N", testDesc: "Failed create pond, already exist", pond: entity.Pond{ID: "pond-1", FarmID: "farm-1"}, expected: entity.ErrorPondAlreadyExist},
+			{testID: 3, testType: "N", testDesc: "Failed create pond, farm not found", pond: entity.Pond{ID: "pond-2", FarmID: "farm-9"}, expected: entity.ErrorFarmNotFound},
+		}
+
+		for _, repo := range repos {
+			So(repo.farmRepo.Create(ctx, entity.Farm{ID: "farm-1"}), ShouldBeNil)
+			for _, tc := range testCases {
+				t.Logf("%d - [%s] : %s", tc.testID, tc.testType, tc.testDesc)
+				So(repo.pondRepo.Create(ctx, tc.pond), ShouldEqual, tc.expected)
+			}
+
+			t.Logf("%d - [%s] : %s", 4, "N", "Failed save pond, farm not found")
+			So(repo.pondRepo.Save(ctx, entity.Pond{ID: "pond-1", FarmID: "farm-9"}), ShouldEqual, entity.ErrorFarmNotFound)
+			pond, err := repo.pondRepo.GetByID(ctx, "pond-1")
+			So(err, ShouldBeNil)
+			So(pond.FarmID, ShouldEqual, "farm-1")
+		}
+
+		t.Logf("%d - [%s] : %s", 5, "P", "Success remove ponds of removed farm")
+		So(repos[1].farmRepo.Delete(ctx, "farm-1"), ShouldBeNil)
+		pond, err := repos[1].pondRepo.GetByID(ctx, "pond-1")
+		So(err, ShouldBeNil)
+		So(pond, ShouldBeNil)
+	})
+}
+
+func TestSQLStatisticSnapshotFind(t *testing.T) {
+	Convey("TestSQLStatisticSnapshotFind", t, FailureHalts, func() {
+		ctx := context.Background()
+		db := openSQLite()
+		So(db.AutoMigrate(&entity.APIStatisticSnapshot{}), ShouldBeNil)
+
+		snapshotRepo := repository.NewSQLStatisticSnapshot(db)
+
+		first := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+		second := first.Add(time.Hour)
+		So(snapshotRepo.Create(ctx, []entity.APIStatisticSnapshot{
+			{Path: "GET /v1/farm", Count: 1, SnapshotAt: first},
+			{Path: "GET /v1/pond", Count: 2, SnapshotAt: first},
+			{Path: "GET /v1/farm", Count: 3, SnapshotAt: second},
+		}), ShouldBeNil)
+
+		testCases := []struct {
+			testID   int
+			testDesc string
+			param    entity.APIStatisticSnapshotParam
+			expected []int64
+		}{
+			{testID: 1, testDesc: "Success find all", expected: []int64{1, 2, 3}},
+			{testID: 2, testDesc: "Success find by path", param: entity.APIStatisticSnapshotParam{Path: "GET /v1/farm"}, expected: []int64{1, 3}},
+			{testID: 3, testDesc: "Success find from", param: entity.APIStatisticSnapshotParam{From: second}, expected: []int64{3}},
+			{testID: 4, testDesc: "Success find page 2", param: entity.APIStatisticSnapshotParam{Limit: 2, Page: 2}, expected: []int64{3}},
+		}
+
+		for _, tc := range testCases {
+			t.Logf("%d - [P] : %s", tc.testID, tc.testDesc)
+			snapshots, err := snapshotRepo.Find(ctx, tc.param)
+			So(err, ShouldBeNil)
+
+			counts := []int64{}
+			for _, snapshot := range snapshots {
+				counts = append(counts, snapshot.Count)
+			}
+			So(counts, ShouldResemble, tc.expected)
+		}
+	})
+}
+
+// timeoutError is net.Error of read deadline passed
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIsTransientError(t *testing.T) {
+	Convey("TestIsTransientError", t, FailureHalts, func() {
+		testCases := []struct {
+			testID   int
+			testType string
+			testDesc string
+			err      error
+			expected bool
+		}{
+			{testID: 1, testType: "P", testDesc: "Lost connection", err: fmt.Errorf("query : %w", driver.ErrBadConn), expected: true},
+			{testID: 2, testType: "P", testDesc: "MySQL deadlock", err: &mysql.MySQLError{Number: entity.CodeMySQLDeadlock}, expected: true},
+			{testID: 3, testType: "P", testDesc: "Postgres serialization failure", err: &pgconn.PgError{Code: entity.CodePostgresSerializationFailure}, expected: true},
+			{testID: 4, testType: "P", testDesc: "Postgres connection failure", err: &pgconn.PgError{Code: "08006"}, expected: true},
+			{testID: 5, testType: "N", testDesc: "MySQL duplicate entry", err: &mysql.MySQLError{Number: entity.CodeMySQLDuplicateEntry}, expected: false},
+			{testID: 6, testType: "N", testDesc: "Context deadline exceeded", err: context.DeadlineExceeded, expected: false},
+			{testID: 7, testType: "N", testDesc: "Nil error", err: nil, expected: false},
+			{testID: 8, testType: "P", testDesc: "MySQL lock wait timeout", err: &mysql.MySQLError{Number: entity.CodeMySQLLockWaitTimeout}, expected: true},
+			{testID: 9, testType: "P", testDesc: "Postgres lock not available", err: &pgconn.PgError{Code: entity.CodePostgresLockNotAvailable}, expected: true},
+			{testID: 10, testType: "P", testDesc: "Network timeout", err: &net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}}, expected: true},
+			{testID: 11, testType: "N", testDesc: "Connection refused", err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, expected: false},
+			{testID: 12, testType: "N", testDesc: "DNS lookup failure", err: &net.DNSError{Err: "no such host", Name: "db"}, expected: false},
+			{testID: 13, testType: "N", testDesc: "Postgres unique violation", err: &pgconn.PgError{Code: entity.CodePostgresUniqueViolation}, expected: false},
+		}
+
+		for _, tc := range testCases {
+			t.Logf("%d - [%s] : %s", tc.testID, tc.testType, tc.testDesc)
+			So(repository.IsTransientError(tc.err), ShouldEqual, tc.expected)
+		}
+	})
+}
+
+func TestReplicaSet(t *testing.T) {
+	Convey("TestReplicaSet", t, FailureHalts, func() {
+		ctx := context.Background()
+		openDB := func(name string) *gorm.DB {
+			dialector, err := repository.NewDialector(repository.DriverSQLite, fmt.Sprintf("file:%s?mode=memory&cache=shared", name))
+			So(err, ShouldBeNil)
+
+			db, err := gorm.Open(dialector, &gorm.Config{
+				NamingStrategy: schema.NamingStrategy{
+					SingularTable: true,
+				},
+			})
+			So(err, ShouldBeNil)
+			So(db.AutoMigrate(&entity.Farm{}), ShouldBeNil)
+
+			return db
+		}
+		primary := openDB("replica-set-primary")
+		replica := openDB("replica-set-replica")
+
+		replicas := repository.NewReplicaSet(primary, []*gorm.DB{replica}, time.Hour)
+		farmRepo := repository.NewReplicaFarm(replicas)
+
+		// replica lag behind, only primary has farm-1
+		So(farmRepo.Create(ctx, entity.Farm{ID: "farm-1"}), ShouldBeNil)
+		So(repository.NewSQLFarm(replica).Create(ctx, entity.Farm{ID: "farm-0"}), ShouldBeNil)
+
+		t.Logf("%d - [%s] : %s", 1, "P", "Success lookup by id from primary")
+		farm, err := farmRepo.GetByID(ctx, "farm-1")
+		So(err, ShouldBeNil)
+		So(farm, ShouldNotBeNil)
+
+		t.Logf("%d - [%s] : %s", 2, "P", "Success find from replica")
+		farms, err := farmRepo.Find(ctx, entity.FarmParam{Limit: 10})
+		So(err, ShouldBeNil)
+		So(farms, ShouldHaveLength, 1)
+		So(farms[0].ID, ShouldEqual, "farm-0")
+
+		t.Logf("%d - [%s] : %s", 3, "N", "Canceled context is not retried on primary")
+		canceledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err = farmRepo.Count(canceledCtx)
+		So(err, ShouldWrap, context.Canceled)
+
+		t.Logf("%d - [%s] : %s", 4, "N", "Failed find, query error of replica returned without marking it down")
+		pondRepo := repository.NewReplicaPond(replicas)
+		_, err = pondRepo.Find(ctx, entity.PondParam{Limit: 10})
+		So(err, ShouldNotBeNil)
+		farms, err = farmRepo.Find(ctx, entity.FarmParam{Limit: 10})
+		So(err, ShouldBeNil)
+		So(farms, ShouldHaveLength, 1)
+		So(farms[0].ID, ShouldEqual, "farm-0")
+
+		t.Logf("%d - [%s] : %s", 5, "P", "Success fall back to primary when replica is unreachable")
+		// nothing listen on the port, connection is refused
+		dialector, err := repository.NewDialector(repository.DriverPostgres, "host=127.0.0.1 port=1 user=user dbname=farm connect_timeout=1")
+		So(err, ShouldBeNil)
+		unreachable, err := gorm.Open(dialector, &gorm.Config{DisableAutomaticPing: true})
+		So(err, ShouldBeNil)
+
+		farmRepo = repository.NewReplicaFarm(repository.NewReplicaSet(primary, []*gorm.DB{unreachable}, time.Hour))
+		farms, err = farmRepo.Find(ctx, entity.FarmParam{Limit: 10})
+		So(err, ShouldBeNil)
+		So(farms, ShouldHaveLength, 1)
+		So(farms[0].ID, ShouldEqual, "farm-1")
+
+		count, err := farmRepo.Count(ctx)
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 1)
+	})
+}
+
+func TestSQLTracing(t *testing.T) {
+	Convey("TestSQLTracing", t, FailureHalts, func() {
+		recorder := tracetest.NewSpanRecorder()
+		tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		defer tracerProvider.Shutdown(context.Background())
+
+		db := openSQLite()
+		So(db.AutoMigrate(&entity.Farm{}), ShouldBeNil)
+		So(db.Use(tracing.NewPlugin(tracing.WithTracerProvider(tracerProvider), tracing.WithoutMetrics())), ShouldBeNil)
+		farmRepo := repository.NewSQLFarm(db)
+
+		t.Log("1 - [P] : Success trace query as child of request span")
+		ctx, parent := tracerProvider.Tracer("test").Start(context.Background(), "GET /v1/farm/{id}")
+		So(farmRepo.Create(ctx, entity.Farm{ID: "farm-1"}), ShouldBeNil)
+		_, err := farmRepo.GetByID(ctx, "farm-1")
+		So(err, ShouldBeNil)
+		parent.End()
+
+		spans := recorder.Ended()
+		So(spans, ShouldHaveLength, 3)
+		for _, span := range spans[:2] {
+			So(span.Parent().SpanID(), ShouldEqual, parent.SpanContext().SpanID())
+			So(span.SpanContext().TraceID(), ShouldEqual, parent.SpanContext().TraceID())
+		}
+	})
+}
@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/alvinatthariq/farmsvc-go/entity"
+
+	gosqlite "github.com/glebarez/go-sqlite"
+	"github.com/glebarez/sqlite"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	gormmysql "gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+var (
+	errDuplicateKey      = errors.New("duplicate key")
+	errForeignKeyViolate = errors.New("foreign key constraint failed")
+)
+
+// NewDialector return gorm dialector of driver, empty driver default to mysql
+func NewDialector(driver string, connectionString string) (gorm.Dialector, error) {
+	switch driver {
+	case "", DriverMySQL:
+		return gormmysql.Open(connectionString), nil
+	case DriverPostgres:
+		return postgres.Open(connectionString), nil
+	case DriverSQLite:
+		return sqlite.Open(sqliteConnectionString(connectionString)), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+}
+
+// sqliteConnectionString enable foreign key enforcement on every connection, sqlite turn it off by default.
+// Transaction take write lock when it begin, otherwise transaction reading before writing fail with SQLITE_BUSY
+// instead of waiting when another connection is writing
+func sqliteConnectionString(connectionString string) string {
+	params := []string{}
+	if !strings.Contains(connectionString, "foreign_keys") {
+		params = append(params, "_pragma=foreign_keys(1)")
+	}
+	if !strings.Contains(connectionString, "_txlock") {
+		params = append(params, "_txlock=immediate")
+	}
+	if len(params) < 1 {
+		return connectionString
+	}
+
+	separator := "?"
+	if strings.Contains(connectionString, "?") {
+		separator = "&"
+	}
+
+	return connectionString + separator + strings.Join(params, "&")
+}
+
+// translateSQLError map driver specific constraint error into errDuplicateKey or errForeignKeyViolate,
+// other error is returned as is
+func translateSQLError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var (
+		mysqlError    *mysql.MySQLError
+		postgresError *pgconn.PgError
+		sqliteError   *gosqlite.Error
+	)
+	switch {
+	case errors.As(err, &mysqlError):
+		switch mysqlError.Number {
+		case entity.CodeMySQLDuplicateEntry:
+			return errDuplicateKey
+		case entity.CodeMySQLForeignKeyConstraintFailed:
+			return errForeignKeyViolate
+		}
+	case errors.As(err, &postgresError):
+		switch postgresError.Code {
+		case entity.CodePostgresUniqueViolation:
+			return errDuplicateKey
+		case entity.CodePostgresForeignKeyViolation:
+			return errForeignKeyViolate
+		}
+	case errors.As(err, &sqliteError):
+		switch sqliteError.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			return errDuplicateKey
+		case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+			return errForeignKeyViolate
+		}
+	}
+
+	return err
+}
+
+// isConnectionError return whether err is failure to reach or keep connection to database, such as connection
+// refused, lost connection or server shutting down, rather than error of the query itself
+func isConnectionError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var (
+		postgresError *pgconn.PgError
+		netError      net.Error
+	)
+	switch {
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, mysql.ErrInvalidConn), errors.Is(err, io.ErrUnexpectedEOF):
+		return true
+	case errors.As(err, &postgresError):
+		return postgresError.Code == entity.CodePostgresAdminShutdown ||
+			strings.HasPrefix(postgresError.Code, entity.CodePostgresClassConnection)
+	case errors.As(err, &netError):
+		return true
+	}
+
+	return false
+}
+
+// IsTransientError return whether err is likely gone on retry: deadlock, lock wait timeout, serialization failure,
+// lost connection or network timeout. Other network error such as connection refused is not, it last longer than
+// a retry backoff. Only operation which is safe to run twice should be retried, write may have been applied before connection is lost
+func IsTransientError(err error) bool {
+	// caller gave up, context error also implement net.Error
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var (
+		mysqlError    *mysql.MySQLError
+		postgresError *pgconn.PgError
+		sqliteError   *gosqlite.Error
+		netError      net.Error
+	)
+	switch {
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, mysql.ErrInvalidConn), errors.Is(err, io.ErrUnexpectedEOF):
+		return true
+	case errors.As(err, &mysqlError):
+		switch mysqlError.Number {
+		case entity.CodeMySQLDeadlock, entity.CodeMySQLLockWaitTimeout:
+			return true
+		}
+	case errors.As(err, &postgresError):
+		switch postgresError.Code {
+		case entity.CodePostgresSerializationFailure, entity.CodePostgresDeadlockDetected, entity.CodePostgresLockNotAvailable,
+			entity.CodePostgresAdminShutdown:
+			return true
+		}
+		return strings.HasPrefix(postgresError.Code, entity.CodePostgresClassConnection)
+	case errors.As(err, &sqliteError):
+		switch sqliteError.Code() & 0xff {
+		case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+			return true
+		}
+	case errors.As(err, &netError):
+		return netError.Timeout()
+	}
+
+	return false
+}
+
+// SnapshotTxOptions return options of read only transaction seeing one snapshot of database,
+// sqlite transaction is serializable already
+func SnapshotTxOptions(db *gorm.DB) *sql.TxOptions {
+	if db.Dialector.Name() == DriverSQLite {
+		return nil
+	}
+
+	return &sql.TxOptions{
+		Isolation: sql.LevelRepeatableRead,
+		ReadOnly:  true,
+	}
+}
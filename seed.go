@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/alvinatthariq/farmsvc-go/transfer"
+)
+
+const defaultSeedFile = "fixtures/demo.json"
+
+// RunSeed handle seed command, farms & ponds of fixture are created or overwritten so it can be run again
+func RunSeed(args []string) {
+	flags := flag.NewFlagSet("seed", flag.ExitOnError)
+	file := flags.String("file", defaultSeedFile, "fixture in json export format")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: farmsvc-go seed [-file fixture.json]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	InitApp(os.Stderr)
+
+	f, err := os.Open(*file)
+	if err != nil {
+		fatal(err)
+	}
+	defer f.Close()
+
+	dataset, err := transfer.Read(f, transfer.FormatJSON, "")
+	if err != nil {
+		fatal(fmt.Errorf("Invalid Fixture %s : %w", *file, err))
+	}
+
+	InitCommandDomain(true)
+	defer CloseCommandDomain()
+
+	farms, ponds, err := transfer.Import(context.Background(), dom, dataset)
+	if err != nil {
+		fatal(err)
+	}
+	appLogger.Info("Seed Completed", slog.String("file", *file), slog.Int("farms", farms), slog.Int("ponds", ponds))
+}
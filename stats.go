@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/alvinatthariq/farmsvc-go/entity"
+)
+
+// RunStats handle stats command, lifetime api statistic of every path is printed as table or json
+func RunStats(args []string) {
+	flags := flag.NewFlagSet("stats", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print json instead of table")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: farmsvc-go stats [-json]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	InitApp(os.Stderr)
+	InitCommandDomain(false)
+	defer CloseCommandDomain()
+
+	// statistic fall back to empty memory while redis is down, which would print nothing
+	if err := redisClient.Ping().Err(); err != nil {
+		fatal(fmt.Errorf("Cannot connect to Redis : %w", err))
+	}
+
+	apiStatistics, err := dom.GetAPIStatistic(context.Background(), entity.APIStatisticParam{})
+	if err != nil {
+		fatal(err)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(apiStatistics); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tCOUNT\tERRORS\tUNIQUE USER AGENT\tP50 MS\tP95 MS\tP99 MS")
+	for _, apiStatistic := range apiStatistics {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%.1f\t%.1f\t%.1f\n",
+			apiStatistic.Path,
+			apiStatistic.Count,
+			apiStatistic.ErrorCount,
+			apiStatistic.UniqueUserAgent,
+			apiStatistic.Latency.P50,
+			apiStatistic.Latency.P95,
+			apiStatistic.Latency.P99,
+		)
+	}
+	if err := w.Flush(); err != nil {
+		fatal(err)
+	}
+}
@@ -0,0 +1,64 @@
+package telemetry
+
+import (
+	"github.com/go-redis/redis"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WrapRedis create client span for every command & pipeline of client, parented by client.Context().
+// Wrap the clone returned by client.WithContext(ctx) of every request, never the shared client: its context
+// is Background so every span would be a root, and clone keep the wrapper of client it is cloned from
+func WrapRedis(client *redis.Client) {
+	tracer := otel.Tracer(instrumentationName)
+
+	client.WrapProcess(func(oldProcess func(cmd redis.Cmder) error) func(cmd redis.Cmder) error {
+		return func(cmd redis.Cmder) error {
+			_, span := tracer.Start(client.Context(), "redis "+cmd.Name(),
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					semconv.DBSystemRedis,
+					semconv.DBOperation(cmd.Name()),
+				),
+			)
+			defer span.End()
+
+			err := oldProcess(cmd)
+			recordRedisError(span, err)
+
+			return err
+		}
+	})
+
+	client.WrapProcessPipeline(func(oldProcess func(cmds []redis.Cmder) error) func(cmds []redis.Cmder) error {
+		return func(cmds []redis.Cmder) error {
+			_, span := tracer.Start(client.Context(), "redis pipeline",
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					semconv.DBSystemRedis,
+					semconv.DBOperation("pipeline"),
+					attribute.Int("db.redis.num_cmd", len(cmds)),
+				),
+			)
+			defer span.End()
+
+			err := oldProcess(cmds)
+			recordRedisError(span, err)
+
+			return err
+		}
+	})
+}
+
+// recordRedisError mark span failed, redis.Nil is a miss and not an error
+func recordRedisError(span trace.Span, err error) {
+	if err == nil || err == redis.Nil {
+		return
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
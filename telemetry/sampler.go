@@ -0,0 +1,44 @@
+package telemetry
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// ratioSampler sample fraction of new trace returned by ratio, so it can change while tracer provider is running
+type ratioSampler struct {
+	ratio   func() float64
+	sampler atomic.Pointer[ratioSamplerState]
+}
+
+// ratioSamplerState is sampler of ratio, rebuilt only when ratio change
+type ratioSamplerState struct {
+	ratio   float64
+	sampler sdktrace.Sampler
+}
+
+func newRatioSampler(ratio func() float64) *ratioSampler {
+	return &ratioSampler{ratio: ratio}
+}
+
+func (s *ratioSampler) current() sdktrace.Sampler {
+	ratio := s.ratio()
+	if state := s.sampler.Load(); state != nil && state.ratio == ratio {
+		return state.sampler
+	}
+
+	state := &ratioSamplerState{ratio: ratio, sampler: sdktrace.TraceIDRatioBased(ratio)}
+	s.sampler.Store(state)
+
+	return state.sampler
+}
+
+func (s *ratioSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	return s.current().ShouldSample(p)
+}
+
+func (s *ratioSampler) Description() string {
+	return fmt.Sprintf("Reloadable{%s}", s.current().Description())
+}
@@ -0,0 +1,100 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+
+	DefaultServiceName  = "farmsvc"
+	DefaultOTLPEndpoint = "localhost:4318"
+
+	instrumentationName = "github.com/alvinatthariq/farmsvc-go/telemetry"
+)
+
+type Options struct {
+	// Exporter is otlp, stdout or none, default to none which only propagate incoming trace context
+	Exporter string
+	// OTLPEndpoint is host:port of OTLP/HTTP collector, default to DefaultOTLPEndpoint
+	OTLPEndpoint string
+	// OTLPInsecure send span to collector over plain http instead of https
+	OTLPInsecure bool
+	// ServiceName default to DefaultServiceName
+	ServiceName string
+	// SampleRatio is fraction of new trace sampled, incoming request follow sampling decision of caller
+	SampleRatio float64
+	// SampleRatioFunc return SampleRatio for every new trace so it change without restart, SampleRatio is used when nil
+	SampleRatioFunc func() float64
+	// Output is where stdout exporter write span, default to stdout
+	Output io.Writer
+}
+
+// Init set global tracer provider & W3C traceparent propagator,
+// shutdown flush pending spans and must be called before exit
+func Init(opt Options) (shutdown func(ctx context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	noop := func(ctx context.Context) error { return nil }
+
+	var exporter sdktrace.SpanExporter
+	switch opt.Exporter {
+	case "", ExporterNone:
+		return noop, nil
+	case ExporterStdout:
+		if opt.Output == nil {
+			opt.Output = os.Stdout
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(opt.Output))
+	case ExporterOTLP:
+		if opt.OTLPEndpoint == "" {
+			opt.OTLPEndpoint = DefaultOTLPEndpoint
+		}
+		clientOpts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(opt.OTLPEndpoint)}
+		if opt.OTLPInsecure {
+			clientOpts = append(clientOpts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(context.Background(), clientOpts...)
+	default:
+		return noop, fmt.Errorf("Invalid Tracing Exporter %q, must be otlp, stdout or none", opt.Exporter)
+	}
+	if err != nil {
+		return noop, err
+	}
+
+	if opt.ServiceName == "" {
+		opt.ServiceName = DefaultServiceName
+	}
+
+	if opt.SampleRatioFunc == nil {
+		sampleRatio := opt.SampleRatio
+		opt.SampleRatioFunc = func() float64 { return sampleRatio }
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(newRatioSampler(opt.SampleRatioFunc))),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(opt.ServiceName),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
@@ -0,0 +1,106 @@
+package telemetry_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alvinatthariq/farmsvc-go/telemetry"
+
+	"github.com/go-redis/redis"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestInit(t *testing.T) {
+	Convey("TestInit", t, FailureHalts, func() {
+		var buf bytes.Buffer
+
+		testCases := []struct {
+			testID   int
+			testType string
+			testDesc string
+			opt      telemetry.Options
+		}{
+			{
+				testID:   1,
+				testDesc: "Success init without exporter",
+				testType: "P",
+				opt:      telemetry.Options{Exporter: telemetry.ExporterNone},
+			},
+			{
+				testID:   2,
+				testDesc: "Success init stdout exporter",
+				testType: "P",
+				opt:      telemetry.Options{Exporter: telemetry.ExporterStdout, Output: &buf, SampleRatio: 1},
+			},
+			{
+				testID:   3,
+				testDesc: "Failed init, unknown exporter",
+				testType: "N",
+				opt:      telemetry.Options{Exporter: "zipkin"},
+			},
+		}
+
+		for _, tc := range testCases {
+			t.Logf("%d - [%s] : %s", tc.testID, tc.testType, tc.testDesc)
+			shutdown, err := telemetry.Init(tc.opt)
+			if tc.testType == "P" {
+				So(err, ShouldBeNil)
+			} else {
+				So(err, ShouldNotBeNil)
+			}
+
+			if tc.opt.Exporter == telemetry.ExporterStdout {
+				_, span := otel.Tracer("test").Start(context.Background(), "test span")
+				span.End()
+				So(shutdown(context.Background()), ShouldBeNil)
+				So(buf.String(), ShouldContainSubstring, "test span")
+			}
+		}
+	})
+}
+
+func TestWrapRedis(t *testing.T) {
+	Convey("TestWrapRedis", t, FailureHalts, func() {
+		recorder := tracetest.NewSpanRecorder()
+		tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		defer tracerProvider.Shutdown(context.Background())
+		otel.SetTracerProvider(tracerProvider)
+
+		// nothing listen on the port, command fail but is still traced
+		redisClient := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", DialTimeout: 100 * time.Millisecond})
+		defer redisClient.Close()
+
+		ctx, parent := otel.Tracer("test").Start(context.Background(), "GET /v1/farm/{id}")
+		client := redisClient.WithContext(ctx)
+		telemetry.WrapRedis(client)
+
+		t.Log("1 - [P] : Success trace command as child of request span")
+		So(client.Get("key").Err(), ShouldNotBeNil)
+
+		t.Log("2 - [P] : Success trace pipeline as child of request span")
+		_, err := client.Pipelined(func(pipe redis.Pipeliner) error {
+			pipe.Get("key")
+			return nil
+		})
+		So(err, ShouldNotBeNil)
+		parent.End()
+
+		spans := recorder.Ended()
+		So(spans, ShouldHaveLength, 3)
+		So(spans[0].Name(), ShouldEqual, "redis get")
+		So(spans[1].Name(), ShouldEqual, "redis pipeline")
+		for _, span := range spans[:2] {
+			So(span.Parent().SpanID(), ShouldEqual, parent.SpanContext().SpanID())
+			So(span.SpanContext().TraceID(), ShouldEqual, parent.SpanContext().TraceID())
+		}
+
+		t.Log("3 - [P] : Success keep client it is cloned from untraced")
+		So(redisClient.Get("key").Err(), ShouldNotBeNil)
+		So(recorder.Ended(), ShouldHaveLength, 3)
+	})
+}
@@ -0,0 +1,231 @@
+package transfer
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alvinatthariq/farmsvc-go/domain"
+	"github.com/alvinatthariq/farmsvc-go/entity"
+	"github.com/alvinatthariq/farmsvc-go/repository"
+
+	"gorm.io/gorm"
+)
+
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+)
+
+var (
+	farmHeader = []string{"id", "name", "description", "created_at", "updated_at"}
+	pondHeader = []string{"id", "farm_id", "name", "description", "created_at", "updated_at"}
+)
+
+// Dataset is farms & ponds exported or imported together
+type Dataset struct {
+	Farms []entity.Farm `json:"farms"`
+	Ponds []entity.Pond `json:"ponds"`
+}
+
+// FormatOf return format of path by its extension, json when unknown
+func FormatOf(path string) string {
+	if strings.EqualFold(filepath.Ext(path), "."+FormatCSV) {
+		return FormatCSV
+	}
+
+	return FormatJSON
+}
+
+// Write encode dataset into w. JSON hold farms & ponds in one document, CSV hold only resource farm or pond
+func Write(w io.Writer, format string, resource string, dataset Dataset) error {
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(dataset)
+	case FormatCSV:
+		return writeCSV(w, resource, dataset)
+	default:
+		return fmt.Errorf("Invalid Format %q, must be json or csv", format)
+	}
+}
+
+// Read decode dataset from r, CSV is read into farms or ponds by resource
+func Read(r io.Reader, format string, resource string) (dataset Dataset, err error) {
+	switch format {
+	case FormatJSON:
+		err = json.NewDecoder(r).Decode(&dataset)
+		return dataset, err
+	case FormatCSV:
+		return readCSV(r, resource)
+	default:
+		return dataset, fmt.Errorf("Invalid Format %q, must be json or csv", format)
+	}
+}
+
+func writeCSV(w io.Writer, resource string, dataset Dataset) error {
+	writer := csv.NewWriter(w)
+
+	switch resource {
+	case entity.ResourceFarm:
+		writer.Write(farmHeader)
+		for _, farm := range dataset.Farms {
+			writer.Write([]string{farm.ID, farm.Name, farm.Description, formatTime(farm.CreatedAt), formatTime(farm.UpdatedAt)})
+		}
+	case entity.ResourcePond:
+		writer.Write(pondHeader)
+		for _, pond := range dataset.Ponds {
+			writer.Write([]string{pond.ID, pond.FarmID, pond.Name, pond.Description, formatTime(pond.CreatedAt), formatTime(pond.UpdatedAt)})
+		}
+	default:
+		return fmt.Errorf("Invalid Resource %q, csv must be farm or pond", resource)
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func readCSV(r io.Reader, resource string) (dataset Dataset, err error) {
+	var header []string
+	switch resource {
+	case entity.ResourceFarm:
+		header = farmHeader
+	case entity.ResourcePond:
+		header = pondHeader
+	default:
+		return dataset, fmt.Errorf("Invalid Resource %q, csv must be farm or pond", resource)
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(header)
+	records, err := reader.ReadAll()
+	if err != nil {
+		return dataset, err
+	} else if len(records) < 1 || strings.Join(records[0], ",") != strings.Join(header, ",") {
+		return dataset, fmt.Errorf("Invalid CSV Header, must be %s", strings.Join(header, ","))
+	}
+
+	for i, record := range records[1:] {
+		line := i + 2
+		switch resource {
+		case entity.ResourceFarm:
+			farm := entity.Farm{ID: record[0], Name: record[1], Description: record[2]}
+			if farm.CreatedAt, err = parseTime(record[3]); err != nil {
+				return dataset, fmt.Errorf("line %d : %w", line, err)
+			}
+			if farm.UpdatedAt, err = parseTime(record[4]); err != nil {
+				return dataset, fmt.Errorf("line %d : %w", line, err)
+			}
+			dataset.Farms = append(dataset.Farms, farm)
+		case entity.ResourcePond:
+			pond := entity.Pond{ID: record[0], FarmID: record[1], Name: record[2], Description: record[3]}
+			if pond.CreatedAt, err = parseTime(record[4]); err != nil {
+				return dataset, fmt.Errorf("line %d : %w", line, err)
+			}
+			if pond.UpdatedAt, err = parseTime(record[5]); err != nil {
+				return dataset, fmt.Errorf("line %d : %w", line, err)
+			}
+			dataset.Ponds = append(dataset.Ponds, pond)
+		}
+	}
+
+	return dataset, nil
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
+
+// parseTime parse RFC3339 time, empty is zero time
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+// exportPageSize is how many farms or ponds are read at once on Export
+const exportPageSize = 500
+
+// Export read every not deleted farm & pond of resource from db, empty resource read both. Pages are read by id
+// in one read only transaction, so the dataset is one snapshot of db even when it is written meanwhile
+func Export(ctx context.Context, db *gorm.DB, resource string) (dataset Dataset, err error) {
+	dataset = Dataset{Farms: []entity.Farm{}, Ponds: []entity.Pond{}}
+
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if resource == "" || resource == entity.ResourceFarm {
+			farmRepo := repository.NewSQLFarm(tx)
+			for afterID := ""; ; {
+				farms, err := farmRepo.Find(ctx, entity.FarmParam{AfterID: afterID, Limit: exportPageSize})
+				if err != nil {
+					return err
+				}
+				dataset.Farms = append(dataset.Farms, farms...)
+				if len(farms) < exportPageSize {
+					break
+				}
+				afterID = farms[len(farms)-1].ID
+			}
+		}
+
+		if resource == "" || resource == entity.ResourcePond {
+			pondRepo := repository.NewSQLPond(tx)
+			for afterID := ""; ; {
+				ponds, err := pondRepo.Find(ctx, entity.PondParam{AfterID: afterID, Limit: exportPageSize})
+				if err != nil {
+					return err
+				}
+				dataset.Ponds = append(dataset.Ponds, ponds...)
+				if len(ponds) < exportPageSize {
+					break
+				}
+				afterID = ponds[len(ponds)-1].ID
+			}
+		}
+
+		return nil
+	}, repository.SnapshotTxOptions(db))
+
+	return dataset, err
+}
+
+// Import create or update every farm then every pond of dataset, so pond can refer farm of the same dataset.
+// Existing farm or pond is overwritten, soft deleted one restored, and timestamps of dataset are kept, it stop on first invalid one
+func Import(ctx context.Context, dom domain.DomainItf, dataset Dataset) (farms int, ponds int, err error) {
+	for _, farm := range dataset.Farms {
+		if strings.TrimSpace(farm.ID) == "" {
+			_, err = dom.CreateFarm(ctx, entity.CreateFarmRequest{Name: farm.Name, Description: farm.Description, CreatedAt: farm.CreatedAt, UpdatedAt: farm.UpdatedAt})
+		} else {
+			_, err = dom.UpdateFarm(ctx, farm.ID, entity.UpdateFarmRequest{Name: farm.Name, Description: farm.Description, CreatedAt: farm.CreatedAt, UpdatedAt: farm.UpdatedAt})
+		}
+		if err != nil {
+			return farms, ponds, fmt.Errorf("farm %d %q : %w", farms+1, farm.ID, err)
+		}
+		farms++
+	}
+
+	for _, pond := range dataset.Ponds {
+		if strings.TrimSpace(pond.ID) == "" {
+			_, err = dom.CreatePond(ctx, entity.CreatePondRequest{FarmID: pond.FarmID, Name: pond.Name, Description: pond.Description, CreatedAt: pond.CreatedAt, UpdatedAt: pond.UpdatedAt})
+		} else {
+			_, err = dom.UpdatePond(ctx, pond.ID, entity.UpdatePondRequest{FarmID: pond.FarmID, Name: pond.Name, Description: pond.Description, CreatedAt: pond.CreatedAt, UpdatedAt: pond.UpdatedAt})
+		}
+		if err != nil {
+			return farms, ponds, fmt.Errorf("pond %d %q : %w", ponds+1, pond.ID, err)
+		}
+		ponds++
+	}
+
+	return farms, ponds, nil
+}
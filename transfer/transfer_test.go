@@ -0,0 +1,198 @@
+package transfer_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alvinatthariq/farmsvc-go/domain"
+	"github.com/alvinatthariq/farmsvc-go/entity"
+	"github.com/alvinatthariq/farmsvc-go/migration"
+	"github.com/alvinatthariq/farmsvc-go/repository"
+	"github.com/alvinatthariq/farmsvc-go/transfer"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+func TestReadWrite(t *testing.T) {
+	Convey("TestReadWrite", t, FailureHalts, func() {
+		createdAt := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+		dataset := transfer.Dataset{
+			Farms: []entity.Farm{{ID: "farm-1", Name: "alpha", Description: "with, comma", CreatedAt: createdAt, UpdatedAt: createdAt}},
+			Ponds: []entity.Pond{{ID: "pond-1", FarmID: "farm-1", Name: "beta", Description: "with \"quote\"", CreatedAt: createdAt, UpdatedAt: createdAt}},
+		}
+
+		testCases := []struct {
+			testID   int
+			testType string
+			testDesc string
+			format   string
+			resource string
+			expected transfer.Dataset
+		}{
+			{testID: 1, testType: "P", testDesc: "Success json round trip", format: transfer.FormatJSON, expected: dataset},
+			{testID: 2, testType: "P", testDesc: "Success farm csv round trip", format: transfer.FormatCSV, resource: entity.ResourceFarm, expected: transfer.Dataset{Farms: dataset.Farms}},
+			{testID: 3, testType: "P", testDesc: "Success pond csv round trip", format: transfer.FormatCSV, resource: entity.ResourcePond, expected: transfer.Dataset{Ponds: dataset.Ponds}},
+			{testID: 4, testType: "N", testDesc: "Failed csv without resource", format: transfer.FormatCSV},
+			{testID: 5, testType: "N", testDesc: "Failed unknown format", format: "xml"},
+		}
+
+		for _, tc := range testCases {
+			t.Logf("%d - [%s] : %s", tc.testID, tc.testType, tc.testDesc)
+			var buf bytes.Buffer
+			err := transfer.Write(&buf, tc.format, tc.resource, dataset)
+			if tc.testType == "N" {
+				So(err, ShouldNotBeNil)
+				continue
+			}
+			So(err, ShouldBeNil)
+
+			result, err := transfer.Read(&buf, tc.format, tc.resource)
+			So(err, ShouldBeNil)
+			So(result, ShouldResemble, tc.expected)
+		}
+
+		t.Logf("%d - [%s] : %s", 6, "N", "Failed csv of other resource")
+		_, err := transfer.Read(strings.NewReader("id,name,description,created_at,updated_at\n"), transfer.FormatCSV, entity.ResourcePond)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func openDB(t *testing.T) *gorm.DB {
+	dialector, err := repository.NewDialector(repository.DriverSQLite, filepath.Join(t.TempDir(), "farm.db"))
+	So(err, ShouldBeNil)
+
+	db, err := gorm.Open(dialector, &gorm.Config{
+		NamingStrategy: schema.NamingStrategy{
+			SingularTable: true,
+		},
+	})
+	So(err, ShouldBeNil)
+	So(migration.New(db).Up(), ShouldBeNil)
+
+	return db
+}
+
+func TestImportExport(t *testing.T) {
+	Convey("TestImportExport", t, FailureHalts, func() {
+		ctx := context.Background()
+		db := openDB(t)
+		// farm & pond are imported into db which is exported, redis is not needed
+		repo := repository.NewMemory()
+		repo.Farm, repo.Pond = repository.NewSQLFarm(db), repository.NewSQLPond(db)
+		dom := domain.InitWithRepository(repo, domain.Options{})
+		defer dom.Close()
+
+		createdAt := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+		updatedAt := createdAt.Add(time.Hour)
+		farms, ponds, err := transfer.Import(ctx, dom, transfer.Dataset{
+			Farms: []entity.Farm{{ID: "farm-1", Name: "alpha", Description: "first", CreatedAt: createdAt, UpdatedAt: updatedAt}, {Name: "beta", Description: "generated id"}},
+			Ponds: []entity.Pond{{ID: "pond-1", FarmID: "farm-1", Name: "gamma", Description: "first", CreatedAt: createdAt, UpdatedAt: updatedAt}},
+		})
+		So(err, ShouldBeNil)
+		So(farms, ShouldEqual, 2)
+		So(ponds, ShouldEqual, 1)
+
+		t.Log("1 - [P] : Success keep timestamps of imported farm & pond")
+		farm, err := dom.GetFarmByID(ctx, "farm-1")
+		So(err, ShouldBeNil)
+		So(farm.CreatedAt.Equal(createdAt), ShouldBeTrue)
+		So(farm.UpdatedAt.Equal(updatedAt), ShouldBeTrue)
+		pond, err := dom.GetPondByID(ctx, "pond-1")
+		So(err, ShouldBeNil)
+		So(pond.CreatedAt.Equal(createdAt), ShouldBeTrue)
+		So(pond.UpdatedAt.Equal(updatedAt), ShouldBeTrue)
+
+		t.Log("2 - [P] : Success overwrite existing farm, empty timestamp set to now")
+		_, _, err = transfer.Import(ctx, dom, transfer.Dataset{
+			Farms: []entity.Farm{{ID: "farm-1", Name: "alpha 2", Description: "first"}},
+		})
+		So(err, ShouldBeNil)
+		farm, err = dom.GetFarmByID(ctx, "farm-1")
+		So(err, ShouldBeNil)
+		So(farm.Name, ShouldEqual, "alpha 2")
+		So(farm.CreatedAt.Equal(createdAt), ShouldBeTrue)
+		So(farm.UpdatedAt.After(updatedAt), ShouldBeTrue)
+
+		t.Log("3 - [P] : Success export farms & ponds")
+		dataset, err := transfer.Export(ctx, db, "")
+		So(err, ShouldBeNil)
+		So(dataset.Farms, ShouldHaveLength, 2)
+		So(dataset.Ponds, ShouldHaveLength, 1)
+
+		t.Log("4 - [P] : Success export only ponds")
+		dataset, err = transfer.Export(ctx, db, entity.ResourcePond)
+		So(err, ShouldBeNil)
+		So(dataset.Farms, ShouldBeEmpty)
+		So(dataset.Ponds, ShouldHaveLength, 1)
+
+		t.Log("5 - [N] : Failed import pond of unknown farm, import stopped")
+		_, ponds, err = transfer.Import(ctx, dom, transfer.Dataset{
+			Ponds: []entity.Pond{{ID: "pond-2", FarmID: "farm-9", Name: "delta", Description: "orphan"}},
+		})
+		So(err, ShouldWrap, entity.ErrorFarmNotFound)
+		So(ponds, ShouldEqual, 0)
+
+		t.Log("6 - [P] : Success restore soft deleted farm & pond of the same id")
+		So(dom.DeletePondByID(ctx, "pond-1"), ShouldBeNil)
+		So(dom.DeleteFarmByID(ctx, "farm-1"), ShouldBeNil)
+		dataset, err = transfer.Export(ctx, db, "")
+		So(err, ShouldBeNil)
+		So(dataset.Farms, ShouldHaveLength, 1)
+		So(dataset.Ponds, ShouldBeEmpty)
+		_, _, err = transfer.Import(ctx, dom, transfer.Dataset{
+			Farms: []entity.Farm{{ID: "farm-1", Name: "alpha 3", Description: "first"}},
+			Ponds: []entity.Pond{{ID: "pond-1", FarmID: "farm-1", Name: "gamma 3", Description: "first"}},
+		})
+		So(err, ShouldBeNil)
+		farm, err = dom.GetFarmByID(ctx, "farm-1")
+		So(err, ShouldBeNil)
+		So(farm.IsDeleted.Valid, ShouldBeFalse)
+		So(farm.DeletedAt.Valid, ShouldBeFalse)
+		dataset, err = transfer.Export(ctx, db, "")
+		So(err, ShouldBeNil)
+		So(dataset.Farms, ShouldHaveLength, 2)
+		So(dataset.Ponds, ShouldHaveLength, 1)
+		So(dataset.Ponds[0].Name, ShouldEqual, "gamma 3")
+	})
+}
+
+func TestExportPages(t *testing.T) {
+	Convey("TestExportPages", t, FailureHalts, func() {
+		ctx := context.Background()
+		db := openDB(t)
+
+		// more than one page, soft deleted farm & its pond are left out
+		farms := []entity.Farm{}
+		for i := 0; i < 1001; i++ {
+			farms = append(farms, entity.Farm{ID: fmt.Sprintf("farm-%04d", i), Name: fmt.Sprintf("farm %d", i)})
+		}
+		farms[500].IsDeleted = sql.NullBool{Bool: true, Valid: true}
+		So(db.CreateInBatches(farms, 200).Error, ShouldBeNil)
+		So(db.Create(&entity.Pond{ID: "pond-1", FarmID: "farm-0000", Name: "pond"}).Error, ShouldBeNil)
+
+		t.Log("1 - [P] : Success export every page once in id order")
+		dataset, err := transfer.Export(ctx, db, "")
+		So(err, ShouldBeNil)
+		So(dataset.Farms, ShouldHaveLength, 1000)
+		So(dataset.Ponds, ShouldHaveLength, 1)
+		for i := 1; i < len(dataset.Farms); i++ {
+			So(dataset.Farms[i-1].ID, ShouldBeLessThan, dataset.Farms[i].ID)
+		}
+		So(dataset.Farms[500].ID, ShouldEqual, "farm-0501")
+
+		t.Log("2 - [N] : Failed export on closed database")
+		sqlDB, err := db.DB()
+		So(err, ShouldBeNil)
+		So(sqlDB.Close(), ShouldBeNil)
+		_, err = transfer.Export(ctx, db, entity.ResourceFarm)
+		So(err, ShouldNotBeNil)
+	})
+}